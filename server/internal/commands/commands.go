@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"fmt"
+
+	"server/internal/services"
+)
+
+// Env carries the services a maintenance command may need. Services that
+// are not configured are nil.
+type Env struct {
+	Keys      *services.KeyService
+	Fsck      *services.FsckService
+	Scrub     *services.ScrubService
+	Migration *services.LayoutMigration
+	Quotas    *services.QuotaService
+	Nodes     *services.NodeService
+	Content   *services.ContentIndexService
+}
+
+// Run executes the command named by args[0] with the remaining arguments.
+func Run(env *Env, args []string) error {
+	switch args[0] {
+	case "rotate-keys":
+		return rotateKeys(env, args[1:])
+	case "fsck":
+		return fsck(env, args[1:])
+	case "scrub":
+		return scrub(env, args[1:])
+	case "migrate-storage":
+		return migrateStorage(env, args[1:])
+	case "recalc-usage":
+		return recalcUsage(env, args[1:])
+	case "set-quota":
+		return setQuota(env, args[1:])
+	case "index-names":
+		return indexNames(env, args[1:])
+	case "index-content":
+		return indexContent(env, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"flag"
+	"log"
+
+	"server/internal/services"
+)
+
+// fsck checks that nodes, blob documents and stored blobs agree with each
+// other. Use -dry-run first to see what a repair would touch.
+func fsck(env *Env, args []string) error {
+	opts := services.DefaultFsckOptions()
+	fs := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "only report problems")
+	fs.StringVar(&opts.Orphans, "orphans", opts.Orphans, "unreferenced blobs: report, quarantine or delete")
+	fs.StringVar(&opts.Missing, "missing", opts.Missing, "files whose content is missing: report or delete")
+	fs.StringVar(&opts.Dangling, "dangling", opts.Dangling, "nodes with a broken parent or in a cycle: report, lostfound or delete")
+	fs.DurationVar(&opts.Grace, "grace", opts.Grace, "ignore anything changed more recently than this")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	res, err := env.Fsck.Run(opts)
+	if res != nil {
+		for _, i := range res.Issues {
+			log.Printf("fsck: %s", i)
+		}
+		log.Printf("fsck: checked %d nodes, %d blobs, %d stored objects: %d issues", res.Nodes, res.Blobs, res.Objects, len(res.Issues))
+	}
+	return err
+}
@@ -0,0 +1,23 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"log"
+)
+
+// indexContent indexes the text of files stored before content search was
+// enabled, or missed while the server was busy.
+func indexContent(env *Env, args []string) error {
+	fs := flag.NewFlagSet("index-content", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if env.Content == nil {
+		return errors.New("content search is disabled (CONTENT_INDEX=false)")
+	}
+
+	indexed, err := env.Content.IndexAll()
+	log.Printf("index-content: indexed %d contents", indexed)
+	return err
+}
@@ -0,0 +1,19 @@
+package commands
+
+import (
+	"flag"
+	"log"
+)
+
+// indexNames gives nodes stored before sibling names were unique their name
+// key, renaming duplicates, and nodes stored before search their n-grams.
+func indexNames(env *Env, args []string) error {
+	fs := flag.NewFlagSet("index-names", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	indexed, renamed, err := env.Nodes.IndexNames()
+	log.Printf("index-names: indexed %d nodes, renamed %d duplicates", indexed, renamed)
+	return err
+}
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"flag"
+	"log"
+	"strings"
+)
+
+// migrateStorage moves files and avatars stored under the old per-owner
+// layouts into the content addressed layout. It is safe to run repeatedly;
+// run it again after failures.
+func migrateStorage(env *Env, args []string) error {
+	fs := flag.NewFlagSet("migrate-storage", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only count what would be migrated")
+	oldBases := fs.String("old-base", "", "comma separated STORAGE_BASE values used before, for nodes that recorded full paths")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	for _, b := range strings.Split(*oldBases, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			env.Migration.OldBases = append(env.Migration.OldBases, b)
+		}
+	}
+
+	res, err := env.Migration.Run(*dryRun)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		log.Printf("migrate-storage: %d items to migrate", res.Pending)
+		return nil
+	}
+	log.Printf("migrate-storage: migrated %d of %d items, %d missing, %d failed", res.Migrated, res.Pending, res.Missing, res.Failed)
+	return nil
+}
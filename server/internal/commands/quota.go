@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"log"
+)
+
+// recalcUsage rebuilds every user's usage counters from the file tree.
+func recalcUsage(env *Env, args []string) error {
+	fs := flag.NewFlagSet("recalc-usage", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	n, err := env.Quotas.Recalculate()
+	log.Printf("recalc-usage: updated %d users", n)
+	return err
+}
+
+// setQuota overrides the limits of one user. Zero falls back to the default
+// and a negative value lifts the limit.
+func setQuota(env *Env, args []string) error {
+	fs := flag.NewFlagSet("set-quota", flag.ContinueOnError)
+	user := fs.String("user", "", "user id")
+	maxBytes := fs.Int64("bytes", 0, "byte limit (0 = default, -1 = unlimited)")
+	maxFiles := fs.Int64("files", 0, "file count limit (0 = default, -1 = unlimited)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *user == "" {
+		return errors.New("-user is required")
+	}
+
+	if err := env.Quotas.SetLimits(*user, *maxBytes, *maxFiles); err != nil {
+		return err
+	}
+	log.Printf("set-quota: %s limited to %d bytes, %d files", *user, *maxBytes, *maxFiles)
+	return nil
+}
@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"log"
+)
+
+// rotateKeys re-wraps every user data key with the active master key
+// (ENCRYPTION_ACTIVE_KEY). Keep the previous master key in
+// ENCRYPTION_MASTER_KEYS until this has completed.
+func rotateKeys(env *Env, args []string) error {
+	fs := flag.NewFlagSet("rotate-keys", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if env.Keys == nil {
+		return errors.New("encryption is not configured (set ENCRYPTION_MASTER_KEYS and ENCRYPTION_ACTIVE_KEY)")
+	}
+	n, err := env.Keys.RewrapAll()
+	if err != nil {
+		return err
+	}
+	log.Printf("rotate-keys: re-wrapped %d data keys with master key %q", n, env.Keys.ActiveMasterKeyID())
+	return nil
+}
@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"flag"
+	"log"
+	"time"
+)
+
+// scrub re-hashes stored blobs and flags the files whose content no longer
+// matches the checksums taken on upload.
+func scrub(env *Env, args []string) error {
+	fs := flag.NewFlagSet("scrub", flag.ContinueOnError)
+	since := fs.Duration("since", 0, "skip blobs verified within this duration")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	res, err := env.Scrub.Run(time.Now().Add(-*since))
+	if res != nil {
+		for _, id := range res.Corrupt {
+			log.Printf("scrub: blob %s is corrupt", id)
+		}
+		log.Printf("scrub: verified %d blobs, %d corrupt, %d unreadable", res.Checked, len(res.Corrupt), res.Failed)
+	}
+	return err
+}
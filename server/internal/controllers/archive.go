@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"server/internal/models"
+	"server/internal/repository"
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type archiveReq struct {
+	IDs    []string `json:"ids" binding:"required,min=1"`
+	Name   string   `json:"name,omitempty"`   // file name without extension, defaults to the node's name or "download"
+	Format string   `json:"format,omitempty"` // zip (default) or tar.gz
+}
+
+// @Summary Download a folder as an archive
+// @Description Streams the folder with everything below it as a ZIP or tar.gz, built while it is sent. Names are stored as UTF-8; names that clash within a folder get a " (n)" suffix.
+// @Tags files
+// @Produce application/zip
+// @Produce application/gzip
+// @Param parent_id path string true "folder id"
+// @Param format query string false "zip (default) or tar.gz"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string "too many files or bytes"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /folders/{parent_id}/archive [get]
+func FolderArchiveHandler(fileRepo repository.FileRepository, archives *services.ArchiveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		ownerID := uid.(string)
+
+		format, ok := archiveFormat(c, c.Query("format"))
+		if !ok {
+			return
+		}
+		folder, err := fileRepo.FindNodeByID(c.Param("parent_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if folder == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		if folder.OwnerID != ownerID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		if folder.Type != "folder" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "not a folder"})
+			return
+		}
+		streamArchive(c, archives, []*models.Node{folder}, folder.Name, format)
+	}
+}
+
+// @Summary Download files and folders as one archive
+// @Description Streams the selected nodes, folders with everything below them, as a ZIP or tar.gz, built while it is sent. A node below another selected folder is included once. Names are stored as UTF-8; names that clash within a folder get a " (n)" suffix.
+// @Tags files
+// @Accept json
+// @Produce application/zip
+// @Produce application/gzip
+// @Param payload body archiveReq true "node ids"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string "too many files or bytes"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /archive [post]
+func ArchiveHandler(fileRepo repository.FileRepository, archives *services.ArchiveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req archiveReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		uid, _ := c.Get("user_id")
+		ownerID := uid.(string)
+
+		format, ok := archiveFormat(c, req.Format)
+		if !ok {
+			return
+		}
+		var ids []string
+		seen := map[string]bool{}
+		for _, id := range req.IDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if archives.MaxEntries > 0 && len(ids) > archives.MaxEntries {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": services.ErrArchiveTooLarge.Error()})
+			return
+		}
+		found, err := fileRepo.FindNodesByIDs(ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		byID := map[string]*models.Node{}
+		for _, n := range found {
+			byID[n.ID] = n
+		}
+		roots := make([]*models.Node, 0, len(ids))
+		for _, id := range ids {
+			n := byID[id]
+			if n == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "node not found: " + id})
+				return
+			}
+			if n.OwnerID != ownerID {
+				c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+				return
+			}
+			roots = append(roots, n)
+		}
+
+		name := req.Name
+		if name == "" {
+			name = "download"
+			if len(roots) == 1 {
+				name = roots[0].Name
+			}
+		}
+		streamArchive(c, archives, roots, name, format)
+	}
+}
+
+// archiveFormat validates the format parameter, answering 400 if it is
+// unknown.
+func archiveFormat(c *gin.Context, v string) (string, bool) {
+	switch v {
+	case "", services.ArchiveZip:
+		return services.ArchiveZip, true
+	case services.ArchiveTarGz, "tgz":
+		return services.ArchiveTarGz, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrUnknownArchiveFormat.Error()})
+	return "", false
+}
+
+// streamArchive plans the archive of roots, so the caps are answered with
+// a proper status, and then writes it to the response as name.format.
+func streamArchive(c *gin.Context, archives *services.ArchiveService, roots []*models.Node, name, format string) {
+	a, err := archives.Plan(roots)
+	if err != nil {
+		if errors.Is(err, services.ErrArchiveTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType := "application/zip"
+	if format == services.ArchiveTarGz {
+		contentType = "application/gzip"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", contentDisposition(name+"."+format))
+	c.Status(http.StatusOK)
+	if err := archives.Write(c.Writer, a, format); err != nil {
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "")
+			c.Header("Content-Disposition", "")
+			if errors.Is(err, services.ErrBlobNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "file missing on server: " + err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// the status is sent already, all that is left is to cut the
+		// archive short
+		log.Printf("archive download for %s failed: %v", roots[0].OwnerID, err)
+		c.Abort()
+	}
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"server/internal/models"
+	"server/internal/repository"
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type batchOpReq struct {
+	Op       string `json:"op" binding:"required"` // move, delete, rename or copy
+	ID       string `json:"id" binding:"required"`
+	ParentID string `json:"parent_id,omitempty"` // move, copy: target folder, empty for the root
+	Name     string `json:"name,omitempty"`      // rename
+	Conflict string `json:"conflict,omitempty"`  // move: fail (default), rename, replace or skip
+}
+
+type batchReq struct {
+	Operations []batchOpReq `json:"operations" binding:"required,dive"`
+	Atomic     bool         `json:"atomic,omitempty"`
+}
+
+type BatchItemResult struct {
+	Op     string       `json:"op"`
+	ID     string       `json:"id"`
+	Status int          `json:"status"` // what the single item endpoint would have answered
+	Node   *models.Node `json:"node,omitempty"`
+	Job    *models.Job  `json:"job,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Results    []BatchItemResult `json:"results"`
+	Succeeded  int               `json:"succeeded"`
+	Failed     int               `json:"failed"`
+	RolledBack bool              `json:"rolled_back,omitempty"`
+}
+
+// batchErrorStatus maps the result of one operation of a batch.
+func batchErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrNodeNotFound), errors.Is(err, services.ErrParentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrParentNotFolder), errors.Is(err, services.ErrMoveIntoSelf),
+		errors.Is(err, services.ErrParentDeleted), errors.Is(err, services.ErrReplaceAncestor):
+		return http.StatusBadRequest
+	case errors.Is(err, repository.ErrNameTaken):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrBatchRolledBack):
+		return http.StatusFailedDependency
+	default:
+		return copyErrorStatus(err)
+	}
+}
+
+// @Summary Apply operations to many nodes
+// @Description Runs move, delete (to the trash), rename and copy operations in order and reports a result per operation; the response is 200 even if some of them failed. The nodes and target folders are checked once for the whole batch, including moves of a folder into its own descendant within the batch. With atomic the batch runs in a MongoDB transaction (which needs a replica set): if one operation fails nothing is applied, the others report 424 and rolled_back is set. Atomic batches cannot copy and only move with conflict=fail. A node may appear only once per batch.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Param payload body batchReq true "operations"
+// @Success 200 {object} controllers.BatchResponse
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string "too many operations"
+// @Failure 500 {object} map[string]string
+// @Failure 501 {object} map[string]string "atomic batches are not supported by the database"
+// @Security ApiKeyAuth
+// @Router /batch [post]
+func BatchHandler(batches *services.BatchService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req batchReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ops := make([]services.BatchOp, 0, len(req.Operations))
+		for i, r := range req.Operations {
+			op := services.BatchOp{Op: r.Op, ID: r.ID, ParentID: r.ParentID}
+			var err error
+			switch r.Op {
+			case services.BatchMove:
+				op.Conflict, err = services.ParseConflict(r.Conflict, services.ConflictFail)
+			case services.BatchRename:
+				op.Name, err = validateNodeName(r.Name)
+			case services.BatchDelete, services.BatchCopy:
+			default:
+				err = fmt.Errorf("unknown op %q", r.Op)
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("operations[%d]: %v", i, err)})
+				return
+			}
+			ops = append(ops, op)
+		}
+
+		uid, _ := c.Get("user_id")
+		results, err := batches.Run(uid.(string), ops, req.Atomic)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrBatchTooLarge):
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			case errors.Is(err, services.ErrBatchDuplicateID), errors.Is(err, services.ErrBatchAtomicCopy),
+				errors.Is(err, services.ErrBatchAtomicConflict):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, repository.ErrNoTransactions):
+				c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		resp := BatchResponse{Results: make([]BatchItemResult, len(results))}
+		for i, r := range results {
+			item := BatchItemResult{Op: ops[i].Op, ID: ops[i].ID, Node: r.Node, Job: r.Job}
+			switch {
+			case r.Err != nil:
+				item.Status = batchErrorStatus(r.Err)
+				item.Error = r.Err.Error()
+				resp.Failed++
+			case r.Job != nil:
+				item.Status = http.StatusAccepted
+			case ops[i].Op == services.BatchCopy:
+				item.Status = http.StatusCreated
+			case ops[i].Op == services.BatchDelete:
+				item.Status = http.StatusNoContent
+			default:
+				item.Status = http.StatusOK
+			}
+			if r.Err == nil {
+				resp.Succeeded++
+			}
+			resp.Results[i] = item
+		}
+		resp.RolledBack = req.Atomic && resp.Failed > 0
+		c.JSON(http.StatusOK, resp)
+	}
+}
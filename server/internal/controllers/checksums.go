@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"server/internal/models"
+)
+
+// requestChecksums reads the checksums a client announced for the uploaded
+// content from the Digest (RFC 3230) and Content-MD5 headers. Unknown
+// algorithms are ignored; nil means nothing was announced.
+func requestChecksums(h http.Header) (*models.Checksums, error) {
+	want := &models.Checksums{}
+	sizes := map[string]int{"sha-256": 32, "md5": 16, "crc32c": 4}
+	set := func(alg, value string) error {
+		raw, err := base64.StdEncoding.DecodeString(strings.Trim(strings.TrimSpace(value), ":"))
+		if err != nil || len(raw) != sizes[alg] {
+			return errors.New("invalid " + alg + " checksum")
+		}
+		sum := hex.EncodeToString(raw)
+		var field *string
+		switch alg {
+		case "sha-256":
+			field = &want.SHA256
+		case "md5":
+			field = &want.MD5
+		case "crc32c":
+			field = &want.CRC32C
+		}
+		if *field != "" && *field != sum {
+			return errors.New("conflicting " + alg + " checksums")
+		}
+		*field = sum
+		return nil
+	}
+
+	for _, entry := range strings.Split(h.Get("Digest"), ",") {
+		alg, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		alg = strings.ToLower(alg)
+		if _, known := sizes[alg]; !known {
+			continue
+		}
+		if err := set(alg, value); err != nil {
+			return nil, err
+		}
+	}
+	if v := h.Get("Content-MD5"); v != "" {
+		if err := set("md5", v); err != nil {
+			return nil, err
+		}
+	}
+	if *want == (models.Checksums{}) {
+		return nil, nil
+	}
+	return want, nil
+}
+
+// setDigestHeaders announces the stored SHA-256 of a file as strong ETag and,
+// for complete responses, as Content-Digest (RFC 9530).
+func setDigestHeaders(w http.ResponseWriter, r *http.Request, n *models.Node) {
+	if n.Digest == "" {
+		return
+	}
+	w.Header().Set("ETag", `"`+n.Digest+`"`)
+	raw, err := hex.DecodeString(n.Digest)
+	if err != nil || r.Header.Get("Range") != "" {
+		return
+	}
+	w.Header().Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(raw)+":")
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"server/internal/repository"
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type copyReq struct {
+	ParentID string `json:"parent_id"`
+}
+
+// @Summary Copy node (file or folder) into a folder (or root)
+// @Description Folders are copied with everything below them. A name already used in the target folder gets a " (n)" suffix. Large trees are counted and copied in the background: the response is then 202 with the job to poll at /jobs/{id}, which fails if the tree is too large or exceeds the quota. Jobs running when the server restarts are marked failed.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Param id path string true "node id to copy"
+// @Param payload body copyReq true "target parent id (empty string for root)"
+// @Success 201 {object} models.Node
+// @Success 202 {object} models.Job
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 507 {object} map[string]string "storage quota exceeded"
+// @Security ApiKeyAuth
+// @Router /copy/{id} [post]
+func CopyHandler(fileRepo repository.FileRepository, copies *services.CopyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var req copyReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		uid, _ := c.Get("user_id")
+		ownerID := uid.(string)
+
+		node, err := fileRepo.FindNodeByID(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if node == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+			return
+		}
+		if node.OwnerID != ownerID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+
+		if req.ParentID != "" {
+			parentNode, err := fileRepo.FindNodeByID(req.ParentID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if parentNode == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "target parent not found"})
+				return
+			}
+			if parentNode.OwnerID != ownerID {
+				c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+				return
+			}
+			if parentNode.Type != "folder" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "target parent is not a folder"})
+				return
+			}
+		}
+
+		copied, job, err := copies.Copy(node, req.ParentID)
+		if err != nil {
+			if errors.Is(err, services.ErrBlobNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "file missing on server"})
+				return
+			}
+			c.JSON(copyErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if job != nil {
+			c.Header("Location", "/jobs/"+job.ID)
+			c.JSON(http.StatusAccepted, job)
+			return
+		}
+		c.JSON(http.StatusCreated, copied)
+	}
+}
+
+func copyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrCopyIntoSelf):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrCopyTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, services.ErrBlobNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// @Summary Get background job
+// @Tags files
+// @Produce json
+// @Param id path string true "job id"
+// @Success 200 {object} models.Job
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /jobs/{id} [get]
+func JobHandler(copies *services.CopyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		ownerID := uid.(string)
+
+		job, err := copies.Job(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if job == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+			return
+		}
+		if job.OwnerID != ownerID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
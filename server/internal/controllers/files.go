@@ -1,10 +1,14 @@
 package controllers
 
 import (
+	"errors"
+	"io"
+	"mime"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"server/internal/models"
 	"server/internal/repository"
@@ -16,10 +20,16 @@ import (
 type createFolderReq struct {
 	Name     string `json:"name" binding:"required"`
 	ParentID string `json:"parent_id,omitempty"`
+	Conflict string `json:"conflict,omitempty"` // fail (default), rename, replace or skip
 }
 
 type moveReq struct {
 	ParentID string `json:"parent_id"`
+	Conflict string `json:"conflict,omitempty"` // fail (default), rename, replace or skip
+}
+
+type updateNodeReq struct {
+	Name *string `json:"name,omitempty"`
 }
 
 type FolderStat struct {
@@ -41,32 +51,98 @@ func sanitizeFilenameForHeader(name string) string {
 	return name
 }
 
+// contentDisposition builds an attachment header for name, using the
+// RFC 2231 form for names that are not plain ASCII.
+func contentDisposition(name string) string {
+	name = sanitizeFilenameForHeader(filepath.Base(name))
+	if v := mime.FormatMediaType("attachment", map[string]string{"filename": name}); v != "" {
+		return v
+	}
+	return `attachment; filename="` + name + `"`
+}
+
+const maxNodeNameLength = 255
+
+// validateNodeName trims name and rejects what cannot be a single path
+// element.
+func validateNodeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return "", errors.New("name is required")
+	case name == "." || name == "..":
+		return "", errors.New("invalid name")
+	case len(name) > maxNodeNameLength:
+		return "", errors.New("name too long")
+	case strings.ContainsAny(name, "/\\"):
+		return "", errors.New("name must not contain slashes")
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return "", errors.New("name must not contain control characters")
+		}
+	}
+	return name, nil
+}
+
+// nameErrorStatus maps the errors of placing a node in a folder.
+func nameErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrNameTaken):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrReplaceAncestor):
+		return http.StatusBadRequest
+	case errors.Is(err, repository.ErrNodeNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // @Summary Create folder
+// @Description conflict decides what happens when the parent already has a node of the same name (compared case-insensitively): fail (409), rename to "name (1)", replace (the existing folder goes to the trash) or skip (the existing folder is returned with 200).
 // @Tags files
 // @Accept json
 // @Produce json
 // @Param payload body createFolderReq true "name and optional parent"
+// @Success 200 {object} models.Node "skipped, the existing folder"
 // @Success 201 {object} models.Node
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string "a sibling already has the name"
 // @Security ApiKeyAuth
 // @Router /folders [post]
-func CreateFolderHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
+func CreateFolderHandler(nodes *services.NodeService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req createFolderReq
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		name, err := validateNodeName(req.Name)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		conflict, err := services.ParseConflict(req.Conflict, services.ConflictFail)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		uid, _ := c.Get("user_id")
 		ownerID := uid.(string)
 		node := &models.Node{
 			OwnerID:  ownerID,
 			ParentID: req.ParentID,
-			Name:     req.Name,
+			Name:     name,
 			Type:     "folder",
 		}
-		if err := fileRepo.CreateNode(node); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		node, created, err := nodes.Create(node, conflict)
+		if err != nil {
+			c.JSON(nameErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if !created {
+			c.JSON(http.StatusOK, node)
 			return
 		}
 		c.JSON(http.StatusCreated, node)
@@ -74,101 +150,390 @@ func CreateFolderHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
 }
 
 // @Summary List files/folders
+// @Description Children are sorted by name unless another order is asked for. With limit, a page is returned and the X-Next-Cursor header, if set, is the cursor of the next one. meta.<key>=<value> keeps the nodes whose metadata has the value for the key, e.g. meta.status=done; repeat it for any of several values.
 // @Tags files
 // @Produce json
 // @Param parent_id query string false "parent id (optional)"
+// @Param sort query string false "name (natural, default), size, type (MIME type), created or updated"
+// @Param order query string false "asc (default) or desc"
+// @Param folders_first query bool false "list folders before files"
+// @Param type query string false "only file or folder"
+// @Param mime query string false "comma separated MIME types, e.g. image/*,application/pdf"
+// @Param ext query string false "comma separated extensions, e.g. jpg,png"
+// @Param tags query string false "comma separated tag ids the nodes must all have"
+// @Param limit query int false "page size, at most 1000; all children if not set"
+// @Param cursor query string false "X-Next-Cursor of the previous page"
 // @Success 200 {array} models.Node
+// @Header 200 {string} X-Next-Cursor "cursor of the next page"
+// @Failure 400 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /files [get]
-func ListHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
+func ListHandler(fileRepo repository.FileRepository, recent *services.RecentService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		parentID := c.Query("parent_id")
-		uid, _ := c.Get("user_id")
-		ownerID := uid.(string)
-		nodes, err := fileRepo.ListChildren(ownerID, parentID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusOK, nodes)
+		listChildren(c, fileRepo, recent, c.Query("parent_id"))
 	}
 }
 
 // @Summary List files/folders under a folder
+// @Description Takes the same sorting, filtering and paging parameters as GET /files.
 // @Tags files
 // @Produce json
 // @Param parent_id path string true "parent folder id"
+// @Param sort query string false "name (natural, default), size, type (MIME type), created or updated"
+// @Param order query string false "asc (default) or desc"
+// @Param folders_first query bool false "list folders before files"
+// @Param type query string false "only file or folder"
+// @Param mime query string false "comma separated MIME types, e.g. image/*,application/pdf"
+// @Param ext query string false "comma separated extensions, e.g. jpg,png"
+// @Param tags query string false "comma separated tag ids the nodes must all have"
+// @Param limit query int false "page size, at most 1000; all children if not set"
+// @Param cursor query string false "X-Next-Cursor of the previous page"
 // @Success 200 {array} models.Node
+// @Header 200 {string} X-Next-Cursor "cursor of the next page"
+// @Failure 400 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /folders/{parent_id} [get]
-func FoldersListHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
+func FoldersListHandler(fileRepo repository.FileRepository, recent *services.RecentService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		parentID := c.Param("parent_id")
+		listChildren(c, fileRepo, recent, c.Param("parent_id"))
+	}
+}
 
-		uid, _ := c.Get("user_id")
-		ownerID := uid.(string)
+// listChildren answers a listing of parentID; listing the first page of a
+// folder counts as opening it, see services.RecentService.
+func listChildren(c *gin.Context, fileRepo repository.FileRepository, recent *services.RecentService, parentID string) {
+	uid, _ := c.Get("user_id")
+	ownerID := uid.(string)
 
-		nodes, err := fileRepo.ListChildren(ownerID, parentID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	filter, err := nodeFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := listOptions(c, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q := repository.ChildrenQuery{OwnerID: ownerID, ParentID: parentID, NodeFilter: filter, ListOptions: opts}
+
+	nodes, next, err := fileRepo.ListChildrenPage(q)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if nodes == nil {
+		nodes = []*models.Node{}
+	}
+	if parentID != "" && opts.Cursor == "" {
+		recent.Touch(ownerID, parentID, "folder", services.RecentOpen)
+	}
+	if next != "" {
+		c.Header("X-Next-Cursor", next)
+	}
+	c.JSON(http.StatusOK, nodes)
+}
 
-		c.JSON(http.StatusOK, nodes)
+// nodeFilter reads the type, mime, ext, tags and meta.<key> parameters of a
+// listing.
+func nodeFilter(c *gin.Context) (repository.NodeFilter, error) {
+	f := repository.NodeFilter{
+		Type:       c.Query("type"),
+		Mimes:      splitList(c.Query("mime")),
+		Extensions: splitList(c.Query("ext")),
+		Tags:       splitList(c.Query("tags")),
+	}
+	if f.Type != "" && f.Type != "file" && f.Type != "folder" {
+		return f, errors.New("type must be file or folder")
 	}
+	for name, values := range c.Request.URL.Query() {
+		key, ok := strings.CutPrefix(name, "meta.")
+		if !ok {
+			continue
+		}
+		if !repository.ValidMetadataKey(key) {
+			return f, errors.New("invalid metadata key " + strconv.Quote(key))
+		}
+		if f.Metadata == nil {
+			f.Metadata = map[string][]string{}
+		}
+		f.Metadata[key] = append(f.Metadata[key], values...)
+	}
+	return f, nil
+}
+
+// listOptions reads the sorting and paging parameters of a listing. Without
+// a limit parameter the page size is defaultLimit.
+func listOptions(c *gin.Context, defaultLimit int) (repository.ListOptions, error) {
+	o := repository.ListOptions{
+		Sort:   c.DefaultQuery("sort", repository.SortName),
+		Limit:  defaultLimit,
+		Cursor: c.Query("cursor"),
+	}
+	switch o.Sort {
+	case repository.SortName, repository.SortSize, repository.SortType, repository.SortCreated, repository.SortUpdated:
+	default:
+		return o, errors.New("sort must be name, size, type, created or updated")
+	}
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		o.Desc = true
+	default:
+		return o, errors.New("order must be asc or desc")
+	}
+	if v := c.Query("folders_first"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return o, errors.New("invalid folders_first")
+		}
+		o.FoldersFirst = b
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > repository.MaxPageSize {
+			return o, errors.New("limit must be between 1 and " + strconv.Itoa(repository.MaxPageSize))
+		}
+		o.Limit = n
+	}
+	return o, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
 }
 
 // @Summary Upload file
+// @Description The file part is streamed into storage while it is received, so form fields may come before or after it.
 // @Tags files
 // @Accept multipart/form-data
 // @Produce json
 // @Param parent_id formData string false "parent folder id"
+// @Param conflict formData string false "when the folder already has a file of the name: fail, rename, replace or skip; by default the file is overwritten if the owner keeps versions and renamed otherwise"
 // @Param file formData file true "file to upload"
+// @Param Digest header string false "expected checksums of the file, e.g. sha-256=<base64>, md5=<base64>, crc32c=<base64>"
+// @Param Content-MD5 header string false "expected MD5 of the file (base64)"
+// @Success 200 {object} models.Node "an existing file was overwritten, keeping its previous content as a version, or kept (skip)"
 // @Success 201 {object} models.Node
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string "a sibling already has the name"
+// @Failure 413 {object} map[string]string
+// @Failure 507 {object} map[string]string "storage quota exceeded"
 // @Security ApiKeyAuth
 // @Router /files/upload [post]
-func UploadHandler(fileRepo repository.FileRepository, storage *services.StorageService) gin.HandlerFunc {
+func UploadHandler(storage *services.StorageService, nodes *services.NodeService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		parentID := c.PostForm("parent_id")
 		uid, _ := c.Get("user_id")
 		ownerID := uid.(string)
 
-		fh, err := c.FormFile("file")
+		want, err := requestChecksums(c.Request.Header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := storage.CheckQuota(ownerID, 0, 1); err != nil {
+			c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if !limitUploadBody(c, storage.MaxSize) {
+			return
+		}
+		mr, err := c.Request.MultipartReader()
 		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "multipart/form-data body required"})
+			return
+		}
+
+		var (
+			parentID, name, mimeType, conflict string
+			blob                               *models.Blob
+		)
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if blob != nil {
+					_ = storage.DiscardIngested(ownerID, blob)
+				}
+				c.JSON(uploadErrorStatus(err), gin.H{"error": "malformed multipart body: " + err.Error()})
+				return
+			}
+			switch {
+			case part.FormName() == "file" && blob == nil:
+				if name, err = validateNodeName(part.FileName()); err != nil {
+					part.Close()
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				mimeType = part.Header.Get("Content-Type")
+				blob, err = storage.IngestFile(ownerID, part, storage.MaxSize, want)
+				if err != nil {
+					part.Close()
+					c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+					return
+				}
+			case part.FormName() == "parent_id":
+				v, _ := io.ReadAll(io.LimitReader(part, 1024))
+				parentID = strings.TrimSpace(string(v))
+			case part.FormName() == "conflict":
+				v, _ := io.ReadAll(io.LimitReader(part, 1024))
+				conflict = strings.TrimSpace(string(v))
+			}
+			part.Close()
+		}
+		if blob == nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "file required"})
 			return
 		}
-		savedPath, size, err := storage.SaveFile(ownerID, fh)
+		createUploadedNode(c, storage, nodes, blob, ownerID, parentID, name, mimeType, conflict)
+	}
+}
+
+// @Summary Upload file from the raw request body
+// @Description Same as the multipart upload for clients that can send the bytes as-is. The body is streamed into storage.
+// @Tags files
+// @Accept octet-stream
+// @Produce json
+// @Param name query string true "file name"
+// @Param parent_id query string false "parent folder id"
+// @Param conflict query string false "when the folder already has a file of the name: fail, rename, replace or skip; by default the file is overwritten if the owner keeps versions and renamed otherwise"
+// @Param Digest header string false "expected checksums of the file, e.g. sha-256=<base64>, md5=<base64>, crc32c=<base64>"
+// @Param Content-MD5 header string false "expected MD5 of the file (base64)"
+// @Success 200 {object} models.Node "an existing file was overwritten, keeping its previous content as a version, or kept (skip)"
+// @Success 201 {object} models.Node
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string "a sibling already has the name"
+// @Failure 413 {object} map[string]string
+// @Failure 507 {object} map[string]string "storage quota exceeded"
+// @Security ApiKeyAuth
+// @Router /files/upload [put]
+func UploadRawHandler(storage *services.StorageService, nodes *services.NodeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		ownerID := uid.(string)
+
+		name, err := validateNodeName(c.Query("name"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := services.ParseConflict(c.Query("conflict"), ""); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		want, err := requestChecksums(c.Request.Header)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		node := &models.Node{
-			OwnerID:  ownerID,
-			ParentID: parentID,
-			Name:     fh.Filename,
-			Type:     "file",
-			Size:     size,
-			Path:     savedPath,
-			Mime:     fh.Header.Get("Content-Type"),
-		}
-		if err := fileRepo.CreateNode(node); err != nil {
-			_ = storage.DeleteFile(savedPath)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := storage.CheckQuota(ownerID, max(c.Request.ContentLength, 0), 1); err != nil {
+			c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusCreated, node)
+		if !limitUploadBody(c, storage.MaxSize) {
+			return
+		}
+		blob, err := storage.IngestFile(ownerID, c.Request.Body, storage.MaxSize, want)
+		if err != nil {
+			c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		mimeType := c.ContentType()
+		if mimeType == "" || mimeType == "application/octet-stream" {
+			if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(name))); byExt != "" {
+				mimeType = byExt
+			}
+		}
+		createUploadedNode(c, storage, nodes, blob, ownerID, c.Query("parent_id"), name, mimeType, c.Query("conflict"))
+	}
+}
+
+// limitUploadBody rejects bodies that announce more than maxSize and caps
+// the rest, leaving some room for multipart framing and form fields.
+func limitUploadBody(c *gin.Context, maxSize int64) bool {
+	if maxSize <= 0 {
+		return true
+	}
+	limit := maxSize + 1<<20
+	if c.Request.ContentLength > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+		return false
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	return true
+}
+
+func uploadErrorStatus(err error) int {
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.Is(err, services.ErrTooLarge), errors.As(err, &maxBytes):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, services.ErrChecksumMismatch):
+		return http.StatusBadRequest
+	case errors.Is(err, repository.ErrNameTaken):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
 	}
 }
 
+// createUploadedNode creates the node for an uploaded blob, resolving a name
+// clash with conflict, see NodeService.CreateFile.
+func createUploadedNode(c *gin.Context, storage *services.StorageService, nodes *services.NodeService, blob *models.Blob, ownerID, parentID, name, mimeType, conflict string) {
+	conflict, err := services.ParseConflict(conflict, "")
+	if err != nil {
+		_ = storage.DiscardIngested(ownerID, blob)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	node, created, err := nodes.CreateFile(&models.Node{
+		OwnerID:  ownerID,
+		ParentID: parentID,
+		Name:     name,
+		Type:     "file",
+		Size:     blob.Size,
+		Path:     blob.Key,
+		Digest:   blob.Digest,
+		MD5:      blob.MD5,
+		CRC32C:   blob.CRC32C,
+		BlobID:   blob.ID,
+		Mime:     mimeType,
+	}, blob, conflict)
+	if err != nil {
+		c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if !created {
+		c.JSON(http.StatusOK, node)
+		return
+	}
+	c.JSON(http.StatusCreated, node)
+}
+
 // @Summary Download file
 // @Tags files
 // @Produce octet-stream
 // @Param id path string true "file id"
 // @Success 200 {file} file
+// @Header 200 {string} ETag "quoted sha-256 hex digest"
+// @Header 200 {string} Content-Digest "sha-256=:<base64>:"
 // @Security ApiKeyAuth
 // @Router /files/{id}/download [get]
-func DownloadHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
+func DownloadHandler(fileRepo repository.FileRepository, storage *services.StorageService, recent *services.RecentService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		uid, _ := c.Get("user_id")
@@ -186,22 +551,34 @@ func DownloadHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "not a file"})
 			return
 		}
-		if _, err := os.Stat(node.Path); err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "file missing on server"})
+		blob, err := storage.OpenFile(node)
+		if err != nil {
+			if errors.Is(err, services.ErrBlobNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "file missing on server"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		c.Header("Content-Disposition", `attachment; filename="`+sanitizeFilenameForHeader(filepath.Base(node.Name))+`"`)
-		c.File(node.Path)
+		defer blob.Close()
+		recent.Touch(ownerID, node.ID, node.Type, services.RecentDownload)
+		if node.Mime != "" {
+			c.Header("Content-Type", node.Mime)
+		}
+		c.Header("Content-Disposition", contentDisposition(node.Name))
+		setDigestHeaders(c.Writer, c.Request, node)
+		http.ServeContent(c.Writer, c.Request, node.Name, blob.Info().ModTime, blob)
 	}
 }
 
 // @Summary Delete node (file or folder)
+// @Description The node and everything below it move to the trash, see /trash.
 // @Tags files
 // @Param id path string true "node id"
 // @Success 204
 // @Security ApiKeyAuth
 // @Router /files/{id} [delete]
-func DeleteHandler(fileRepo repository.FileRepository, storage *services.StorageService) gin.HandlerFunc {
+func DeleteHandler(fileRepo repository.FileRepository, trash *services.TrashService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		uid, _ := c.Get("user_id")
@@ -215,20 +592,10 @@ func DeleteHandler(fileRepo repository.FileRepository, storage *services.Storage
 			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
 			return
 		}
-		if node.Type == "folder" {
-			children, _ := fileRepo.ListChildren(ownerID, id)
-			for _, ch := range children {
-				if ch.Type == "file" {
-					_ = storage.DeleteFile(ch.Path)
-				} else {
-					_ = DeleteRecursive(fileRepo, storage, ch)
-				}
-				_ = fileRepo.DeleteNode(ch.ID)
-			}
-		} else if node.Type == "file" {
-			_ = storage.DeleteFile(node.Path)
+		if err := trash.Trash(node); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
-		_ = fileRepo.DeleteNode(id)
 		c.Status(http.StatusNoContent)
 	}
 }
@@ -391,6 +758,7 @@ func FolderStatsHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
 }
 
 // @Summary Move node (file or folder) to another parent (or root)
+// @Description conflict decides what happens when the target already has a node of the same name: fail (409), rename to "name (1)", replace (the existing node goes to the trash) or skip (the node stays where it is).
 // @Tags files
 // @Accept json
 // @Produce json
@@ -400,10 +768,11 @@ func FolderStatsHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
 // @Failure 400 {object} map[string]string
 // @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string "a node in the target already has the name"
 // @Failure 500 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /move/{id} [post]
-func MoveHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
+func MoveHandler(fileRepo repository.FileRepository, nodes *services.NodeService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		var req moveReq
@@ -411,6 +780,11 @@ func MoveHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		conflict, err := services.ParseConflict(req.Conflict, services.ConflictFail)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		uid, _ := c.Get("user_id")
 		ownerID := uid.(string)
@@ -470,14 +844,77 @@ func MoveHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
 			}
 		}
 
-		node.ParentID = newParentID
+		if _, err := nodes.Move(node, newParentID, conflict); err != nil {
+			c.JSON(nameErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
 
-		if err := fileRepo.UpdateNodeParent(ownerID, node.ID, newParentID); err != nil {
+		c.JSON(http.StatusOK, node)
+	}
+}
+
+// @Summary Update node (file or folder)
+// @Description Renames a node. Only the fields present in the body are changed; the stored content is not touched.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Param id path string true "node id"
+// @Param payload body updateNodeReq true "fields to change"
+// @Success 200 {object} models.Node
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string "a sibling already has the name"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /files/{id} [patch]
+func UpdateNodeHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var req updateNodeReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		uid, _ := c.Get("user_id")
+		ownerID := uid.(string)
+
+		node, err := fileRepo.FindNodeByID(id)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		if node == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+			return
+		}
+		if node.OwnerID != ownerID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
 
-		node.ParentID = newParentID
+		if req.Name == nil {
+			c.JSON(http.StatusOK, node)
+			return
+		}
+		name, err := validateNodeName(*req.Name)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if name == node.Name {
+			c.JSON(http.StatusOK, node)
+			return
+		}
+
+		if err := fileRepo.RenameNode(ownerID, node.ID, name); err != nil {
+			c.JSON(nameErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		node.Name = name
+		node.UpdatedAt = time.Now()
 		c.JSON(http.StatusOK, node)
 	}
 }
@@ -511,17 +948,3 @@ func collectNodesRecursive(fileRepo repository.FileRepository, ownerID, parentID
 	}
 	return result, nil
 }
-
-func DeleteRecursive(fileRepo repository.FileRepository, storage *services.StorageService, node *models.Node) error {
-	children, _ := fileRepo.ListChildren(node.OwnerID, node.ID)
-	for _, ch := range children {
-		if ch.Type == "file" {
-			_ = storage.DeleteFile(ch.Path)
-			_ = fileRepo.DeleteNode(ch.ID)
-		} else {
-			_ = DeleteRecursive(fileRepo, storage, ch)
-			_ = fileRepo.DeleteNode(ch.ID)
-		}
-	}
-	return nil
-}
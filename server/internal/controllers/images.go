@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"server/internal/repository"
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get resized or converted image
+// @Description Returns a JPEG, PNG or GIF file resized, cropped and converted to JPEG or PNG, turned upright by its EXIF orientation. fit is how the image fills w x h: contain keeps the aspect ratio inside it, cover crops the middle of the image and fill stretches it; with only w or h the aspect ratio is kept. Images are never enlarged, and at most 4096 pixels wide and high. Renditions are cached, so repeated requests are served without decoding the image again.
+// @Tags files
+// @Produce jpeg
+// @Produce png
+// @Param id path string true "file id"
+// @Param w query int false "width in pixels, up to 4096"
+// @Param h query int false "height in pixels, up to 4096"
+// @Param fit query string false "contain (default), cover or fill"
+// @Param format query string false "jpeg or png; png for PNG files and jpeg for others by default"
+// @Param quality query int false "JPEG quality from 1 to 100, 85 by default"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "not found or not an image"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /files/{id}/image [get]
+func ImageHandler(fileRepo repository.FileRepository, images *services.ImageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts := services.ImageOptions{Fit: c.Query("fit"), Format: c.Query("format")}
+		for _, p := range []struct {
+			name string
+			dst  *int
+		}{{"w", &opts.Width}, {"h", &opts.Height}, {"quality", &opts.Quality}} {
+			v := c.Query(p.name)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": p.name + " must be a number"})
+				return
+			}
+			*p.dst = n
+		}
+		uid, _ := c.Get("user_id")
+		node, err := fileRepo.FindNodeByID(c.Param("id"))
+		if err != nil || node == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		if node.OwnerID != uid.(string) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+
+		img, opts, err := images.Open(node, opts)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidImageOptions):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, services.ErrNotImage):
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			case errors.Is(err, services.ErrBlobNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "file missing on server"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+		defer img.Close()
+		c.Header("Content-Type", opts.ContentType())
+		c.Header("Cache-Control", "private, max-age=86400")
+		c.Header("ETag", `"`+node.Digest+"-"+opts.Name()+`"`)
+		http.ServeContent(c.Writer, c.Request, "", img.Info().ModTime, img)
+	}
+}
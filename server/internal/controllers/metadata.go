@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type replaceMetadataReq struct {
+	Description string                 `json:"description"`
+	Metadata    map[string]interface{} `json:"metadata"` // values are strings, numbers or booleans
+}
+
+type patchMetadataReq struct {
+	Description *string `json:"description,omitempty"` // "" removes the description
+	// keys to add or change, null removes a key
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type defineMetadataKeyReq struct {
+	Type string `json:"type" binding:"required"` // string, number or boolean
+}
+
+// metadataErrorStatus maps the errors of services.MetadataService.
+func metadataErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrNodeNotFound), errors.Is(err, services.ErrMetadataKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidMetadata):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrNodeChanged), errors.Is(err, services.ErrMetadataKeyInUse):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// @Summary Replace description and metadata
+// @Description Sets the description and the metadata of a file or folder, dropping keys not in the body. Keys are 1 to 64 letters, digits, _ or -; values are strings of at most 1024 characters, numbers or booleans, of the type the key is defined with, see /metadata/keys. Keys not defined yet are defined by the type of their first value. A node has at most 50 keys and 16 KiB of metadata; descriptions have at most 4000 characters.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Param id path string true "node id"
+// @Param payload body replaceMetadataReq true "description and metadata"
+// @Success 200 {object} models.Node
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string "the node kept changing concurrently"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /nodes/{id}/metadata [put]
+func ReplaceMetadataHandler(metadata *services.MetadataService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req replaceMetadataReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		uid, _ := c.Get("user_id")
+		node, err := metadata.Update(uid.(string), c.Param("id"), services.MetadataUpdate{
+			Description: &req.Description,
+			Set:         req.Metadata,
+			Replace:     true,
+		})
+		if err != nil {
+			c.JSON(metadataErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, node)
+	}
+}
+
+// @Summary Update description and metadata
+// @Description Changes only what is in the body: the description if present and the metadata keys listed, removing those set to null. The limits of PUT apply.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Param id path string true "node id"
+// @Param payload body patchMetadataReq true "changes"
+// @Success 200 {object} models.Node
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string "the node kept changing concurrently"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /nodes/{id}/metadata [patch]
+func PatchMetadataHandler(metadata *services.MetadataService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req patchMetadataReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		uid, _ := c.Get("user_id")
+		node, err := metadata.Update(uid.(string), c.Param("id"), services.MetadataUpdate{
+			Description: req.Description,
+			Set:         req.Metadata,
+		})
+		if err != nil {
+			c.JSON(metadataErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, node)
+	}
+}
+
+// @Summary Remove a metadata key
+// @Tags files
+// @Produce json
+// @Param id path string true "node id"
+// @Param key path string true "metadata key"
+// @Success 200 {object} models.Node
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string "the node kept changing concurrently"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /nodes/{id}/metadata/{key} [delete]
+func DeleteMetadataKeyHandler(metadata *services.MetadataService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		node, err := metadata.Update(uid.(string), c.Param("id"), services.MetadataUpdate{
+			Set: map[string]interface{}{c.Param("key"): nil},
+		})
+		if err != nil {
+			c.JSON(metadataErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, node)
+	}
+}
+
+// @Summary List metadata keys
+// @Description Returns the user's metadata keys with the type of their values.
+// @Tags files
+// @Produce json
+// @Success 200 {array} models.MetadataKey
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /metadata/keys [get]
+func ListMetadataKeysHandler(metadata *services.MetadataService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		keys, err := metadata.ListKeys(uid.(string))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, keys)
+	}
+}
+
+// @Summary Define a metadata key
+// @Description Declares the type of the values of a metadata key: string, number or boolean. Values of other types are rejected from then on. The type of a defined key can be changed while no node has a value of another type for it.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Param key path string true "metadata key"
+// @Param payload body defineMetadataKeyReq true "type"
+// @Success 200 {object} models.MetadataKey
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string "nodes have values of another type"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /metadata/keys/{key} [put]
+func DefineMetadataKeyHandler(metadata *services.MetadataService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req defineMetadataKeyReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		uid, _ := c.Get("user_id")
+		key, err := metadata.DefineKey(uid.(string), c.Param("key"), req.Type)
+		if err != nil {
+			c.JSON(metadataErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, key)
+	}
+}
+
+// @Summary Delete a metadata key definition
+// @Description Only keys no node has a value for, in the trash or not, can be deleted.
+// @Tags files
+// @Param key path string true "metadata key"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string "nodes have values for the key"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /metadata/keys/{key} [delete]
+func DeleteMetadataKeyDefinitionHandler(metadata *services.MetadataService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		if err := metadata.DeleteKey(uid.(string), c.Param("key")); err != nil {
+			c.JSON(metadataErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"server/internal/models"
+	"server/internal/repository"
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NodeDetailsResponse struct {
+	Node *models.Node `json:"node"`
+	// folders above the node, the top level one first: the breadcrumbs
+	Ancestors    []*models.Node `json:"ancestors"`
+	Path         string         `json:"path"` // e.g. "/Documents/2024/report.pdf"
+	ChildFolders int            `json:"child_folders"`
+	ChildFiles   int            `json:"child_files"`
+	TotalSize    int64          `json:"total_size"`  // folders: bytes of every file below
+	TotalFiles   int            `json:"total_files"` // folders: files below, at any depth
+	// set when the folder has too many folders below it to sum them all
+	Partial bool `json:"partial,omitempty"`
+}
+
+// @Summary Get node with its ancestors
+// @Description Returns the node, the folders above it (for breadcrumbs) and its path. For folders also the number of direct child folders and files and the size and number of all files below; partial is set when the folder has more than 10000 folders below it and the totals only cover the files in the nearest 10000. The node goes to the top of GET /recent, as previewed file or opened folder.
+// @Tags files
+// @Produce json
+// @Param id path string true "node id"
+// @Success 200 {object} controllers.NodeDetailsResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /nodes/{id} [get]
+func NodeDetailsHandler(fileRepo repository.FileRepository, nodes *services.NodeService, recent *services.RecentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		ownerID := uid.(string)
+
+		node, err := fileRepo.FindNodeByID(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if node == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		if node.OwnerID != ownerID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		action := services.RecentPreview
+		if node.Type == "folder" {
+			action = services.RecentOpen
+		}
+		if node.TrashedAt == nil {
+			recent.Touch(ownerID, node.ID, node.Type, action)
+		}
+		respondNodeDetails(c, nodes, node)
+	}
+}
+
+// @Summary Resolve a path to a node
+// @Description Looks up the node at a slash separated path from the root, e.g. /Documents/2024/report.pdf, and returns it like /nodes/{id}. Names are compared ignoring case, as sibling names are unique that way.
+// @Tags files
+// @Produce json
+// @Param path query string true "path of the node"
+// @Success 200 {object} controllers.NodeDetailsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /resolve [get]
+func ResolveHandler(nodes *services.NodeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		ownerID := uid.(string)
+
+		path := c.Query("path")
+		if path == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "path required"})
+			return
+		}
+		node, err := nodes.Resolve(ownerID, path)
+		if err != nil {
+			if errors.Is(err, services.ErrNodeNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		respondNodeDetails(c, nodes, node)
+	}
+}
+
+func respondNodeDetails(c *gin.Context, nodes *services.NodeService, node *models.Node) {
+	d, err := nodes.Details(node)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ancestors := d.Ancestors
+	if ancestors == nil {
+		ancestors = []*models.Node{}
+	}
+	c.JSON(http.StatusOK, NodeDetailsResponse{
+		Node:         node,
+		Ancestors:    ancestors,
+		Path:         d.Path,
+		ChildFolders: d.ChildFolders,
+		ChildFiles:   d.ChildFiles,
+		TotalSize:    d.TotalSize,
+		TotalFiles:   d.TotalFiles,
+		Partial:      d.Partial,
+	})
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"server/internal/models"
+	"server/internal/repository"
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	recentDefaultLimit = 50
+	recentMaxLimit     = 200
+)
+
+type RecentResult struct {
+	Node       *models.Node `json:"node"`
+	Path       string       `json:"path"`
+	Action     string       `json:"action"` // the last one: upload, download, preview or open
+	AccessedAt time.Time    `json:"accessed_at"`
+}
+
+// @Summary List recently used nodes
+// @Description Returns the files and folders the user uploaded, downloaded, previewed (GET /nodes/{id}) or opened (listed) last, most recent first. Every node is listed once, at the time it was last used. Nodes in the trash are left out, so a page may have fewer results than limit; X-Next-Cursor, if set, is the cursor of the next page. Nodes not used for 90 days drop off the list.
+// @Tags files
+// @Produce json
+// @Param type query string false "only file or folder"
+// @Param limit query int false "page size, at most 200 (default 50)"
+// @Param cursor query string false "X-Next-Cursor of the previous page"
+// @Success 200 {array} controllers.RecentResult
+// @Header 200 {string} X-Next-Cursor "cursor of the next page"
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /recent [get]
+func ListRecentHandler(recent *services.RecentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+
+		nodeType := c.Query("type")
+		if nodeType != "" && nodeType != "file" && nodeType != "folder" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "type must be file or folder"})
+			return
+		}
+		limit := recentDefaultLimit
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > recentMaxLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(recentMaxLimit)})
+				return
+			}
+			limit = n
+		}
+
+		items, next, err := recent.List(uid.(string), nodeType, c.Query("cursor"), limit)
+		if err != nil {
+			if errors.Is(err, repository.ErrInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		out := make([]RecentResult, len(items))
+		for i, it := range items {
+			out[i] = RecentResult{Node: it.Node, Path: it.Path, Action: it.Action, AccessedAt: it.AccessedAt}
+		}
+		if next != "" {
+			c.Header("X-Next-Cursor", next)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"server/internal/models"
+	"server/internal/repository"
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchDefaultLimit is the page size of a search without a limit
+// parameter.
+const searchDefaultLimit = 100
+
+type SearchResult struct {
+	Node *models.Node `json:"node"`
+	Path string       `json:"path"` // e.g. "/Documents/2024/report.pdf"
+}
+
+// @Summary Search files and folders
+// @Description Finds the user's files and folders by name and other properties, anywhere or below one folder. Names match ignoring case, also in the middle of Japanese names. Results are sorted and paged like GET /files, 100 at a time unless limit is set; X-Next-Cursor, if set, is the cursor of the next page. Metadata filters meta.<key>=<value> work as for GET /files.
+// @Tags files
+// @Produce json
+// @Param q query string false "part of the name"
+// @Param prefix query bool false "match names starting with q only"
+// @Param starred query bool false "only starred nodes"
+// @Param in query string false "folder id: search only below this folder, at any depth; 400 if it has more than 10000 folders below it"
+// @Param type query string false "only file or folder"
+// @Param mime query string false "comma separated MIME types, e.g. image/*,application/pdf"
+// @Param ext query string false "comma separated extensions, e.g. jpg,png"
+// @Param tags query string false "comma separated tag ids the nodes must all have"
+// @Param min_size query int false "smallest file size in bytes"
+// @Param max_size query int false "largest file size in bytes"
+// @Param from query string false "updated at or after, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "updated before, RFC 3339 or YYYY-MM-DD (that day included)"
+// @Param sort query string false "name (natural, default), size, type (MIME type), created or updated"
+// @Param order query string false "asc (default) or desc"
+// @Param folders_first query bool false "list folders before files"
+// @Param limit query int false "page size, at most 1000"
+// @Param cursor query string false "X-Next-Cursor of the previous page"
+// @Success 200 {array} controllers.SearchResult
+// @Header 200 {string} X-Next-Cursor "cursor of the next page"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string "folder not found"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /search [get]
+func SearchHandler(nodes *services.NodeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		ownerID := uid.(string)
+
+		q, err := searchQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		q.OwnerID = ownerID
+
+		results, next, err := nodes.Search(q, c.Query("in"))
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrNodeNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "folder not found"})
+			case errors.Is(err, repository.ErrInvalidCursor), errors.Is(err, services.ErrTooManyFolders):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+		out := make([]SearchResult, len(results))
+		for i, r := range results {
+			out[i] = SearchResult{Node: r.Node, Path: r.Path}
+		}
+		if next != "" {
+			c.Header("X-Next-Cursor", next)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// respondSearch answers with the page of nodes matching q and their paths.
+func respondSearch(c *gin.Context, nodes *services.NodeService, q repository.SearchQuery) {
+	results, next, err := nodes.Search(q, "")
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]SearchResult, len(results))
+	for i, r := range results {
+		out[i] = SearchResult{Node: r.Node, Path: r.Path}
+	}
+	if next != "" {
+		c.Header("X-Next-Cursor", next)
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func searchQuery(c *gin.Context) (repository.SearchQuery, error) {
+	q := repository.SearchQuery{Name: c.Query("q")}
+	var err error
+	if q.NodeFilter, err = nodeFilter(c); err != nil {
+		return q, err
+	}
+	if q.ListOptions, err = listOptions(c, searchDefaultLimit); err != nil {
+		return q, err
+	}
+	if v := c.Query("prefix"); v != "" {
+		if q.Prefix, err = strconv.ParseBool(v); err != nil {
+			return q, errors.New("invalid prefix")
+		}
+	}
+	if v := c.Query("starred"); v != "" {
+		if q.Starred, err = strconv.ParseBool(v); err != nil {
+			return q, errors.New("invalid starred")
+		}
+	}
+	if q.MinSize, err = sizeParam(c, "min_size"); err != nil {
+		return q, err
+	}
+	if q.MaxSize, err = sizeParam(c, "max_size"); err != nil {
+		return q, err
+	}
+	if q.UpdatedAfter, err = timeParam(c, "from", false); err != nil {
+		return q, err
+	}
+	if q.UpdatedBefore, err = timeParam(c, "to", true); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+func sizeParam(c *gin.Context, name string) (int64, error) {
+	v := c.Query(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid " + name)
+	}
+	return n, nil
+}
+
+// timeParam reads an RFC 3339 time or a date. With endOfDay a date stands
+// for the start of the next day, so that the day is included.
+func timeParam(c *gin.Context, name string, endOfDay bool) (time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, errors.New("invalid " + name + ", want RFC 3339 or YYYY-MM-DD")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+const (
+	contentSearchDefaultLimit = 20
+	contentSearchMaxLimit     = 100
+)
+
+type ContentSearchResult struct {
+	Node *models.Node `json:"node"`
+	Path string       `json:"path"`
+	// the text around the first match, with the matches marked
+	Snippet []services.SnippetPart `json:"snippet"`
+}
+
+// @Summary Search inside files
+// @Description Finds the user's text files, source code and Word, Excel and PowerPoint (docx, xlsx, pptx) documents containing every word of q, ignoring case. Japanese text is matched without spaces between words. Files are indexed shortly after they are uploaded, so a new file may not be found right away. Results are the most recently updated files first. Only the 1000 most recently indexed contents with the words are looked into, so files whose content was indexed long ago may be left out for common words.
+// @Tags files
+// @Produce json
+// @Param q query string true "words to look for"
+// @Param limit query int false "number of results, at most 100 (default 20)"
+// @Success 200 {array} controllers.ContentSearchResult
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /search/content [get]
+func ContentSearchHandler(content *services.ContentIndexService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		ownerID := uid.(string)
+
+		limit := contentSearchDefaultLimit
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > contentSearchMaxLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(contentSearchMaxLimit)})
+				return
+			}
+			limit = n
+		}
+		results, err := content.Search(ownerID, c.Query("q"), limit)
+		if err != nil {
+			if errors.Is(err, services.ErrEmptyQuery) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		out := make([]ContentSearchResult, len(results))
+		for i, r := range results {
+			out[i] = ContentSearchResult{Node: r.Node, Path: r.Path, Snippet: r.Snippet}
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"net/http"
+
+	"server/internal/repository"
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Star node
+// @Description Stars a file or folder of the user, see GET /starred.
+// @Tags files
+// @Produce json
+// @Param id path string true "node id"
+// @Success 200 {object} models.Node
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /nodes/{id}/star [put]
+func StarHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setStarred(c, fileRepo, true)
+	}
+}
+
+// @Summary Unstar node
+// @Tags files
+// @Produce json
+// @Param id path string true "node id"
+// @Success 200 {object} models.Node
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /nodes/{id}/star [delete]
+func UnstarHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setStarred(c, fileRepo, false)
+	}
+}
+
+func setStarred(c *gin.Context, fileRepo repository.FileRepository, starred bool) {
+	uid, _ := c.Get("user_id")
+	found, err := fileRepo.SetStarred(uid.(string), c.Param("id"), starred)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	node, err := fileRepo.FindNodeByID(c.Param("id"))
+	if err != nil || node == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load node"})
+		return
+	}
+	c.JSON(http.StatusOK, node)
+}
+
+// @Summary List starred nodes
+// @Description Returns the user's starred files and folders, in any folder, with their paths. Results are filtered, sorted and paged like GET /search.
+// @Tags files
+// @Produce json
+// @Param type query string false "only file or folder"
+// @Param mime query string false "comma separated MIME types, e.g. image/*,application/pdf"
+// @Param ext query string false "comma separated extensions, e.g. jpg,png"
+// @Param tags query string false "comma separated tag ids the nodes must all have"
+// @Param sort query string false "name (natural, default), size, type (MIME type), created or updated"
+// @Param order query string false "asc (default) or desc"
+// @Param folders_first query bool false "list folders before files"
+// @Param limit query int false "page size, at most 1000"
+// @Param cursor query string false "X-Next-Cursor of the previous page"
+// @Success 200 {array} controllers.SearchResult
+// @Header 200 {string} X-Next-Cursor "cursor of the next page"
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /starred [get]
+func ListStarredHandler(nodes *services.NodeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		q := repository.SearchQuery{OwnerID: uid.(string), Starred: true}
+		var err error
+		if q.NodeFilter, err = nodeFilter(c); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if q.ListOptions, err = listOptions(c, searchDefaultLimit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		respondSearch(c, nodes, q)
+	}
+}
@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"server/internal/models"
+	"server/internal/repository"
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createTagReq struct {
+	Name  string `json:"name" binding:"required"`
+	Color string `json:"color,omitempty"` // "#rrggbb", gray if empty
+}
+
+type updateTagReq struct {
+	Name  *string `json:"name,omitempty"`
+	Color *string `json:"color,omitempty"`
+}
+
+type mergeTagReq struct {
+	Into string `json:"into" binding:"required"` // id of the tag to keep
+}
+
+type tagNodesReq struct {
+	NodeIDs []string `json:"node_ids" binding:"required,min=1"`
+	Add     []string `json:"add,omitempty"`    // tag ids
+	Remove  []string `json:"remove,omitempty"` // tag ids
+}
+
+type TagResponse struct {
+	models.Tag
+	NodeCount int `json:"node_count"` // files and folders with the tag, not counting the trash
+}
+
+// tagErrorStatus maps the errors of services.TagService.
+func tagErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrTagNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidTagName), errors.Is(err, services.ErrInvalidTagColor),
+		errors.Is(err, services.ErrMergeIntoSelf), errors.Is(err, services.ErrTooManyNodes):
+		return http.StatusBadRequest
+	case errors.Is(err, repository.ErrNameTaken):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// @Summary List tags
+// @Description Returns the user's tags by name with the number of files and folders that have each.
+// @Tags tags
+// @Produce json
+// @Success 200 {array} controllers.TagResponse
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /tags [get]
+func ListTagsHandler(tags *services.TagService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		usage, err := tags.List(uid.(string))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		out := make([]TagResponse, len(usage))
+		for i, u := range usage {
+			out[i] = TagResponse{Tag: *u.Tag, NodeCount: u.Nodes}
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// @Summary Create tag
+// @Description Tag names are unique per user, compared case-insensitively.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param payload body createTagReq true "name and color"
+// @Success 201 {object} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string "the user already has a tag of the name"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /tags [post]
+func CreateTagHandler(tags *services.TagService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req createTagReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		uid, _ := c.Get("user_id")
+		t, err := tags.Create(uid.(string), req.Name, req.Color)
+		if err != nil {
+			c.JSON(tagErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, t)
+	}
+}
+
+// @Summary Update tag
+// @Description Renames or recolors a tag. Only the fields present in the body are changed. To rename a tag to the name of another tag, merge them.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path string true "tag id"
+// @Param payload body updateTagReq true "fields to change"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string "the user already has a tag of the name"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /tags/{id} [patch]
+func UpdateTagHandler(tags *services.TagService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req updateTagReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		uid, _ := c.Get("user_id")
+		t, err := tags.Update(uid.(string), c.Param("id"), req.Name, req.Color)
+		if err != nil {
+			c.JSON(tagErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, t)
+	}
+}
+
+// @Summary Merge tags
+// @Description Gives every file and folder with the tag the tag into instead, then deletes the tag.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path string true "tag id"
+// @Param payload body mergeTagReq true "tag to keep"
+// @Success 200 {object} models.Tag "the tag kept"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /tags/{id}/merge [post]
+func MergeTagHandler(tags *services.TagService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req mergeTagReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		uid, _ := c.Get("user_id")
+		t, err := tags.Merge(uid.(string), c.Param("id"), req.Into)
+		if err != nil {
+			c.JSON(tagErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, t)
+	}
+}
+
+// @Summary Delete tag
+// @Description Removes the tag from every file and folder, also those in the trash, and deletes it.
+// @Tags tags
+// @Param id path string true "tag id"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /tags/{id} [delete]
+func DeleteTagHandler(tags *services.TagService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		if err := tags.Delete(uid.(string), c.Param("id")); err != nil {
+			c.JSON(tagErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// @Summary List nodes with a tag
+// @Description Returns the user's files and folders with the tag, in any folder, with their paths. More tags can be required with tags. Results are sorted and paged like GET /search.
+// @Tags tags
+// @Produce json
+// @Param id path string true "tag id"
+// @Param tags query string false "comma separated ids of further tags the nodes must have"
+// @Param type query string false "only file or folder"
+// @Param mime query string false "comma separated MIME types, e.g. image/*,application/pdf"
+// @Param ext query string false "comma separated extensions, e.g. jpg,png"
+// @Param sort query string false "name (natural, default), size, type (MIME type), created or updated"
+// @Param order query string false "asc (default) or desc"
+// @Param folders_first query bool false "list folders before files"
+// @Param limit query int false "page size, at most 1000"
+// @Param cursor query string false "X-Next-Cursor of the previous page"
+// @Success 200 {array} controllers.SearchResult
+// @Header 200 {string} X-Next-Cursor "cursor of the next page"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /tags/{id}/nodes [get]
+func ListTagNodesHandler(tags *services.TagService, nodes *services.NodeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		ownerID := uid.(string)
+
+		t, err := tags.Find(ownerID, c.Param("id"))
+		if err != nil {
+			c.JSON(tagErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		q := repository.SearchQuery{OwnerID: ownerID}
+		if q.NodeFilter, err = nodeFilter(c); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if q.ListOptions, err = listOptions(c, searchDefaultLimit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		q.Tags = append(q.Tags, t.ID)
+
+		respondSearch(c, nodes, q)
+	}
+}
+
+// @Summary Tag or untag nodes
+// @Description Adds the tags add to and removes the tags remove from up to 1000 files and folders. Nodes in the trash and nodes of other users are skipped. Returns the nodes that were changed with their tags.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param payload body tagNodesReq true "nodes and tags"
+// @Success 200 {array} models.Node
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string "tag not found"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /nodes/tags [post]
+func UpdateNodeTagsHandler(tags *services.TagService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req tagNodesReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		uid, _ := c.Get("user_id")
+		nodes, err := tags.Apply(uid.(string), req.NodeIDs, req.Add, req.Remove)
+		if err != nil {
+			c.JSON(tagErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, nodes)
+	}
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"server/internal/repository"
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get thumbnail of an image
+// @Description Returns a JPEG thumbnail of a JPEG, PNG or GIF file, turned upright by its EXIF orientation, that fits in a square of 128 (small), 256 (medium) or 1024 (large) pixels. Thumbnails are made after upload, or on the first request if they are missing.
+// @Tags files
+// @Produce jpeg
+// @Param id path string true "file id"
+// @Param size query string false "small, medium (default) or large"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string "not found or not an image"
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /files/{id}/thumbnail [get]
+func ThumbnailHandler(fileRepo repository.FileRepository, thumbs *services.ThumbnailService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		size := c.DefaultQuery("size", services.DefaultThumbnailSize)
+		if _, ok := services.ThumbnailSizes[size]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "size must be small, medium or large"})
+			return
+		}
+		uid, _ := c.Get("user_id")
+		node, err := fileRepo.FindNodeByID(c.Param("id"))
+		if err != nil || node == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		if node.OwnerID != uid.(string) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+
+		thumb, err := thumbs.Open(node, size)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrNoThumbnail):
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			case errors.Is(err, services.ErrBlobNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "file missing on server"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+		defer thumb.Close()
+		c.Header("Content-Type", "image/jpeg")
+		c.Header("Cache-Control", "private, max-age=86400")
+		c.Header("ETag", `"`+node.Digest+"-"+size+`"`)
+		http.ServeContent(c.Writer, c.Request, "", thumb.Info().ModTime, thumb)
+	}
+}
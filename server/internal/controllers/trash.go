@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary List trash
+// @Description Returns the nodes the user deleted, newest first. Folders are listed once with everything that was below them.
+// @Tags trash
+// @Produce json
+// @Success 200 {array} models.Node
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /trash [get]
+func ListTrashHandler(trash *services.TrashService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		nodes, err := trash.List(uid.(string))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, nodes)
+	}
+}
+
+// @Summary Restore node from trash
+// @Description Restores into the original folder, recreating it when it no longer exists. If that folder already has a node of the same name, the node is restored to the root instead.
+// @Tags trash
+// @Produce json
+// @Param id path string true "node id"
+// @Success 200 {object} models.Node
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /trash/{id}/restore [post]
+func RestoreHandler(trash *services.TrashService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		node, err := trash.Restore(uid.(string), c.Param("id"))
+		if err != nil {
+			if errors.Is(err, services.ErrNotInTrash) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, node)
+	}
+}
+
+// @Summary Delete node from trash permanently
+// @Tags trash
+// @Param id path string true "node id"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /trash/{id} [delete]
+func DeleteFromTrashHandler(trash *services.TrashService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		if err := trash.Delete(uid.(string), c.Param("id")); err != nil {
+			if errors.Is(err, services.ErrNotInTrash) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// @Summary Empty trash
+// @Tags trash
+// @Success 204
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /trash [delete]
+func EmptyTrashHandler(trash *services.TrashService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		if _, err := trash.Empty(uid.(string)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
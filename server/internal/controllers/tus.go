@@ -0,0 +1,378 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"server/internal/models"
+	"server/internal/repository"
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,concatenation,expiration"
+	tusBasePath   = "/files/tus/"
+)
+
+func tusHeaders(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Cache-Control", "no-store")
+}
+
+func tusCheckVersion(c *gin.Context) bool {
+	tusHeaders(c)
+	if v := c.GetHeader("Tus-Resumable"); v != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func parseTusMetadata(raw string) map[string]string {
+	meta := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, val, _ := strings.Cut(pair, " ")
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(val))
+		if err != nil {
+			continue
+		}
+		meta[key] = string(decoded)
+	}
+	return meta
+}
+
+// findOwnUpload loads an upload session and answers 404 for sessions of other
+// users so upload ids cannot be probed.
+func findOwnUpload(c *gin.Context, uploads *services.UploadService) *models.UploadSession {
+	uid, _ := c.Get("user_id")
+	ownerID := uid.(string)
+	u, err := uploads.Get(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrUploadNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return nil
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil
+	}
+	if u.OwnerID != ownerID {
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil
+	}
+	return u
+}
+
+// @Summary Resumable upload capabilities (tus)
+// @Tags uploads
+// @Success 204
+// @Router /files/tus [options]
+func TusOptionsHandler(uploads *services.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tusHeaders(c)
+		c.Header("Tus-Version", tusVersion)
+		c.Header("Tus-Extension", tusExtensions)
+		if uploads.MaxSize > 0 {
+			c.Header("Tus-Max-Size", strconv.FormatInt(uploads.MaxSize, 10))
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// @Summary Create resumable upload (tus creation / concatenation)
+// @Description Upload-Metadata accepts filename, filetype, parent_id and conflict (fail, rename, replace or skip, applied when the upload completes, see POST /files/upload). With `Upload-Concat: final;<urls>` the completed partial uploads are joined into one file node.
+// @Tags uploads
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param Upload-Length header int false "total size in bytes (not used for final concatenation)"
+// @Param Upload-Metadata header string false "tus metadata"
+// @Param Upload-Concat header string false "partial or final;<upload urls>"
+// @Param Digest header string false "expected checksums of the whole file, verified once it is complete"
+// @Success 201
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 507 {object} map[string]string "storage quota exceeded, or too much data in unfinished uploads"
+// @Security ApiKeyAuth
+// @Router /files/tus [post]
+func TusCreateHandler(fileRepo repository.FileRepository, uploads *services.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tusCheckVersion(c) {
+			return
+		}
+		uid, _ := c.Get("user_id")
+		ownerID := uid.(string)
+
+		want, err := requestChecksums(c.Request.Header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		meta := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+		u := &models.UploadSession{
+			OwnerID:  ownerID,
+			ParentID: meta["parent_id"],
+			Name:     "file",
+			Mime:     meta["filetype"],
+			Metadata: meta,
+			Expected: want,
+		}
+		if strings.TrimSpace(meta["filename"]) != "" {
+			if u.Name, err = validateNodeName(meta["filename"]); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if _, err := services.ParseConflict(meta["conflict"], ""); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if u.ParentID != "" {
+			parentNode, err := fileRepo.FindNodeByID(u.ParentID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if parentNode == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "parent not found"})
+				return
+			}
+			if parentNode.OwnerID != ownerID {
+				c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+				return
+			}
+			if parentNode.Type != "folder" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "parent is not a folder"})
+				return
+			}
+		}
+
+		concat := c.GetHeader("Upload-Concat")
+		if strings.HasPrefix(concat, "final;") {
+			var partIDs []string
+			for _, ref := range strings.Fields(strings.TrimPrefix(concat, "final;")) {
+				ref = strings.TrimRight(ref, "/")
+				partIDs = append(partIDs, ref[strings.LastIndex(ref, "/")+1:])
+			}
+			if len(partIDs) == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "no partial uploads given"})
+				return
+			}
+			node, err := uploads.Concat(u, partIDs)
+			if err != nil {
+				switch {
+				case errors.Is(err, services.ErrUploadNotFound):
+					c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				case errors.Is(err, services.ErrUploadIncomplete), errors.Is(err, services.ErrChecksumMismatch):
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				case errors.Is(err, services.ErrTooLarge):
+					c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+				case errors.Is(err, services.ErrQuotaExceeded):
+					c.JSON(http.StatusInsufficientStorage, gin.H{"error": err.Error()})
+				case errors.Is(err, repository.ErrNameTaken):
+					c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				default:
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				}
+				return
+			}
+			c.Header("Location", tusBasePath+u.ID)
+			c.Header("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+			c.Header("X-Node-Id", node.ID)
+			c.Status(http.StatusCreated)
+			return
+		}
+
+		length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+		if err != nil || length < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Upload-Length"})
+			return
+		}
+		if uploads.MaxSize > 0 && length > uploads.MaxSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "upload too large"})
+			return
+		}
+		u.Length = length
+		u.Partial = concat == "partial"
+
+		if err := uploads.Create(u); err != nil {
+			if errors.Is(err, services.ErrQuotaExceeded) || errors.Is(err, services.ErrStagingFull) {
+				c.JSON(http.StatusInsufficientStorage, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if u.Length == 0 && !u.Partial {
+			if u, err = uploads.Append(u.ID, 0, http.NoBody); err != nil {
+				if errors.Is(err, services.ErrChecksumMismatch) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				if errors.Is(err, services.ErrQuotaExceeded) {
+					c.JSON(http.StatusInsufficientStorage, gin.H{"error": err.Error()})
+					return
+				}
+				if errors.Is(err, repository.ErrNameTaken) {
+					c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.Header("X-Node-Id", u.NodeID)
+		}
+		c.Header("Location", tusBasePath+u.ID)
+		c.Header("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+		c.Status(http.StatusCreated)
+	}
+}
+
+// @Summary Resumable upload status (tus)
+// @Tags uploads
+// @Param id path string true "upload id"
+// @Param Tus-Resumable header string true "1.0.0"
+// @Success 200
+// @Failure 404
+// @Security ApiKeyAuth
+// @Router /files/tus/{id} [head]
+func TusHeadHandler(uploads *services.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tusCheckVersion(c) {
+			return
+		}
+		u := findOwnUpload(c, uploads)
+		if u == nil {
+			return
+		}
+		c.Header("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+		c.Header("Upload-Length", strconv.FormatInt(u.Length, 10))
+		c.Header("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+		if u.Partial {
+			c.Header("Upload-Concat", "partial")
+		} else if len(u.Parts) > 0 {
+			refs := make([]string, 0, len(u.Parts))
+			for _, p := range u.Parts {
+				refs = append(refs, tusBasePath+p)
+			}
+			c.Header("Upload-Concat", "final;"+strings.Join(refs, " "))
+		}
+		if u.NodeID != "" {
+			c.Header("X-Node-Id", u.NodeID)
+		}
+		c.Status(http.StatusOK)
+	}
+}
+
+// @Summary Append a chunk to a resumable upload (tus)
+// @Tags uploads
+// @Accept application/offset+octet-stream
+// @Param id path string true "upload id"
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param Upload-Offset header int true "offset of this chunk"
+// @Success 204
+// @Failure 403 {object} map[string]string "the upload is complete already"
+// @Failure 404
+// @Failure 409
+// @Failure 415
+// @Failure 460 {object} map[string]string "the completed file does not match the announced checksums"
+// @Failure 507 {object} map[string]string "storage quota exceeded, free space and resend the last chunk"
+// @Security ApiKeyAuth
+// @Router /files/tus/{id} [patch]
+func TusPatchHandler(uploads *services.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tusCheckVersion(c) {
+			return
+		}
+		if c.ContentType() != "application/offset+octet-stream" {
+			c.AbortWithStatus(http.StatusUnsupportedMediaType)
+			return
+		}
+		offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Upload-Offset"})
+			return
+		}
+		u := findOwnUpload(c, uploads)
+		if u == nil {
+			return
+		}
+		if time.Now().After(u.ExpiresAt) {
+			c.AbortWithStatus(http.StatusGone)
+			return
+		}
+
+		u, err = uploads.Append(u.ID, offset, c.Request.Body)
+		if u != nil {
+			c.Header("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+			c.Header("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrUploadOffsetMismatch), errors.Is(err, services.ErrUploadLocked):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			case errors.Is(err, services.ErrUploadNotFound):
+				c.AbortWithStatus(http.StatusNotFound)
+			case errors.Is(err, services.ErrUploadCompleted):
+				// tus answers 403 to PATCH requests against a final upload
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			case errors.Is(err, services.ErrChecksumMismatch):
+				// tus checksum extension status
+				c.JSON(460, gin.H{"error": err.Error()})
+			case errors.Is(err, services.ErrQuotaExceeded):
+				c.JSON(http.StatusInsufficientStorage, gin.H{"error": err.Error()})
+			case errors.Is(err, repository.ErrNameTaken):
+				// conflict=fail; the upload is gone
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+		if u.NodeID != "" {
+			c.Header("X-Node-Id", u.NodeID)
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// @Summary Terminate a resumable upload (tus)
+// @Tags uploads
+// @Param id path string true "upload id"
+// @Param Tus-Resumable header string true "1.0.0"
+// @Success 204
+// @Failure 404
+// @Security ApiKeyAuth
+// @Router /files/tus/{id} [delete]
+func TusDeleteHandler(uploads *services.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tusCheckVersion(c) {
+			return
+		}
+		u := findOwnUpload(c, uploads)
+		if u == nil {
+			return
+		}
+		if err := uploads.Terminate(u.ID); err != nil {
+			if errors.Is(err, services.ErrUploadLocked) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
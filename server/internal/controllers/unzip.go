@@ -2,13 +2,13 @@ package controllers
 
 import (
 	"archive/zip"
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -30,18 +30,22 @@ type UnzipResponse struct {
 }
 
 // @Summary Unzip uploaded ZIP archive
+// @Description The archive is extracted into a new folder. Folders whose names differ only in case are merged; conflict decides what happens to files whose names clash: fail (409, nothing is kept), rename to "name (1)" (default), replace (the later entry wins) or skip (the earlier entry wins).
 // @Tags files
 // @Accept multipart/form-data
 // @Produce json
 // @Param parent_id formData string false "parent folder id"
+// @Param conflict formData string false "fail, rename, replace or skip"
 // @Param file formData file true "zip file to upload"
 // @Success 201 {object} controllers.UnzipResponse
 // @Failure 400 {object} map[string]string
 // @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Failure 507 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /files/unzip [post]
-func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageService) gin.HandlerFunc {
+func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageService, nodes *services.NodeService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		const (
 			maxZipSize          = int64(200 << 20)  // 200MB max upload zip
@@ -51,34 +55,16 @@ func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageS
 			tempPrefix          = "upload-zip-"
 		)
 
-		originalParentID := c.PostForm("parent_id")
-		parentID := originalParentID
 		uid, _ := c.Get("user_id")
 		ownerID := uid.(string)
 
-		type storageFromReader interface {
-			SaveFromReader(ownerID string, path string, r io.Reader) (savedPath string, size int64, err error)
-		}
-		sw, ok := interface{}(storage).(storageFromReader)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "storage service must implement SaveFromReader(ownerID, path string, r io.Reader) (string, int64, error)"})
-			return
-		}
-
-		fh, err := c.FormFile("file")
+		mr, err := c.Request.MultipartReader()
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "file required"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "multipart/form-data body required"})
 			return
 		}
 
-		src, err := fh.Open()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open uploaded file"})
-			return
-		}
-		defer src.Close()
-
-		tmpFile, err := os.CreateTemp("", tempPrefix+"*.zip")
+		tmpFile, err := storage.CreateTemp(tempPrefix + "*.zip")
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create temp file"})
 			return
@@ -89,17 +75,53 @@ func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageS
 			_ = os.Remove(tmpPath)
 		}()
 
-		written, err := io.CopyN(tmpFile, src, maxZipSize+1)
-		if err != nil && !errors.Is(err, io.EOF) {
-			if !errors.Is(err, io.EOF) {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read uploaded file"})
+		// stream the archive straight to the temp file; the form fields may
+		// come on either side of it
+		var originalParentID, zipName, conflict string
+		gotFile := false
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "malformed multipart body"})
 				return
 			}
+			switch {
+			case part.FormName() == "file" && !gotFile:
+				gotFile = true
+				zipName = part.FileName()
+				written, err := io.CopyN(tmpFile, part, maxZipSize+1)
+				if err != nil && !errors.Is(err, io.EOF) {
+					part.Close()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read uploaded file"})
+					return
+				}
+				if written > maxZipSize {
+					part.Close()
+					c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("zip too large (limit %d bytes)", maxZipSize)})
+					return
+				}
+			case part.FormName() == "parent_id":
+				v, _ := io.ReadAll(io.LimitReader(part, 1024))
+				originalParentID = strings.TrimSpace(string(v))
+			case part.FormName() == "conflict":
+				v, _ := io.ReadAll(io.LimitReader(part, 1024))
+				conflict = strings.TrimSpace(string(v))
+			}
+			part.Close()
 		}
-		if written > maxZipSize {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("zip too large (limit %d bytes)", maxZipSize)})
+		if !gotFile {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file required"})
 			return
 		}
+		conflict, err = services.ParseConflict(conflict, services.ConflictRename)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		parentID := originalParentID
 		stat, err := tmpFile.Stat()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to stat temp file"})
@@ -117,14 +139,36 @@ func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageS
 		}
 		defer zr.Close()
 
+		// the sizes in the archive may lie, IngestFile enforces the real ones
+		var declaredSize, declaredFiles int64
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() || strings.HasSuffix(f.Name, "/") || f.Mode()&os.ModeSymlink != 0 {
+				continue
+			}
+			declaredSize += int64(f.UncompressedSize64)
+			declaredFiles++
+		}
+		if declaredSize > maxTotalExtractSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "zip extracts to too much data"})
+			return
+		}
+		if err := storage.CheckQuota(ownerID, declaredSize, declaredFiles); err != nil {
+			c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
 		createdNodes := make([]*models.Node, 0, 64)
 		createdPaths := make([]string, 0, 64)
 		dirNodeMap := map[string]string{}
+		// files by folder and name key, to leave out the ones replaced by a
+		// later entry
+		fileNodeMap := map[string]string{}
+		replaced := map[string]bool{}
 
 		totalEntries := 0
 		var totalExtractedSize int64 = 0
 
-		baseName := strings.TrimSuffix(fh.Filename, filepath.Ext(fh.Filename))
+		baseName := strings.TrimSuffix(zipName, filepath.Ext(zipName))
 		baseName = strings.ReplaceAll(baseName, "/", "_")
 		baseName = strings.ReplaceAll(baseName, "\\", "_")
 		if baseName == "" {
@@ -140,13 +184,12 @@ func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageS
 			Name:     rootFolderName,
 			Type:     "folder",
 		}
-		if err := fileRepo.CreateNode(rootNode); err != nil {
+		if _, _, err := nodes.Create(rootNode, services.ConflictRename); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create root folder node: " + err.Error()})
 			return
 		}
 		createdNodes = append(createdNodes, rootNode)
 		parentID = rootNode.ID
-		physicalFolderPrefix := rootNode.ID
 
 		createDirNode := func(dirParts []string) (string, error) {
 			if len(dirParts) == 0 {
@@ -161,16 +204,13 @@ func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageS
 					curParent = id
 					continue
 				}
-				node := &models.Node{
-					OwnerID:  ownerID,
-					ParentID: curParent,
-					Name:     p,
-					Type:     "folder",
-				}
-				if err := fileRepo.CreateNode(node); err != nil {
+				node, created, err := nodes.EnsureFolder(ownerID, curParent, p)
+				if err != nil {
 					return "", err
 				}
-				createdNodes = append(createdNodes, node)
+				if created {
+					createdNodes = append(createdNodes, node)
+				}
 				dirNodeMap[key] = node.ID
 				curParent = node.ID
 				if i > 1000 {
@@ -183,7 +223,7 @@ func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageS
 		for _, f := range zr.File {
 			totalEntries++
 			if totalEntries > maxEntries {
-				cleanupCreated(storage, createdPaths, createdNodes, fileRepo)
+				cleanupCreated(storage, createdNodes, fileRepo)
 				c.JSON(http.StatusBadRequest, gin.H{"error": "too many entries in zip"})
 				return
 			}
@@ -201,15 +241,15 @@ func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageS
 				continue
 			}
 			for _, pseg := range strings.Split(clean, "/") {
-				if pseg == ".." {
-					cleanupCreated(storage, createdPaths, createdNodes, fileRepo)
-					c.JSON(http.StatusBadRequest, gin.H{"error": "zip contains invalid path segments"})
+				if _, err := validateNodeName(pseg); err != nil {
+					cleanupCreated(storage, createdNodes, fileRepo)
+					c.JSON(http.StatusBadRequest, gin.H{"error": "zip contains invalid path segments: " + err.Error()})
 					return
 				}
 			}
 
 			if len(clean) > 4096 {
-				cleanupCreated(storage, createdPaths, createdNodes, fileRepo)
+				cleanupCreated(storage, createdNodes, fileRepo)
 				c.JSON(http.StatusBadRequest, gin.H{"error": "filename too long"})
 				return
 			}
@@ -217,7 +257,7 @@ func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageS
 			if f.FileInfo().IsDir() || strings.HasSuffix(name, "/") {
 				parts := strings.Split(strings.TrimRight(clean, "/"), "/")
 				if _, err := createDirNode(parts); err != nil {
-					cleanupCreated(storage, createdPaths, createdNodes, fileRepo)
+					cleanupCreated(storage, createdNodes, fileRepo)
 					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create folder nodes: " + err.Error()})
 					return
 				}
@@ -231,12 +271,12 @@ func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageS
 			parts := strings.Split(clean, "/")
 			for _, comp := range parts {
 				if comp == "" {
-					cleanupCreated(storage, createdPaths, createdNodes, fileRepo)
+					cleanupCreated(storage, createdNodes, fileRepo)
 					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid path in zip"})
 					return
 				}
 				if len(comp) > maxFilenameLength {
-					cleanupCreated(storage, createdPaths, createdNodes, fileRepo)
+					cleanupCreated(storage, createdNodes, fileRepo)
 					c.JSON(http.StatusBadRequest, gin.H{"error": "filename component too long"})
 					return
 				}
@@ -245,43 +285,37 @@ func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageS
 			dirParts := parts[:len(parts)-1]
 			parentForFile, err := createDirNode(dirParts)
 			if err != nil {
-				cleanupCreated(storage, createdPaths, createdNodes, fileRepo)
+				cleanupCreated(storage, createdNodes, fileRepo)
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create parent folders: " + err.Error()})
 				return
 			}
 
 			rc, err := f.Open()
 			if err != nil {
-				cleanupCreated(storage, createdPaths, createdNodes, fileRepo)
+				cleanupCreated(storage, createdNodes, fileRepo)
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read zip entry"})
 				return
 			}
 
-			limited := io.LimitReader(rc, maxTotalExtractSize+1)
-			relPath := path.Join(physicalFolderPrefix, clean)
-			savedPath, size, err := sw.SaveFromReader(ownerID, relPath, limited)
+			limited := bufio.NewReaderSize(io.LimitReader(rc, maxTotalExtractSize+1), 512)
+			head, _ := limited.Peek(512)
+			mimeType := http.DetectContentType(head)
+			blob, err := storage.IngestFile(ownerID, limited, storage.MaxSize, nil)
 			rc.Close()
 			if err != nil {
-				cleanupCreated(storage, createdPaths, createdNodes, fileRepo)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save extracted file: " + err.Error()})
+				cleanupCreated(storage, createdNodes, fileRepo)
+				c.JSON(uploadErrorStatus(err), gin.H{"error": "failed to save extracted file: " + err.Error()})
 				return
 			}
 
-			totalExtractedSize += size
+			totalExtractedSize += blob.Size
 			if totalExtractedSize > maxTotalExtractSize {
-				_ = storage.DeleteFile(savedPath)
-				cleanupCreated(storage, append(createdPaths, savedPath), createdNodes, fileRepo)
+				_ = storage.DiscardIngested(ownerID, blob)
+				cleanupCreated(storage, createdNodes, fileRepo)
 				c.JSON(http.StatusBadRequest, gin.H{"error": "zip extracts to too much data"})
 				return
 			}
 
-			var mimeType string
-			if f2, err := os.Open(savedPath); err == nil {
-				buf := make([]byte, 512)
-				n, _ := f2.Read(buf)
-				mimeType = http.DetectContentType(buf[:n])
-				f2.Close()
-			}
 			if mimeType == "" || mimeType == "application/octet-stream" {
 				ext := strings.ToLower(filepath.Ext(parts[len(parts)-1]))
 				if ext != "" {
@@ -298,24 +332,42 @@ func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageS
 				ParentID: parentForFile,
 				Name:     parts[len(parts)-1],
 				Type:     "file",
-				Size:     size,
-				Path:     savedPath,
+				Size:     blob.Size,
+				Path:     blob.Key,
+				Digest:   blob.Digest,
+				MD5:      blob.MD5,
+				CRC32C:   blob.CRC32C,
+				BlobID:   blob.ID,
 				Mime:     mimeType,
 			}
-			if err := fileRepo.CreateNode(node); err != nil {
-				_ = storage.DeleteFile(savedPath)
-				cleanupCreated(storage, append(createdPaths, savedPath), createdNodes, fileRepo)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create node: " + err.Error()})
+			placed, created, err := nodes.CreateFile(node, blob, conflict)
+			if err != nil {
+				cleanupCreated(storage, createdNodes, fileRepo)
+				c.JSON(uploadErrorStatus(err), gin.H{"error": "failed to create node: " + err.Error()})
 				return
 			}
-			createdNodes = append(createdNodes, node)
-			createdPaths = append(createdPaths, savedPath)
+			if !created {
+				continue
+			}
+			fileKey := parentForFile + "/" + repository.NameKey(placed.Name)
+			if prev, ok := fileNodeMap[fileKey]; ok && conflict == services.ConflictReplace {
+				replaced[prev] = true
+			}
+			fileNodeMap[fileKey] = placed.ID
+			createdNodes = append(createdNodes, placed)
+			createdPaths = append(createdPaths, blob.Key)
 		}
 
+		kept := make([]*models.Node, 0, len(createdNodes))
+		for _, n := range createdNodes {
+			if !replaced[n.ID] {
+				kept = append(kept, n)
+			}
+		}
 		resp := UnzipResponse{
 			Message:      "unzipped successfully",
-			CreatedCount: len(createdNodes),
-			CreatedNodes: createdNodes,
+			CreatedCount: len(kept),
+			CreatedNodes: kept,
 			CreatedPaths: createdPaths,
 			RootParentID: rootNode.ID,
 			Timestamp:    time.Now().UTC(),
@@ -324,11 +376,21 @@ func UnzipHandler(fileRepo repository.FileRepository, storage *services.StorageS
 	}
 }
 
-func cleanupCreated(storage *services.StorageService, savedPaths []string, createdNodes []*models.Node, fileRepo repository.FileRepository) {
-	for _, p := range savedPaths {
-		_ = storage.DeleteFile(p)
-	}
+// cleanupCreated deletes the nodes the unzip created, the last one first.
+// Each is read again, as a later entry of the same name may have given it
+// new content and made the old one a version.
+func cleanupCreated(storage *services.StorageService, createdNodes []*models.Node, fileRepo repository.FileRepository) {
 	for i := len(createdNodes) - 1; i >= 0; i-- {
-		_ = fileRepo.DeleteNode(createdNodes[i].ID)
+		n, err := fileRepo.FindNodeByID(createdNodes[i].ID)
+		if err != nil || n == nil {
+			continue
+		}
+		deleted, err := fileRepo.PurgeNode(n.ID)
+		if err != nil || !deleted {
+			continue
+		}
+		if n.Type == "file" {
+			_ = storage.ReleaseFile(n)
+		}
 	}
 }
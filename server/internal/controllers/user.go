@@ -1,9 +1,11 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
+	"server/internal/models"
 	"server/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -68,15 +70,20 @@ func UpdateProfileHandler(s *services.AuthService, storageSvc *services.StorageS
 		uid := uidI.(string)
 
 		name := c.PostForm("name")
-		var avatarPath string
+		var avatar *models.Blob
 
 		if fh, err := c.FormFile("avatar"); err == nil && fh != nil {
-			saved, _, err := storageSvc.SaveFile(uid, fh)
+			src, err := fh.Open()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open uploaded file"})
+				return
+			}
+			avatar, err = storageSvc.Ingest(uid, src)
+			src.Close()
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save avatar: " + err.Error()})
 				return
 			}
-			avatarPath = saved
 		} else {
 			if name == "" {
 				var jb struct {
@@ -89,11 +96,21 @@ func UpdateProfileHandler(s *services.AuthService, storageSvc *services.StorageS
 			}
 		}
 
-		u, err := s.UpdateProfile(uid, name, avatarPath)
+		var previous *models.User
+		if avatar != nil {
+			previous, _ = s.GetProfile(uid)
+		}
+		u, err := s.UpdateProfile(uid, name, avatar)
 		if err != nil {
+			if avatar != nil {
+				_ = storageSvc.Release(avatar.ID)
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		if previous != nil {
+			_ = storageSvc.ReleaseAvatar(previous)
+		}
 		c.JSON(http.StatusOK, u)
 	}
 }
@@ -174,3 +191,92 @@ func ChangePasswordHandler(s *services.AuthService) gin.HandlerFunc {
 		c.Status(http.StatusNoContent)
 	}
 }
+
+type usageRes struct {
+	UsedBytes int64 `json:"used_bytes"`
+	UsedFiles int64 `json:"used_files"`
+	MaxBytes  int64 `json:"max_bytes"`
+	MaxFiles  int64 `json:"max_files"`
+}
+
+// @Summary Get storage usage
+// @Description Returns the bytes and files stored by the authenticated user and the limits that apply. A limit of 0 means unlimited.
+// @Tags user
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} usageRes
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /me/usage [get]
+func UsageHandler(quotas *services.QuotaService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uidI, ok := c.Get("user_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+			return
+		}
+		u, err := quotas.Usage(uidI.(string))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, usageRes{
+			UsedBytes: u.UsedBytes,
+			UsedFiles: u.UsedFiles,
+			MaxBytes:  u.MaxBytes,
+			MaxFiles:  u.MaxFiles,
+		})
+	}
+}
+
+// @Summary Get versioning settings
+// @Description Whether overwritten files keep their previous content, with the limits that apply. Limits above the server limits are lowered to them.
+// @Tags user
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.VersioningSettings
+// @Failure 500 {object} map[string]string
+// @Router /me/versioning [get]
+func GetVersioningHandler(versions *services.VersionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		s, err := versions.Settings(uid.(string))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	}
+}
+
+// @Summary Update versioning settings
+// @Description With versioning enabled, uploading a file with the name of an existing file in the same folder, or PUT /files/{id}/content, keeps the previous content as a version. Zero limits mean the server limits.
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param payload body models.VersioningSettings true "settings"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.VersioningSettings
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /me/versioning [put]
+func UpdateVersioningHandler(versions *services.VersionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.VersioningSettings
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		uid, _ := c.Get("user_id")
+		s, err := versions.SetSettings(uid.(string), &req)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidVersionLimits) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	}
+}
@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"server/internal/models"
+	"server/internal/repository"
+	"server/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func versionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrVersionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrNodeChanged):
+		return http.StatusConflict
+	default:
+		return uploadErrorStatus(err)
+	}
+}
+
+// ownedFile loads the file node named by the id parameter and writes the
+// error response if it is missing, not the caller's or not a file.
+func ownedFile(c *gin.Context, fileRepo repository.FileRepository) (*models.Node, bool) {
+	uid, _ := c.Get("user_id")
+	node, err := fileRepo.FindNodeByID(c.Param("id"))
+	if err != nil || node == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return nil, false
+	}
+	if node.OwnerID != uid.(string) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return nil, false
+	}
+	if node.Type != "file" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "not a file"})
+		return nil, false
+	}
+	return node, true
+}
+
+// @Summary Replace file content
+// @Description Streams the request body in as the new content of the file. With versioning enabled the previous content is kept as a version. Without a Content-Type the mime type is kept.
+// @Tags versions
+// @Accept octet-stream
+// @Produce json
+// @Param id path string true "file id"
+// @Param Digest header string false "expected checksums of the file, e.g. sha-256=<base64>, md5=<base64>, crc32c=<base64>"
+// @Param Content-MD5 header string false "expected MD5 of the file (base64)"
+// @Success 200 {object} models.Node
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 507 {object} map[string]string "storage quota exceeded"
+// @Security ApiKeyAuth
+// @Router /files/{id}/content [put]
+func PutContentHandler(fileRepo repository.FileRepository, storage *services.StorageService, versions *services.VersionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		node, ok := ownedFile(c, fileRepo)
+		if !ok {
+			return
+		}
+		want, err := requestChecksums(c.Request.Header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := storage.CheckQuota(node.OwnerID, max(c.Request.ContentLength, 0), 0); err != nil {
+			c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if !limitUploadBody(c, storage.MaxSize) {
+			return
+		}
+		blob, err := storage.IngestContent(node.OwnerID, c.Request.Body, storage.MaxSize, want)
+		if err != nil {
+			c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		mimeType := c.ContentType()
+		if mimeType == "application/octet-stream" {
+			mimeType = ""
+		}
+		updated, err := versions.ReplaceContent(node, blob, mimeType, false)
+		if err != nil {
+			c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// @Summary List file versions
+// @Description Previous contents of the file, newest first.
+// @Tags versions
+// @Produce json
+// @Param id path string true "file id"
+// @Success 200 {array} models.NodeVersion
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /files/{id}/versions [get]
+func ListVersionsHandler(fileRepo repository.FileRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		node, ok := ownedFile(c, fileRepo)
+		if !ok {
+			return
+		}
+		out := node.Versions
+		if out == nil {
+			out = []models.NodeVersion{}
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// @Summary Download file version
+// @Tags versions
+// @Produce octet-stream
+// @Param id path string true "file id"
+// @Param version_id path string true "version id"
+// @Success 200 {file} file
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /files/{id}/versions/{version_id}/download [get]
+func DownloadVersionHandler(fileRepo repository.FileRepository, versions *services.VersionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		node, ok := ownedFile(c, fileRepo)
+		if !ok {
+			return
+		}
+		ver, err := versions.Find(node, c.Param("version_id"))
+		if err != nil {
+			c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		blob, err := versions.Open(node, ver)
+		if err != nil {
+			if errors.Is(err, services.ErrBlobNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "file missing on server"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer blob.Close()
+		if ver.Mime != "" {
+			c.Header("Content-Type", ver.Mime)
+		}
+		c.Header("Content-Disposition", contentDisposition(node.Name))
+		setDigestHeaders(c.Writer, c.Request, &models.Node{Digest: ver.Digest})
+		http.ServeContent(c.Writer, c.Request, node.Name, ver.ReplacedAt, blob)
+	}
+}
+
+// @Summary Restore file version
+// @Description Makes the version the current content. The current content is kept as a version in its place.
+// @Tags versions
+// @Produce json
+// @Param id path string true "file id"
+// @Param version_id path string true "version id"
+// @Success 200 {object} models.Node
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /files/{id}/versions/{version_id}/restore [post]
+func RestoreVersionHandler(fileRepo repository.FileRepository, versions *services.VersionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		node, ok := ownedFile(c, fileRepo)
+		if !ok {
+			return
+		}
+		updated, err := versions.Restore(node, c.Param("version_id"))
+		if err != nil {
+			c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// @Summary Delete file version
+// @Tags versions
+// @Param id path string true "file id"
+// @Param version_id path string true "version id"
+// @Success 204
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /files/{id}/versions/{version_id} [delete]
+func DeleteVersionHandler(fileRepo repository.FileRepository, versions *services.VersionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		node, ok := ownedFile(c, fileRepo)
+		if !ok {
+			return
+		}
+		if err := versions.Delete(node, c.Param("version_id")); err != nil {
+			c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+type Blob struct {
+	ID         string    `json:"id" bson:"_id"`                            // digest, or "<key id>.<digest>" when encrypted
+	Digest     string    `json:"digest" bson:"digest"`                     // sha-256 hex digest of the plaintext
+	KeyID      string    `json:"key_id,omitempty" bson:"key_id,omitempty"` // data key the blob is encrypted with
+	MD5        string    `json:"md5,omitempty" bson:"md5,omitempty"`       // hex, of the plaintext
+	CRC32C     string    `json:"crc32c,omitempty" bson:"crc32c,omitempty"` // hex, of the plaintext
+	Key        string    `json:"key" bson:"key"`
+	Size       int64     `json:"size" bson:"size"`
+	RefCount   int64     `json:"ref_count" bson:"ref_count"`
+	Corrupt    bool      `json:"corrupt,omitempty" bson:"corrupt,omitempty"`
+	VerifiedAt time.Time `json:"verified_at,omitempty" bson:"verified_at,omitempty"` // last scrub
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" bson:"updated_at"`
+
+	// Renditions names the images derived from the blob, deleted with it.
+	Renditions []string `json:"renditions,omitempty" bson:"renditions,omitempty"`
+	// DeletingAt marks a blob whose content is being deleted; it cannot be
+	// acquired until the record is gone.
+	DeletingAt *time.Time `json:"deleting_at,omitempty" bson:"deleting_at,omitempty"`
+}
+
+// Checksums of a content, hex encoded. Empty fields are unknown.
+type Checksums struct {
+	SHA256 string `json:"sha256,omitempty" bson:"sha256,omitempty"`
+	MD5    string `json:"md5,omitempty" bson:"md5,omitempty"`
+	CRC32C string `json:"crc32c,omitempty" bson:"crc32c,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ContentIndex is the text extracted from a file content for search. It is
+// kept once per owner and content and shared by the owner's files with that
+// digest, so copies and moves need no indexing.
+type ContentIndex struct {
+	ID        string    `json:"id" bson:"_id"` // "<owner id>.<digest>"
+	OwnerID   string    `json:"owner_id" bson:"owner_id"`
+	Digest    string    `json:"digest" bson:"digest"`
+	Text      string    `json:"text" bson:"text"` // NFKC normalized, possibly cut off
+	Tokens    []string  `json:"-" bson:"tokens"`  // see services.contentTokens
+	Truncated bool      `json:"truncated,omitempty" bson:"truncated,omitempty"`
+	IndexedAt time.Time `json:"indexed_at" bson:"indexed_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// DataKey is a per-user content encryption key, stored wrapped (encrypted)
+// by the master key identified by MasterKeyID.
+type DataKey struct {
+	ID          string    `json:"id" bson:"_id,omitempty"`
+	OwnerID     string    `json:"owner_id" bson:"owner_id"`
+	WrappedKey  []byte    `json:"-" bson:"wrapped_key"`
+	MasterKeyID string    `json:"master_key_id" bson:"master_key_id"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+}
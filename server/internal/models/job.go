@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Job tracks a long running operation started by a request, such as copying
+// a large folder tree.
+type Job struct {
+	ID        string    `json:"id" bson:"_id"`
+	OwnerID   string    `json:"owner_id" bson:"owner_id"`
+	Type      string    `json:"type" bson:"type"` // "copy"
+	Status    string    `json:"status" bson:"status"`
+	SourceID  string    `json:"source_id,omitempty" bson:"source_id,omitempty"`
+	TargetID  string    `json:"target_id,omitempty" bson:"target_id,omitempty"` // destination parent, empty for root
+	ResultID  string    `json:"result_id,omitempty" bson:"result_id,omitempty"` // node created by the job
+	Total     int       `json:"total" bson:"total"`                             // 0 until the nodes are counted
+	Done      int       `json:"done" bson:"done"`
+	Error     string    `json:"error,omitempty" bson:"error,omitempty"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// MetadataKey declares the type of the values of one of a user's metadata
+// keys, so that every node has values of the same type under it.
+type MetadataKey struct {
+	ID        string    `json:"-" bson:"_id"` // "<owner id>.<key>"
+	OwnerID   string    `json:"owner_id" bson:"owner_id"`
+	Key       string    `json:"key" bson:"key"`
+	Type      string    `json:"type" bson:"type"` // "string", "number" or "boolean"
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
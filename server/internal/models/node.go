@@ -3,14 +3,36 @@ package models
 import "time"
 
 type Node struct {
-	ID        string    `json:"id" bson:"_id,omitempty"`
-	OwnerID   string    `json:"owner_id" bson:"owner_id"`
-	ParentID  string    `json:"parent_id,omitempty" bson:"parent_id,omitempty"` // root: empty
-	Name      string    `json:"name" bson:"name"`
-	Type      string    `json:"type" bson:"type"`                     // "file" | "folder"
-	Size      int64     `json:"size,omitempty" bson:"size,omitempty"` // bytes for files
-	Mime      string    `json:"mime,omitempty" bson:"mime,omitempty"`
-	Path      string    `json:"path,omitempty" bson:"path,omitempty"`
-	CreatedAt time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+	ID        string        `json:"id" bson:"_id,omitempty"`
+	OwnerID   string        `json:"owner_id" bson:"owner_id"`
+	ParentID  string        `json:"parent_id,omitempty" bson:"parent_id,omitempty"` // root: empty
+	Name      string        `json:"name" bson:"name"`
+	NameKey   string        `json:"-" bson:"name_key,omitempty"`          // unique among siblings, see repository.NameKey
+	NameGrams []string      `json:"-" bson:"name_grams,omitempty"`        // for search, see repository.NameGrams
+	Type      string        `json:"type" bson:"type"`                     // "file" | "folder"
+	Size      int64         `json:"size,omitempty" bson:"size,omitempty"` // bytes for files
+	Mime      string        `json:"mime,omitempty" bson:"mime,omitempty"`
+	Path      string        `json:"path,omitempty" bson:"path,omitempty"`
+	Digest    string        `json:"digest,omitempty" bson:"digest,omitempty"` // sha-256 of the content
+	MD5       string        `json:"md5,omitempty" bson:"md5,omitempty"`
+	CRC32C    string        `json:"crc32c,omitempty" bson:"crc32c,omitempty"`
+	BlobID    string        `json:"-" bson:"blob_id,omitempty"`                 // see models.Blob
+	Corrupt   bool          `json:"corrupt,omitempty" bson:"corrupt,omitempty"` // content no longer matches Digest
+	Versions  []NodeVersion `json:"-" bson:"versions,omitempty"`                // previous contents, newest first
+	Tags      []string      `json:"tags,omitempty" bson:"tags,omitempty"`       // IDs of models.Tag
+	Starred   bool          `json:"starred,omitempty" bson:"starred,omitempty"`
+	CreatedAt time.Time     `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at" bson:"updated_at"`
+
+	// Description and Metadata annotate the node; metadata values are
+	// strings, numbers or booleans, see services.MetadataService.
+	Description string                 `json:"description,omitempty" bson:"description,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty" bson:"metadata,omitempty"`
+
+	// Deleted nodes stay in the trash until purged. The node the user
+	// deleted keeps its parent and the folder names above it; everything
+	// below it points back to it with TrashedWith.
+	TrashedAt   *time.Time `json:"trashed_at,omitempty" bson:"trashed_at,omitempty"`
+	TrashedWith string     `json:"-" bson:"trashed_with,omitempty"`
+	TrashPath   string     `json:"trash_path,omitempty" bson:"trash_path,omitempty"` // e.g. "Documents/2024"
 }
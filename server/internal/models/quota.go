@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Quota holds the storage limits and the running usage of one user. Usage
+// counts every file node and kept version with its logical size,
+// deduplicated or not.
+type Quota struct {
+	OwnerID   string    `json:"owner_id" bson:"_id"`
+	MaxBytes  int64     `json:"max_bytes" bson:"max_bytes,omitempty"` // 0: server default, negative: unlimited
+	MaxFiles  int64     `json:"max_files" bson:"max_files,omitempty"` // 0: server default, negative: unlimited
+	UsedBytes int64     `json:"used_bytes" bson:"used_bytes"`
+	UsedFiles int64     `json:"used_files" bson:"used_files"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// RecentEntry records when a user last touched a node. There is one entry
+// per user and node, so the recent list has every node once.
+type RecentEntry struct {
+	ID         string    `json:"id" bson:"_id"` // "<owner id>.<node id>"
+	OwnerID    string    `json:"owner_id" bson:"owner_id"`
+	NodeID     string    `json:"node_id" bson:"node_id"`
+	Type       string    `json:"type" bson:"type"`     // of the node, "file" | "folder"
+	Action     string    `json:"action" bson:"action"` // the last one: "upload" | "download" | "preview" | "open"
+	AccessedAt time.Time `json:"accessed_at" bson:"accessed_at"`
+}
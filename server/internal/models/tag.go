@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Tag is a label a user attaches to any of their files and folders,
+// wherever they are. Nodes refer to their tags by ID.
+type Tag struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	OwnerID   string    `json:"owner_id" bson:"owner_id"`
+	Name      string    `json:"name" bson:"name"`
+	NameKey   string    `json:"-" bson:"name_key"`  // unique per owner, see repository.NameKey
+	Color     string    `json:"color" bson:"color"` // "#rrggbb"
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
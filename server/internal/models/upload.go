@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type UploadSession struct {
+	ID        string            `json:"id" bson:"_id"`
+	OwnerID   string            `json:"owner_id" bson:"owner_id"`
+	ParentID  string            `json:"parent_id,omitempty" bson:"parent_id,omitempty"`
+	Name      string            `json:"name" bson:"name"`
+	Mime      string            `json:"mime,omitempty" bson:"mime,omitempty"`
+	Length    int64             `json:"length" bson:"length"`
+	Offset    int64             `json:"offset" bson:"offset"`
+	Metadata  map[string]string `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	Partial   bool              `json:"partial,omitempty" bson:"partial,omitempty"`   // tus concatenation part
+	Parts     []string          `json:"parts,omitempty" bson:"parts,omitempty"`       // upload ids of a final concatenation
+	NodeID    string            `json:"node_id,omitempty" bson:"node_id,omitempty"`   // set once assembled
+	PendingID string            `json:"-" bson:"pending_id,omitempty"`                // id the node is created with, so that a retry finds it
+	Expected  *Checksums        `json:"expected,omitempty" bson:"expected,omitempty"` // announced by the client, verified on completion
+	ExpiresAt time.Time         `json:"expires_at" bson:"expires_at"`
+	CreatedAt time.Time         `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at" bson:"updated_at"`
+}
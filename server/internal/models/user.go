@@ -3,11 +3,13 @@ package models
 import "time"
 
 type User struct {
-	ID           string    `json:"id" bson:"_id,omitempty"`
-	Email        string    `json:"email" bson:"email"`
-	PasswordHash string    `json:"-" bson:"password_hash"`
-	Name         string    `json:"name,omitempty" bson:"name,omitempty"`
-	AvatarURL    string    `json:"avatar_url,omitempty" bson:"avatar_url,omitempty"`
-	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" bson:"updated_at"`
+	ID           string              `json:"id" bson:"_id,omitempty"`
+	Email        string              `json:"email" bson:"email"`
+	PasswordHash string              `json:"-" bson:"password_hash"`
+	Name         string              `json:"name,omitempty" bson:"name,omitempty"`
+	AvatarURL    string              `json:"avatar_url,omitempty" bson:"avatar_url,omitempty"` // storage key of the avatar
+	AvatarBlobID string              `json:"-" bson:"avatar_blob_id,omitempty"`
+	Versioning   *VersioningSettings `json:"versioning,omitempty" bson:"versioning,omitempty"`
+	CreatedAt    time.Time           `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at" bson:"updated_at"`
 }
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// NodeVersion is a previous content of a file node, kept when the file is
+// overwritten with versioning enabled.
+type NodeVersion struct {
+	ID         string    `json:"id" bson:"id"`
+	Size       int64     `json:"size" bson:"size"`
+	Mime       string    `json:"mime,omitempty" bson:"mime,omitempty"`
+	Path       string    `json:"-" bson:"path,omitempty"`
+	Digest     string    `json:"digest,omitempty" bson:"digest,omitempty"`
+	MD5        string    `json:"md5,omitempty" bson:"md5,omitempty"`
+	CRC32C     string    `json:"crc32c,omitempty" bson:"crc32c,omitempty"`
+	BlobID     string    `json:"-" bson:"blob_id"`
+	ReplacedAt time.Time `json:"replaced_at" bson:"replaced_at"` // when it stopped being the current content
+}
+
+// VersioningSettings are a user's choices for keeping previous contents of
+// overwritten files. Zero limits mean the server limits.
+type VersioningSettings struct {
+	Enabled     bool `json:"enabled" bson:"enabled"`
+	MaxVersions int  `json:"max_versions" bson:"max_versions,omitempty"`
+	MaxAgeDays  int  `json:"max_age_days" bson:"max_age_days,omitempty"`
+}
@@ -0,0 +1,35 @@
+package repository
+
+//go:generate mockery --name=BlobRepository --output=mocks --outpkg=mocks
+
+import (
+	"errors"
+
+	"server/internal/models"
+)
+
+// ErrBlobDeleting is returned by AcquireBlob for a blob marked with
+// MarkBlobDeleting.
+var ErrBlobDeleting = errors.New("blob is being deleted")
+
+type BlobRepository interface {
+	// AcquireBlob adds a reference to the blob, inserting it when unknown.
+	// It reports whether the blob document was created by this call.
+	AcquireBlob(b *models.Blob) (bool, error)
+	FindBlob(id string) (*models.Blob, error)
+	// ReleaseBlob drops a reference and returns the blob with its new count.
+	ReleaseBlob(id string) (*models.Blob, error)
+	// MarkBlobDeleting marks an unreferenced blob as being deleted and
+	// returns it, nil if it is referenced, gone or marked already.
+	MarkBlobDeleting(id string) (*models.Blob, error)
+	DeleteBlobIfUnreferenced(id string) (bool, error)
+	// WalkBlobs calls fn for every blob document.
+	WalkBlobs(fn func(b *models.Blob) error) error
+	// SetBlobRefCount replaces the reference count if it still equals from.
+	SetBlobRefCount(id string, from, to int64) (bool, error)
+	// MarkBlobVerified records the outcome of re-hashing the blob.
+	MarkBlobVerified(id string, corrupt bool) error
+	// AddBlobRendition records a rendition of the blob unless it has max
+	// others already. It reports whether the rendition is recorded.
+	AddBlobRendition(id, name string, max int) (bool, error)
+}
@@ -0,0 +1,23 @@
+package repository
+
+//go:generate mockery --name=ContentRepository --output=mocks --outpkg=mocks
+
+import "server/internal/models"
+
+type ContentRepository interface {
+	// HasContent reports whether the owner's content with the digest is
+	// indexed.
+	HasContent(ownerID, digest string) (bool, error)
+	// PutContent stores c, replacing an earlier index of the same content.
+	PutContent(c *models.ContentIndex) error
+	DeleteContent(ownerID, digest string) error
+	// SearchContent returns up to limit indexed contents of the owner that
+	// have all of the tokens, the most recently indexed first, following
+	// after, the last one of the previous page, unless it is nil.
+	SearchContent(ownerID string, tokens []string, after *models.ContentIndex, limit int) ([]*models.ContentIndex, error)
+}
+
+// ContentID is the ID of the index of the owner's content with the digest.
+func ContentID(ownerID, digest string) string {
+	return ownerID + "." + digest
+}
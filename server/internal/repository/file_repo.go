@@ -3,14 +3,119 @@ package repository
 //go:generate mockery --name=FileRepository --output=mocks --outpkg=mocks
 
 import (
+	"errors"
+	"time"
+
 	"server/internal/models"
 )
 
+var (
+	// ErrNoTransactions is returned by Transaction when MongoDB does not
+	// run as a replica set.
+	ErrNoTransactions = errors.New("transactions need MongoDB to run as a replica set")
+	// ErrNodeNotFound is returned by updates of a node the owner does not
+	// have outside the trash.
+	ErrNodeNotFound = errors.New("node not found")
+)
+
 type FileRepository interface {
 	CreateNode(n *models.Node) error
+	// FindNodeByID, FindNodesByIDs and ListChildren do not return nodes in
+	// the trash.
 	FindNodeByID(id string) (*models.Node, error)
+	FindNodesByIDs(ids []string) ([]*models.Node, error)
+	// FindFilesByDigests returns the owner's files whose current content
+	// has one of the digests.
+	FindFilesByDigests(ownerID string, digests []string) ([]*models.Node, error)
+	// HasFileWithDigest reports whether a file of the owner, also one in
+	// the trash, has the digest as its current content.
+	HasFileWithDigest(ownerID, digest string) (bool, error)
 	ListChildren(ownerID, parentID string) ([]*models.Node, error)
+	// ListChildFolderIDs returns the IDs of the folders directly in the
+	// owner's folders parentIDs.
+	ListChildFolderIDs(ownerID string, parentIDs []string) ([]string, error)
+	// SumChildFiles counts the files directly in the owner's folders
+	// parentIDs and sums up their sizes.
+	SumChildFiles(ownerID string, parentIDs []string) (files int, size int64, err error)
+	// ListChildrenPage returns the children matching q in its order and,
+	// if there are more, the cursor of the next page. Returns
+	// ErrInvalidCursor for a cursor of another query.
+	ListChildrenPage(q ChildrenQuery) ([]*models.Node, string, error)
+	// SearchNodes is ListChildrenPage for the nodes matching q anywhere.
+	// Nodes that have no name key yet are only found without a name.
+	SearchNodes(q SearchQuery) ([]*models.Node, string, error)
 	DeleteNode(id string) error
+	// PurgeNode is DeleteNode reporting whether this call deleted the node,
+	// so that only one caller releases what it held.
+	PurgeNode(id string) (bool, error)
 	UpdateNode(n *models.Node) error
-	UpdateNodeParent(ownerID, nodeID, parentID string) error
+	// CreateNode, MoveNode, RenameNode and RestoreNodes return ErrNameTaken
+	// when a sibling already has the name; MoveNode and RenameNode return
+	// ErrNodeNotFound when there is no such node.
+	MoveNode(ownerID, nodeID, parentID, name string) error
+	RenameNode(ownerID, nodeID, name string) error
+	// FindChildByName returns the node in parentID whose name has the
+	// NameKey of name, nil if there is none.
+	FindChildByName(ownerID, parentID, name string) (*models.Node, error)
+	// TagNodes adds the tags to the owner's nodes that are not in the trash
+	// and returns how many nodes it found.
+	TagNodes(ownerID string, nodeIDs, tagIDs []string) (int64, error)
+	// UntagNodes removes the tags from the owner's nodes, from every node,
+	// also those in the trash, if nodeIDs is nil.
+	UntagNodes(ownerID string, nodeIDs, tagIDs []string) (int64, error)
+	// RetagNodes gives every node of the owner tagged fromID the tag toID
+	// instead.
+	RetagNodes(ownerID, fromID, toID string) error
+	// CountTags returns how many nodes not in the trash have each tag.
+	CountTags(ownerID string) (map[string]int, error)
+	// SetStarred stars or unstars the owner's node if it is not in the
+	// trash; found is false if there is no such node.
+	SetStarred(ownerID, nodeID string, starred bool) (found bool, err error)
+	// UpdateMetadata sets the description, unless it is nil, and the keys of
+	// set and removes the keys of unset from the metadata of the owner's
+	// node if it is not in the trash and was last updated at
+	// prevUpdatedAt; it reports whether it was. An empty description
+	// removes it.
+	UpdateMetadata(ownerID, nodeID string, prevUpdatedAt time.Time, description *string, set map[string]interface{}, unset []string) (bool, error)
+	// MetadataKeyInUse reports whether a node of the owner, in the trash
+	// or not, has a value for the metadata key, only counting values that
+	// are not of the type otherThan ("string", "number" or "boolean")
+	// unless it is empty.
+	MetadataKeyInUse(ownerID, key, otherThan string) (bool, error)
+	// IndexName gives a node stored without a name key its key, storing it
+	// under name. Nodes that already have a key are left alone.
+	IndexName(n *models.Node, name string) error
+	// IndexNameGrams stores the search n-grams of the node's name.
+	IndexNameGrams(n *models.Node) error
+	// WalkNodes calls fn for every node of every owner.
+	WalkNodes(fn func(n *models.Node) error) error
+	// SetNodeBlob moves a file node stored at oldPath into the blob b.
+	SetNodeBlob(nodeID, oldPath string, b *models.Blob) (bool, error)
+	// SetCorruptByBlob flags or unflags every file node stored in the blob.
+	SetCorruptByBlob(blobID string, corrupt bool) error
+	// UpdateNodeContent stores the content fields and versions of n if the
+	// node was not changed since prevUpdatedAt.
+	UpdateNodeContent(n *models.Node, prevUpdatedAt time.Time) (bool, error)
+	// WalkVersionedNodes calls fn for every file node that has versions.
+	WalkVersionedNodes(fn func(n *models.Node) error) error
+
+	// TrashNodes moves the node rootID and its descendants to the trash.
+	TrashNodes(ownerID, rootID, trashPath string, descendantIDs []string, at time.Time) error
+	// FindTrashRoot returns a node the user deleted, nil if it is not in
+	// the trash or was only deleted along with a folder.
+	FindTrashRoot(id string) (*models.Node, error)
+	// ListTrash returns the nodes the owner deleted, newest first.
+	ListTrash(ownerID string) ([]*models.Node, error)
+	ListTrashedWith(rootID string) ([]*models.Node, error)
+	// ListExpiredTrash returns the deleted nodes of every owner trashed
+	// before the given time.
+	ListExpiredTrash(before time.Time) ([]*models.Node, error)
+	// RestoreNodes takes rootID and its descendants out of the trash,
+	// placing rootID in parentID under name.
+	RestoreNodes(ownerID, rootID, parentID, name string) error
+
+	// Transaction runs fn in a transaction; what fn does through tx is
+	// applied all or nothing. fn may be called again if the transaction has
+	// to be retried. Returns ErrNoTransactions if the database cannot.
+	Transaction(fn func(tx FileRepository) error) error
 }
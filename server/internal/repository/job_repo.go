@@ -0,0 +1,17 @@
+package repository
+
+//go:generate mockery --name=JobRepository --output=mocks --outpkg=mocks
+
+import "server/internal/models"
+
+type JobRepository interface {
+	CreateJob(j *models.Job) error
+	FindJob(id string) (*models.Job, error)
+	UpdateJobProgress(id string, done int) error
+	SetJobTotal(id string, total int) error
+	// FinishJob records the final status; resultID and errMsg may be empty.
+	FinishJob(id, status, resultID, errMsg string) error
+	// FailRunningJobs marks every running job failed with errMsg and
+	// returns how many there were.
+	FailRunningJobs(errMsg string) (int64, error)
+}
@@ -0,0 +1,14 @@
+package repository
+
+//go:generate mockery --name=KeyRepository --output=mocks --outpkg=mocks
+
+import "server/internal/models"
+
+type KeyRepository interface {
+	CreateDataKey(k *models.DataKey) error
+	FindDataKey(id string) (*models.DataKey, error)
+	FindDataKeyByOwner(ownerID string) (*models.DataKey, error)
+	ListDataKeysNotWrappedBy(masterKeyID string) ([]*models.DataKey, error)
+	// RewrapDataKey replaces the wrapped key only if it is still wrapped by oldMasterKeyID.
+	RewrapDataKey(id, oldMasterKeyID, newMasterKeyID string, wrapped []byte) (bool, error)
+}
@@ -0,0 +1,23 @@
+package repository
+
+//go:generate mockery --name=MetadataKeyRepository --output=mocks --outpkg=mocks
+
+import "server/internal/models"
+
+type MetadataKeyRepository interface {
+	// ListMetadataKeys returns the owner's key definitions by key.
+	ListMetadataKeys(ownerID string) ([]*models.MetadataKey, error)
+	FindMetadataKey(ownerID, key string) (*models.MetadataKey, error)
+	// AddMetadataKey stores k unless the owner has defined the key already,
+	// and returns the definition in effect.
+	AddMetadataKey(k *models.MetadataKey) (*models.MetadataKey, error)
+	// PutMetadataKey stores k, replacing the type of an earlier definition.
+	PutMetadataKey(k *models.MetadataKey) error
+	// DeleteMetadataKey reports whether there was a definition to delete.
+	DeleteMetadataKey(ownerID, key string) (bool, error)
+}
+
+// MetadataKeyID is the ID of the definition of the owner's metadata key.
+func MetadataKeyID(ownerID, key string) string {
+	return ownerID + "." + key
+}
@@ -4,6 +4,8 @@ package mocks
 
 import (
 	models "server/internal/models"
+	repository "server/internal/repository"
+	time "time"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -13,6 +15,36 @@ type FileRepository struct {
 	mock.Mock
 }
 
+// CountTags provides a mock function with given fields: ownerID
+func (_m *FileRepository) CountTags(ownerID string) (map[string]int, error) {
+	ret := _m.Called(ownerID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountTags")
+	}
+
+	var r0 map[string]int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (map[string]int, error)); ok {
+		return rf(ownerID)
+	}
+	if rf, ok := ret.Get(0).(func(string) map[string]int); ok {
+		r0 = rf(ownerID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]int)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(ownerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateNode provides a mock function with given fields: n
 func (_m *FileRepository) CreateNode(n *models.Node) error {
 	ret := _m.Called(n)
@@ -49,6 +81,66 @@ func (_m *FileRepository) DeleteNode(id string) error {
 	return r0
 }
 
+// FindChildByName provides a mock function with given fields: ownerID, parentID, name
+func (_m *FileRepository) FindChildByName(ownerID string, parentID string, name string) (*models.Node, error) {
+	ret := _m.Called(ownerID, parentID, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindChildByName")
+	}
+
+	var r0 *models.Node
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, string) (*models.Node, error)); ok {
+		return rf(ownerID, parentID, name)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, string) *models.Node); ok {
+		r0 = rf(ownerID, parentID, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Node)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, string) error); ok {
+		r1 = rf(ownerID, parentID, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindFilesByDigests provides a mock function with given fields: ownerID, digests
+func (_m *FileRepository) FindFilesByDigests(ownerID string, digests []string) ([]*models.Node, error) {
+	ret := _m.Called(ownerID, digests)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindFilesByDigests")
+	}
+
+	var r0 []*models.Node
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, []string) ([]*models.Node, error)); ok {
+		return rf(ownerID, digests)
+	}
+	if rf, ok := ret.Get(0).(func(string, []string) []*models.Node); ok {
+		r0 = rf(ownerID, digests)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Node)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, []string) error); ok {
+		r1 = rf(ownerID, digests)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FindNodeByID provides a mock function with given fields: id
 func (_m *FileRepository) FindNodeByID(id string) (*models.Node, error) {
 	ret := _m.Called(id)
@@ -79,6 +171,160 @@ func (_m *FileRepository) FindNodeByID(id string) (*models.Node, error) {
 	return r0, r1
 }
 
+// FindNodesByIDs provides a mock function with given fields: ids
+func (_m *FileRepository) FindNodesByIDs(ids []string) ([]*models.Node, error) {
+	ret := _m.Called(ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindNodesByIDs")
+	}
+
+	var r0 []*models.Node
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]string) ([]*models.Node, error)); ok {
+		return rf(ids)
+	}
+	if rf, ok := ret.Get(0).(func([]string) []*models.Node); ok {
+		r0 = rf(ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Node)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]string) error); ok {
+		r1 = rf(ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindTrashRoot provides a mock function with given fields: id
+func (_m *FileRepository) FindTrashRoot(id string) (*models.Node, error) {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindTrashRoot")
+	}
+
+	var r0 *models.Node
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (*models.Node, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(string) *models.Node); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Node)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HasFileWithDigest provides a mock function with given fields: ownerID, digest
+func (_m *FileRepository) HasFileWithDigest(ownerID string, digest string) (bool, error) {
+	ret := _m.Called(ownerID, digest)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HasFileWithDigest")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string) (bool, error)); ok {
+		return rf(ownerID, digest)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) bool); ok {
+		r0 = rf(ownerID, digest)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(ownerID, digest)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IndexName provides a mock function with given fields: n, name
+func (_m *FileRepository) IndexName(n *models.Node, name string) error {
+	ret := _m.Called(n, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IndexName")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*models.Node, string) error); ok {
+		r0 = rf(n, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// IndexNameGrams provides a mock function with given fields: n
+func (_m *FileRepository) IndexNameGrams(n *models.Node) error {
+	ret := _m.Called(n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IndexNameGrams")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*models.Node) error); ok {
+		r0 = rf(n)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ListChildFolderIDs provides a mock function with given fields: ownerID, parentIDs
+func (_m *FileRepository) ListChildFolderIDs(ownerID string, parentIDs []string) ([]string, error) {
+	ret := _m.Called(ownerID, parentIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListChildFolderIDs")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, []string) ([]string, error)); ok {
+		return rf(ownerID, parentIDs)
+	}
+	if rf, ok := ret.Get(0).(func(string, []string) []string); ok {
+		r0 = rf(ownerID, parentIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, []string) error); ok {
+		r1 = rf(ownerID, parentIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListChildren provides a mock function with given fields: ownerID, parentID
 func (_m *FileRepository) ListChildren(ownerID string, parentID string) ([]*models.Node, error) {
 	ret := _m.Called(ownerID, parentID)
@@ -109,35 +355,602 @@ func (_m *FileRepository) ListChildren(ownerID string, parentID string) ([]*mode
 	return r0, r1
 }
 
-// UpdateNode provides a mock function with given fields: n
-func (_m *FileRepository) UpdateNode(n *models.Node) error {
-	ret := _m.Called(n)
+// ListChildrenPage provides a mock function with given fields: q
+func (_m *FileRepository) ListChildrenPage(q repository.ChildrenQuery) ([]*models.Node, string, error) {
+	ret := _m.Called(q)
 
 	if len(ret) == 0 {
-		panic("no return value specified for UpdateNode")
+		panic("no return value specified for ListChildrenPage")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(*models.Node) error); ok {
-		r0 = rf(n)
+	var r0 []*models.Node
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(repository.ChildrenQuery) ([]*models.Node, string, error)); ok {
+		return rf(q)
+	}
+	if rf, ok := ret.Get(0).(func(repository.ChildrenQuery) []*models.Node); ok {
+		r0 = rf(q)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Node)
+		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(repository.ChildrenQuery) string); ok {
+		r1 = rf(q)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(repository.ChildrenQuery) error); ok {
+		r2 = rf(q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// UpdateNodeParent provides a mock function with given fields: ownerID, nodeID, parentID
-func (_m *FileRepository) UpdateNodeParent(ownerID string, nodeID string, parentID string) error {
-	ret := _m.Called(ownerID, nodeID, parentID)
+// ListExpiredTrash provides a mock function with given fields: before
+func (_m *FileRepository) ListExpiredTrash(before time.Time) ([]*models.Node, error) {
+	ret := _m.Called(before)
 
 	if len(ret) == 0 {
-		panic("no return value specified for UpdateNodeParent")
+		panic("no return value specified for ListExpiredTrash")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(string, string, string) error); ok {
-		r0 = rf(ownerID, nodeID, parentID)
+	var r0 []*models.Node
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time) ([]*models.Node, error)); ok {
+		return rf(before)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time) []*models.Node); ok {
+		r0 = rf(before)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Node)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListTrash provides a mock function with given fields: ownerID
+func (_m *FileRepository) ListTrash(ownerID string) ([]*models.Node, error) {
+	ret := _m.Called(ownerID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListTrash")
+	}
+
+	var r0 []*models.Node
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]*models.Node, error)); ok {
+		return rf(ownerID)
+	}
+	if rf, ok := ret.Get(0).(func(string) []*models.Node); ok {
+		r0 = rf(ownerID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Node)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(ownerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListTrashedWith provides a mock function with given fields: rootID
+func (_m *FileRepository) ListTrashedWith(rootID string) ([]*models.Node, error) {
+	ret := _m.Called(rootID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListTrashedWith")
+	}
+
+	var r0 []*models.Node
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]*models.Node, error)); ok {
+		return rf(rootID)
+	}
+	if rf, ok := ret.Get(0).(func(string) []*models.Node); ok {
+		r0 = rf(rootID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Node)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(rootID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MetadataKeyInUse provides a mock function with given fields: ownerID, key, otherThan
+func (_m *FileRepository) MetadataKeyInUse(ownerID string, key string, otherThan string) (bool, error) {
+	ret := _m.Called(ownerID, key, otherThan)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MetadataKeyInUse")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, string) (bool, error)); ok {
+		return rf(ownerID, key, otherThan)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, string) bool); ok {
+		r0 = rf(ownerID, key, otherThan)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, string) error); ok {
+		r1 = rf(ownerID, key, otherThan)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MoveNode provides a mock function with given fields: ownerID, nodeID, parentID, name
+func (_m *FileRepository) MoveNode(ownerID string, nodeID string, parentID string, name string) error {
+	ret := _m.Called(ownerID, nodeID, parentID, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MoveNode")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, string) error); ok {
+		r0 = rf(ownerID, nodeID, parentID, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PurgeNode provides a mock function with given fields: id
+func (_m *FileRepository) PurgeNode(id string) (bool, error) {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeNode")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (bool, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RenameNode provides a mock function with given fields: ownerID, nodeID, name
+func (_m *FileRepository) RenameNode(ownerID string, nodeID string, name string) error {
+	ret := _m.Called(ownerID, nodeID, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RenameNode")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string) error); ok {
+		r0 = rf(ownerID, nodeID, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RestoreNodes provides a mock function with given fields: ownerID, rootID, parentID, name
+func (_m *FileRepository) RestoreNodes(ownerID string, rootID string, parentID string, name string) error {
+	ret := _m.Called(ownerID, rootID, parentID, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreNodes")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, string) error); ok {
+		r0 = rf(ownerID, rootID, parentID, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RetagNodes provides a mock function with given fields: ownerID, fromID, toID
+func (_m *FileRepository) RetagNodes(ownerID string, fromID string, toID string) error {
+	ret := _m.Called(ownerID, fromID, toID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RetagNodes")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string) error); ok {
+		r0 = rf(ownerID, fromID, toID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SearchNodes provides a mock function with given fields: q
+func (_m *FileRepository) SearchNodes(q repository.SearchQuery) ([]*models.Node, string, error) {
+	ret := _m.Called(q)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SearchNodes")
+	}
+
+	var r0 []*models.Node
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(repository.SearchQuery) ([]*models.Node, string, error)); ok {
+		return rf(q)
+	}
+	if rf, ok := ret.Get(0).(func(repository.SearchQuery) []*models.Node); ok {
+		r0 = rf(q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Node)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(repository.SearchQuery) string); ok {
+		r1 = rf(q)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(repository.SearchQuery) error); ok {
+		r2 = rf(q)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SetCorruptByBlob provides a mock function with given fields: blobID, corrupt
+func (_m *FileRepository) SetCorruptByBlob(blobID string, corrupt bool) error {
+	ret := _m.Called(blobID, corrupt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetCorruptByBlob")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, bool) error); ok {
+		r0 = rf(blobID, corrupt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetNodeBlob provides a mock function with given fields: nodeID, oldPath, b
+func (_m *FileRepository) SetNodeBlob(nodeID string, oldPath string, b *models.Blob) (bool, error) {
+	ret := _m.Called(nodeID, oldPath, b)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetNodeBlob")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, *models.Blob) (bool, error)); ok {
+		return rf(nodeID, oldPath, b)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, *models.Blob) bool); ok {
+		r0 = rf(nodeID, oldPath, b)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, *models.Blob) error); ok {
+		r1 = rf(nodeID, oldPath, b)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetStarred provides a mock function with given fields: ownerID, nodeID, starred
+func (_m *FileRepository) SetStarred(ownerID string, nodeID string, starred bool) (bool, error) {
+	ret := _m.Called(ownerID, nodeID, starred)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetStarred")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, bool) (bool, error)); ok {
+		return rf(ownerID, nodeID, starred)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, bool) bool); ok {
+		r0 = rf(ownerID, nodeID, starred)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, bool) error); ok {
+		r1 = rf(ownerID, nodeID, starred)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SumChildFiles provides a mock function with given fields: ownerID, parentIDs
+func (_m *FileRepository) SumChildFiles(ownerID string, parentIDs []string) (int, int64, error) {
+	ret := _m.Called(ownerID, parentIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SumChildFiles")
+	}
+
+	var r0 int
+	var r1 int64
+	var r2 error
+	if rf, ok := ret.Get(0).(func(string, []string) (int, int64, error)); ok {
+		return rf(ownerID, parentIDs)
+	}
+	if rf, ok := ret.Get(0).(func(string, []string) int); ok {
+		r0 = rf(ownerID, parentIDs)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, []string) int64); ok {
+		r1 = rf(ownerID, parentIDs)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	if rf, ok := ret.Get(2).(func(string, []string) error); ok {
+		r2 = rf(ownerID, parentIDs)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// TagNodes provides a mock function with given fields: ownerID, nodeIDs, tagIDs
+func (_m *FileRepository) TagNodes(ownerID string, nodeIDs []string, tagIDs []string) (int64, error) {
+	ret := _m.Called(ownerID, nodeIDs, tagIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TagNodes")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, []string, []string) (int64, error)); ok {
+		return rf(ownerID, nodeIDs, tagIDs)
+	}
+	if rf, ok := ret.Get(0).(func(string, []string, []string) int64); ok {
+		r0 = rf(ownerID, nodeIDs, tagIDs)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, []string, []string) error); ok {
+		r1 = rf(ownerID, nodeIDs, tagIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Transaction provides a mock function with given fields: fn
+func (_m *FileRepository) Transaction(fn func(tx repository.FileRepository) error) error {
+	ret := _m.Called(fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Transaction")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(func(tx repository.FileRepository) error) error); ok {
+		r0 = rf(fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// TrashNodes provides a mock function with given fields: ownerID, rootID, trashPath, descendantIDs, at
+func (_m *FileRepository) TrashNodes(ownerID string, rootID string, trashPath string, descendantIDs []string, at time.Time) error {
+	ret := _m.Called(ownerID, rootID, trashPath, descendantIDs, at)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TrashNodes")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, []string, time.Time) error); ok {
+		r0 = rf(ownerID, rootID, trashPath, descendantIDs, at)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UntagNodes provides a mock function with given fields: ownerID, nodeIDs, tagIDs
+func (_m *FileRepository) UntagNodes(ownerID string, nodeIDs []string, tagIDs []string) (int64, error) {
+	ret := _m.Called(ownerID, nodeIDs, tagIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UntagNodes")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, []string, []string) (int64, error)); ok {
+		return rf(ownerID, nodeIDs, tagIDs)
+	}
+	if rf, ok := ret.Get(0).(func(string, []string, []string) int64); ok {
+		r0 = rf(ownerID, nodeIDs, tagIDs)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, []string, []string) error); ok {
+		r1 = rf(ownerID, nodeIDs, tagIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateMetadata provides a mock function with given fields: ownerID, nodeID, prevUpdatedAt, description, set, unset
+func (_m *FileRepository) UpdateMetadata(ownerID string, nodeID string, prevUpdatedAt time.Time, description *string, set map[string]interface{}, unset []string) (bool, error) {
+	ret := _m.Called(ownerID, nodeID, prevUpdatedAt, description, set, unset)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateMetadata")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, time.Time, *string, map[string]interface{}, []string) (bool, error)); ok {
+		return rf(ownerID, nodeID, prevUpdatedAt, description, set, unset)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, time.Time, *string, map[string]interface{}, []string) bool); ok {
+		r0 = rf(ownerID, nodeID, prevUpdatedAt, description, set, unset)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, time.Time, *string, map[string]interface{}, []string) error); ok {
+		r1 = rf(ownerID, nodeID, prevUpdatedAt, description, set, unset)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateNode provides a mock function with given fields: n
+func (_m *FileRepository) UpdateNode(n *models.Node) error {
+	ret := _m.Called(n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateNode")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*models.Node) error); ok {
+		r0 = rf(n)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateNodeContent provides a mock function with given fields: n, prevUpdatedAt
+func (_m *FileRepository) UpdateNodeContent(n *models.Node, prevUpdatedAt time.Time) (bool, error) {
+	ret := _m.Called(n, prevUpdatedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateNodeContent")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*models.Node, time.Time) (bool, error)); ok {
+		return rf(n, prevUpdatedAt)
+	}
+	if rf, ok := ret.Get(0).(func(*models.Node, time.Time) bool); ok {
+		r0 = rf(n, prevUpdatedAt)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(*models.Node, time.Time) error); ok {
+		r1 = rf(n, prevUpdatedAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// WalkNodes provides a mock function with given fields: fn
+func (_m *FileRepository) WalkNodes(fn func(n *models.Node) error) error {
+	ret := _m.Called(fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WalkNodes")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(func(n *models.Node) error) error); ok {
+		r0 = rf(fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// WalkVersionedNodes provides a mock function with given fields: fn
+func (_m *FileRepository) WalkVersionedNodes(fn func(n *models.Node) error) error {
+	ret := _m.Called(fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WalkVersionedNodes")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(func(n *models.Node) error) error); ok {
+		r0 = rf(fn)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -162,6 +162,82 @@ func (_m *UserRepository) FindUserIDByRefreshToken(tokenHash string) (string, in
 	return r0, r1, r2
 }
 
+// ListUsersWithAvatar provides a mock function with given fields:
+func (_m *UserRepository) ListUsersWithAvatar() ([]*models.User, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListUsersWithAvatar")
+	}
+
+	var r0 []*models.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]*models.User, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []*models.User); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetAvatarBlob provides a mock function with given fields: userID, oldKey, b
+func (_m *UserRepository) SetAvatarBlob(userID string, oldKey string, b *models.Blob) (bool, error) {
+	ret := _m.Called(userID, oldKey, b)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetAvatarBlob")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, *models.Blob) (bool, error)); ok {
+		return rf(userID, oldKey, b)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, *models.Blob) bool); ok {
+		r0 = rf(userID, oldKey, b)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, *models.Blob) error); ok {
+		r1 = rf(userID, oldKey, b)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetVersioning provides a mock function with given fields: userID, v
+func (_m *UserRepository) SetVersioning(userID string, v *models.VersioningSettings) error {
+	ret := _m.Called(userID, v)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetVersioning")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, *models.VersioningSettings) error); ok {
+		r0 = rf(userID, v)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // StoreRefreshToken provides a mock function with given fields: tokenHash, userID, expiresAt
 func (_m *UserRepository) StoreRefreshToken(tokenHash string, userID string, expiresAt int64) error {
 	ret := _m.Called(tokenHash, userID, expiresAt)
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoBlobRepo struct {
+	col *mongo.Collection
+}
+
+func NewMongoBlobRepo(client *mongo.Client, dbName string) (*MongoBlobRepo, error) {
+	col := client.Database(dbName).Collection("blobs")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "ref_count", Value: 1}},
+	})
+	return &MongoBlobRepo{col: col}, nil
+}
+
+func (r *MongoBlobRepo) AcquireBlob(b *models.Blob) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	now := time.Now()
+	update := bson.M{
+		"$inc": bson.M{"ref_count": 1},
+		"$set": bson.M{"updated_at": now},
+		"$setOnInsert": bson.M{
+			"digest":     b.Digest,
+			"key_id":     b.KeyID,
+			"md5":        b.MD5,
+			"crc32c":     b.CRC32C,
+			"key":        b.Key,
+			"size":       b.Size,
+			"created_at": now,
+		},
+	}
+	// a blob being deleted does not match, so the upsert fails on its id
+	filter := bson.M{"_id": b.ID, "deleting_at": bson.M{"$exists": false}}
+	res, err := r.col.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, ErrBlobDeleting
+	}
+	if err != nil {
+		return false, err
+	}
+	return res.UpsertedCount > 0, nil
+}
+
+func (r *MongoBlobRepo) FindBlob(id string) (*models.Blob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var b models.Blob
+	if err := r.col.FindOne(ctx, bson.M{"_id": id}).Decode(&b); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *MongoBlobRepo) ReleaseBlob(id string) (*models.Blob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	update := bson.M{
+		"$inc": bson.M{"ref_count": -1},
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var b models.Blob
+	if err := r.col.FindOneAndUpdate(ctx, bson.M{"_id": id, "ref_count": bson.M{"$gt": 0}}, update, opts).Decode(&b); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *MongoBlobRepo) MarkBlobDeleting(id string) (*models.Blob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	filter := bson.M{"_id": id, "ref_count": bson.M{"$lte": 0}, "deleting_at": bson.M{"$exists": false}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var b models.Blob
+	if err := r.col.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"deleting_at": time.Now()}}, opts).Decode(&b); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *MongoBlobRepo) DeleteBlobIfUnreferenced(id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := r.col.DeleteOne(ctx, bson.M{"_id": id, "ref_count": bson.M{"$lte": 0}})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+func (r *MongoBlobRepo) WalkBlobs(fn func(b *models.Blob) error) error {
+	ctx := context.Background()
+	cur, err := r.col.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var b models.Blob
+		if err := cur.Decode(&b); err != nil {
+			return err
+		}
+		if err := fn(&b); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func (r *MongoBlobRepo) SetBlobRefCount(id string, from, to int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"ref_count": to, "updated_at": time.Now()}}
+	res, err := r.col.UpdateOne(ctx, bson.M{"_id": id, "ref_count": from, "deleting_at": bson.M{"$exists": false}}, update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (r *MongoBlobRepo) MarkBlobVerified(id string, corrupt bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"corrupt": corrupt, "verified_at": time.Now()}}
+	_, err := r.col.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+func (r *MongoBlobRepo) AddBlobRendition(id, name string, max int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	filter := bson.M{"_id": id, "$or": bson.A{
+		bson.M{"renditions": name},
+		bson.M{fmt.Sprintf("renditions.%d", max-1): bson.M{"$exists": false}},
+	}}
+	res, err := r.col.UpdateOne(ctx, filter, bson.M{"$addToSet": bson.M{"renditions": name}})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
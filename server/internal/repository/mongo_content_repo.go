@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoContentRepo struct {
+	col *mongo.Collection
+}
+
+func NewMongoContentRepo(client *mongo.Client, dbName string) (*MongoContentRepo, error) {
+	col := client.Database(dbName).Collection("contents")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "tokens", Value: 1}},
+		Options: options.Index().SetBackground(true),
+	})
+	// searches read the newest contents first
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "indexed_at", Value: -1}, {Key: "_id", Value: 1}},
+		Options: options.Index().SetBackground(true),
+	})
+	return &MongoContentRepo{col: col}, nil
+}
+
+func (r *MongoContentRepo) HasContent(ownerID, digest string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	n, err := r.col.CountDocuments(ctx, bson.M{"_id": ContentID(ownerID, digest)}, options.Count().SetLimit(1))
+	return n > 0, err
+}
+
+func (r *MongoContentRepo) PutContent(c *models.ContentIndex) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	c.ID = ContentID(c.OwnerID, c.Digest)
+	c.IndexedAt = time.Now()
+	_, err := r.col.ReplaceOne(ctx, bson.M{"_id": c.ID}, c, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *MongoContentRepo) DeleteContent(ownerID, digest string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := r.col.DeleteOne(ctx, bson.M{"_id": ContentID(ownerID, digest)})
+	return err
+}
+
+func (r *MongoContentRepo) SearchContent(ownerID string, tokens []string, after *models.ContentIndex, limit int) ([]*models.ContentIndex, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	filter := bson.M{"owner_id": ownerID, "tokens": bson.M{"$all": tokens}}
+	if after != nil {
+		filter["$or"] = bson.A{
+			bson.M{"indexed_at": bson.M{"$lt": after.IndexedAt}},
+			bson.M{"indexed_at": after.IndexedAt, "_id": bson.M{"$gt": after.ID}},
+		}
+	}
+	opts := options.Find().
+		SetProjection(bson.M{"tokens": 0}).
+		SetSort(bson.D{{Key: "indexed_at", Value: -1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+	cur, err := r.col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []*models.ContentIndex
+	for cur.Next(ctx) {
+		var c models.ContentIndex
+		if err := cur.Decode(&c); err != nil {
+			return nil, err
+		}
+		out = append(out, &c)
+	}
+	return out, cur.Err()
+}
@@ -15,6 +15,8 @@ import (
 
 type MongoFileRepo struct {
 	col *mongo.Collection
+	// sc is set on the copy of the repo handed to a Transaction callback
+	sc mongo.SessionContext
 }
 
 func NewMongoFileRepo(client *mongo.Client, dbName string) (*MongoFileRepo, error) {
@@ -26,38 +28,151 @@ func NewMongoFileRepo(client *mongo.Client, dbName string) (*MongoFileRepo, erro
 		Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "parent_id", Value: 1}},
 		Options: options.Index().SetBackground(true),
 	})
+	// nodes in the trash and nodes stored before name keys were introduced
+	// have no name_key, see IndexName
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "parent_id", Value: 1}, {Key: "name_key", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"name_key": bson.M{"$exists": true}}),
+	})
+	// the orders of ListChildrenPage; type -1 puts folders first
+	for _, keys := range []bson.D{
+		{{Key: "name", Value: 1}},
+		{{Key: "type", Value: -1}, {Key: "name", Value: 1}},
+		{{Key: "size", Value: 1}},
+		{{Key: "type", Value: -1}, {Key: "size", Value: 1}},
+		{{Key: "mime", Value: 1}, {Key: "name", Value: 1}},
+		{{Key: "created_at", Value: 1}},
+		{{Key: "updated_at", Value: 1}},
+	} {
+		keys = append(bson.D{{Key: "owner_id", Value: 1}, {Key: "parent_id", Value: 1}}, keys...)
+		_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    append(keys, bson.E{Key: "_id", Value: 1}),
+			Options: options.Index().SetCollation(listCollation),
+		})
+	}
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "name_grams", Value: 1}},
+		Options: options.Index().SetCollation(listCollation),
+	})
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "digest", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "tags", Value: 1}},
+		Options: options.Index().SetSparse(true).SetCollation(listCollation),
+	})
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "starred", Value: 1}},
+		Options: options.Index().SetSparse(true).SetCollation(listCollation),
+	})
+	// metadata keys are chosen by the users
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "metadata.$**", Value: 1}},
+		Options: options.Index().SetCollation(listCollation),
+	})
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "trashed_with", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "trashed_at", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
 	return &MongoFileRepo{col: col}, nil
 }
 
-func (r *MongoFileRepo) UpdateNodeParent(ownerID, nodeID, parentID string) error {
+func (r *MongoFileRepo) ctx() context.Context {
+	if r.sc != nil {
+		return r.sc
+	}
+	return context.Background()
+}
 
-	oid, err := primitive.ObjectIDFromHex(nodeID)
+func (r *MongoFileRepo) Transaction(fn func(tx FileRepository) error) error {
+	sess, err := r.col.Database().Client().StartSession()
 	if err != nil {
 		return err
 	}
+	defer sess.EndSession(context.Background())
+	_, err = sess.WithTransaction(context.Background(), func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(&MongoFileRepo{col: r.col, sc: sc})
+	})
+	var ce mongo.CommandError
+	if errors.As(err, &ce) && ce.Code == 20 { // IllegalOperation: standalone server
+		return ErrNoTransactions
+	}
+	return err
+}
 
-	filter := bson.M{
-		"_id":      oid,
-		"owner_id": ownerID,
+func (r *MongoFileRepo) MoveNode(ownerID, nodeID, parentID, name string) error {
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(nodeID)
+	if err != nil {
+		return ErrNodeNotFound
 	}
+	filter := bson.M{"_id": oid, "owner_id": ownerID, "trashed_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"name": name, "name_key": NameKey(name), "name_grams": NameGrams(name), "updated_at": time.Now()}}
+	setParent(update, parentID)
+	res, err := r.col.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nameError(err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNodeNotFound
+	}
+	return nil
+}
 
-	update := bson.M{"$set": bson.M{"parent_id": parentID, "updated_at": time.Now()}}
+// setParent adds parentID to an update; root nodes have no parent_id.
+func setParent(update bson.M, parentID string) {
+	if parentID == "" {
+		unset, _ := update["$unset"].(bson.M)
+		if unset == nil {
+			unset = bson.M{}
+			update["$unset"] = unset
+		}
+		unset["parent_id"] = ""
+		return
+	}
+	update["$set"].(bson.M)["parent_id"] = parentValue(parentID)
+}
 
-	res, err := r.col.UpdateOne(context.Background(), filter, update)
+func (r *MongoFileRepo) RenameNode(ownerID, nodeID, name string) error {
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(nodeID)
 	if err != nil {
-		return err
+		return ErrNodeNotFound
+	}
+	filter := bson.M{"_id": oid, "owner_id": ownerID, "trashed_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"name": name, "name_key": NameKey(name), "name_grams": NameGrams(name), "updated_at": time.Now()}}
+	res, err := r.col.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nameError(err)
 	}
 	if res.MatchedCount == 0 {
-		return errors.New("node not found or not owner")
+		return ErrNodeNotFound
 	}
 	return nil
 }
 
+// NewNodeID returns a new id to create a node with.
+func NewNodeID() string {
+	return primitive.NewObjectID().Hex()
+}
+
+// CreateNode inserts n under n.ID if it is set, see NewNodeID, and under a
+// new id otherwise.
 func (r *MongoFileRepo) CreateNode(n *models.Node) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
 	defer cancel()
 	n.CreatedAt = time.Now()
 	n.UpdatedAt = n.CreatedAt
+	n.NameKey = NameKey(n.Name)
+	n.NameGrams = NameGrams(n.Name)
 
 	raw, err := bson.Marshal(n)
 	if err != nil {
@@ -69,16 +184,19 @@ func (r *MongoFileRepo) CreateNode(n *models.Node) error {
 	}
 
 	if pid, ok := doc["parent_id"].(string); ok && pid != "" {
-		if poid, err := primitive.ObjectIDFromHex(pid); err == nil {
-			doc["parent_id"] = poid
-		} else {
-			doc["parent_id"] = pid
+		doc["parent_id"] = parentValue(pid)
+	}
+	if id, ok := doc["_id"].(string); ok {
+		oid, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return errors.New("invalid node id")
 		}
+		doc["_id"] = oid
 	}
 
 	res, err := r.col.InsertOne(ctx, doc)
 	if err != nil {
-		return err
+		return nameError(err)
 	}
 	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
 		n.ID = oid.Hex()
@@ -87,14 +205,16 @@ func (r *MongoFileRepo) CreateNode(n *models.Node) error {
 }
 
 func (r *MongoFileRepo) FindNodeByID(id string) (*models.Node, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
 	defer cancel()
 	oid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, err
+		// no node has such an id
+		return nil, nil
 	}
 	var n models.Node
-	if err := r.col.FindOne(ctx, bson.M{"_id": oid}).Decode(&n); err != nil {
+	filter := bson.M{"_id": oid, "trashed_at": bson.M{"$exists": false}}
+	if err := r.col.FindOne(ctx, filter).Decode(&n); err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil
 		}
@@ -104,10 +224,121 @@ func (r *MongoFileRepo) FindNodeByID(id string) (*models.Node, error) {
 }
 
 func (r *MongoFileRepo) ListChildren(ownerID, parentID string) ([]*models.Node, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
 	defer cancel()
 
-	filter := bson.M{"owner_id": ownerID}
+	cur, err := r.col.Find(ctx, childrenFilter(ownerID, parentID))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []*models.Node
+	for cur.Next(ctx) {
+		var n models.Node
+		if err := cur.Decode(&n); err != nil {
+			return nil, err
+		}
+		out = append(out, &n)
+	}
+	return out, nil
+}
+
+func (r *MongoFileRepo) ListChildFolderIDs(ownerID string, parentIDs []string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 30*time.Second)
+	defer cancel()
+	filter := parentsFilter(ownerID, parentIDs)
+	filter["type"] = "folder"
+	cur, err := r.col.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var ids []string
+	for cur.Next(ctx) {
+		var row struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, err
+		}
+		ids = append(ids, row.ID.Hex())
+	}
+	return ids, cur.Err()
+}
+
+func (r *MongoFileRepo) SumChildFiles(ownerID string, parentIDs []string) (int, int64, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 30*time.Second)
+	defer cancel()
+	filter := parentsFilter(ownerID, parentIDs)
+	filter["type"] = "file"
+	cur, err := r.col.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "files": bson.M{"$sum": 1}, "size": bson.M{"$sum": "$size"}}}},
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cur.Close(ctx)
+
+	var row struct {
+		Files int   `bson:"files"`
+		Size  int64 `bson:"size"`
+	}
+	if cur.Next(ctx) {
+		if err := cur.Decode(&row); err != nil {
+			return 0, 0, err
+		}
+	}
+	return row.Files, row.Size, cur.Err()
+}
+
+func (r *MongoFileRepo) ListChildrenPage(q ChildrenQuery) ([]*models.Node, string, error) {
+	filter := withConditions(childrenFilter(q.OwnerID, q.ParentID), nodeFilter(q.NodeFilter))
+	return r.listPage(filter, q.ListOptions)
+}
+
+func (r *MongoFileRepo) SearchNodes(q SearchQuery) ([]*models.Node, string, error) {
+	return r.listPage(searchFilter(q), q.ListOptions)
+}
+
+// listPage returns the nodes matching filter in the order of o, a page of
+// them if o has a limit.
+func (r *MongoFileRepo) listPage(filter bson.M, o ListOptions) ([]*models.Node, string, error) {
+	if o.Sort == "" {
+		o.Sort = SortName
+	}
+	keys, err := sortKeys(o)
+	if err != nil {
+		return nil, "", err
+	}
+	if o.Cursor != "" {
+		cur, err := decodeCursor(o)
+		if err != nil {
+			return nil, "", err
+		}
+		filter = bson.M{"$and": []bson.M{filter, afterCursor(keys, cur)}}
+	}
+	opts := options.Find().SetSort(sortDoc(keys)).SetCollation(listCollation)
+	if o.Limit > 0 {
+		// one more tells whether there is a next page
+		opts.SetLimit(int64(o.Limit) + 1)
+	}
+	nodes, err := r.findNodes(filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	if o.Limit <= 0 || len(nodes) <= o.Limit {
+		return nodes, "", nil
+	}
+	nodes = nodes[:o.Limit]
+	return nodes, encodeCursor(o, nodes[len(nodes)-1]), nil
+}
+
+// childrenFilter matches the nodes in parentID that are not in the trash.
+func childrenFilter(ownerID, parentID string) bson.M {
+	filter := bson.M{"owner_id": ownerID, "trashed_at": bson.M{"$exists": false}}
 	if parentID == "" {
 		filter["parent_id"] = bson.M{"$exists": false}
 	} else {
@@ -120,26 +351,231 @@ func (r *MongoFileRepo) ListChildren(ownerID, parentID string) ([]*models.Node,
 			filter["parent_id"] = parentID
 		}
 	}
+	return filter
+}
 
-	cur, err := r.col.Find(ctx, filter)
+// parentsFilter is childrenFilter for the children of several folders.
+func parentsFilter(ownerID string, parentIDs []string) bson.M {
+	parents := make([]interface{}, 0, 2*len(parentIDs))
+	for _, id := range parentIDs {
+		parents = append(parents, parentValue(id), id)
+	}
+	return bson.M{"owner_id": ownerID, "parent_id": bson.M{"$in": parents}, "trashed_at": bson.M{"$exists": false}}
+}
+
+func (r *MongoFileRepo) FindChildByName(ownerID, parentID, name string) (*models.Node, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
+	defer cancel()
+	filter := childrenFilter(ownerID, parentID)
+	filter["name_key"] = NameKey(name)
+	var n models.Node
+	if err := r.col.FindOne(ctx, filter).Decode(&n); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (r *MongoFileRepo) FindNodesByIDs(ids []string) ([]*models.Node, error) {
+	return r.findNodes(bson.M{"_id": bson.M{"$in": objectIDs(ids)}, "trashed_at": bson.M{"$exists": false}})
+}
+
+func (r *MongoFileRepo) FindFilesByDigests(ownerID string, digests []string) ([]*models.Node, error) {
+	filter := bson.M{"owner_id": ownerID, "digest": bson.M{"$in": digests}, "trashed_at": bson.M{"$exists": false}}
+	return r.findNodes(filter)
+}
+
+func (r *MongoFileRepo) HasFileWithDigest(ownerID, digest string) (bool, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
+	defer cancel()
+	n, err := r.col.CountDocuments(ctx, bson.M{"owner_id": ownerID, "digest": digest}, options.Count().SetLimit(1))
+	return n > 0, err
+}
+
+func (r *MongoFileRepo) TagNodes(ownerID string, nodeIDs, tagIDs []string) (int64, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 30*time.Second)
+	defer cancel()
+	filter := bson.M{"_id": bson.M{"$in": objectIDs(nodeIDs)}, "owner_id": ownerID, "trashed_at": bson.M{"$exists": false}}
+	res, err := r.col.UpdateMany(ctx, filter, bson.M{"$addToSet": bson.M{"tags": bson.M{"$each": tagIDs}}})
+	if err != nil {
+		return 0, err
+	}
+	return res.MatchedCount, nil
+}
+
+func (r *MongoFileRepo) UntagNodes(ownerID string, nodeIDs, tagIDs []string) (int64, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 30*time.Second)
+	defer cancel()
+	filter := bson.M{"owner_id": ownerID, "tags": bson.M{"$in": tagIDs}}
+	if nodeIDs != nil {
+		filter["_id"] = bson.M{"$in": objectIDs(nodeIDs)}
+		filter["trashed_at"] = bson.M{"$exists": false}
+	}
+	res, err := r.col.UpdateMany(ctx, filter, bson.M{"$pull": bson.M{"tags": bson.M{"$in": tagIDs}}})
+	if err != nil {
+		return 0, err
+	}
+	return res.MatchedCount, nil
+}
+
+func (r *MongoFileRepo) RetagNodes(ownerID, fromID, toID string) error {
+	ctx, cancel := context.WithTimeout(r.ctx(), 30*time.Second)
+	defer cancel()
+	filter := bson.M{"owner_id": ownerID, "tags": fromID}
+	if _, err := r.col.UpdateMany(ctx, filter, bson.M{"$addToSet": bson.M{"tags": toID}}); err != nil {
+		return err
+	}
+	_, err := r.col.UpdateMany(ctx, filter, bson.M{"$pull": bson.M{"tags": fromID}})
+	return err
+}
+
+func (r *MongoFileRepo) SetStarred(ownerID, nodeID string, starred bool) (bool, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(nodeID)
+	if err != nil {
+		return false, nil
+	}
+	update := bson.M{"$set": bson.M{"starred": true}}
+	if !starred {
+		update = bson.M{"$unset": bson.M{"starred": ""}}
+	}
+	filter := bson.M{"_id": oid, "owner_id": ownerID, "trashed_at": bson.M{"$exists": false}}
+	res, err := r.col.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (r *MongoFileRepo) UpdateMetadata(ownerID, nodeID string, prevUpdatedAt time.Time, description *string, set map[string]interface{}, unset []string) (bool, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(nodeID)
+	if err != nil {
+		return false, nil
+	}
+	setFields := bson.M{"updated_at": time.Now()}
+	unsetFields := bson.M{}
+	if description != nil {
+		if *description != "" {
+			setFields["description"] = *description
+		} else {
+			unsetFields["description"] = ""
+		}
+	}
+	for k, v := range set {
+		setFields["metadata."+k] = v
+	}
+	for _, k := range unset {
+		unsetFields["metadata."+k] = ""
+	}
+	update := bson.M{"$set": setFields}
+	if len(unsetFields) > 0 {
+		update["$unset"] = unsetFields
+	}
+	filter := bson.M{"_id": oid, "owner_id": ownerID, "updated_at": prevUpdatedAt, "trashed_at": bson.M{"$exists": false}}
+	res, err := r.col.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// metadataBSONTypes maps the metadata value types to the BSON types $type
+// matches them by.
+var metadataBSONTypes = map[string]string{"string": "string", "number": "number", "boolean": "bool"}
+
+func (r *MongoFileRepo) MetadataKeyInUse(ownerID, key, otherThan string) (bool, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 30*time.Second)
+	defer cancel()
+	field := bson.M{"$exists": true}
+	if t, ok := metadataBSONTypes[otherThan]; ok {
+		field["$not"] = bson.M{"$type": t}
+	}
+	n, err := r.col.CountDocuments(ctx, bson.M{"owner_id": ownerID, "metadata." + key: field}, options.Count().SetLimit(1))
+	return n > 0, err
+}
+
+func (r *MongoFileRepo) CountTags(ownerID string) (map[string]int, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 30*time.Second)
+	defer cancel()
+	cur, err := r.col.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"owner_id": ownerID, "tags.0": bson.M{"$exists": true}, "trashed_at": bson.M{"$exists": false}}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+	})
 	if err != nil {
 		return nil, err
 	}
 	defer cur.Close(ctx)
 
-	var out []*models.Node
+	counts := map[string]int{}
 	for cur.Next(ctx) {
-		var n models.Node
-		if err := cur.Decode(&n); err != nil {
+		var row struct {
+			ID    string `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		if err := cur.Decode(&row); err != nil {
 			return nil, err
 		}
-		out = append(out, &n)
+		counts[row.ID] = row.Count
 	}
-	return out, nil
+	return counts, cur.Err()
+}
+
+// objectIDs converts node IDs, skipping invalid ones.
+func objectIDs(ids []string) []primitive.ObjectID {
+	oids := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+			oids = append(oids, oid)
+		}
+	}
+	return oids
+}
+
+func (r *MongoFileRepo) IndexName(n *models.Node, name string) error {
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(n.ID)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"name": name, "name_key": NameKey(name), "name_grams": NameGrams(name)}}
+	setParent(update, n.ParentID)
+	res, err := r.col.UpdateOne(ctx, bson.M{"_id": oid, "name_key": bson.M{"$exists": false}}, update)
+	if err != nil {
+		return nameError(err)
+	}
+	if res.MatchedCount > 0 {
+		n.Name = name
+		n.NameKey = NameKey(name)
+		n.NameGrams = NameGrams(name)
+	}
+	return nil
+}
+
+func (r *MongoFileRepo) IndexNameGrams(n *models.Node) error {
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(n.ID)
+	if err != nil {
+		return err
+	}
+	grams := NameGrams(n.Name)
+	_, err = r.col.UpdateOne(ctx, bson.M{"_id": oid, "name": n.Name}, bson.M{"$set": bson.M{"name_grams": grams}})
+	if err != nil {
+		return err
+	}
+	n.NameGrams = grams
+	return nil
 }
 
 func (r *MongoFileRepo) DeleteNode(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
 	defer cancel()
 	oid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -152,14 +588,254 @@ func (r *MongoFileRepo) DeleteNode(id string) error {
 	return nil
 }
 
+func (r *MongoFileRepo) PurgeNode(id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	res, err := r.col.DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
 func (r *MongoFileRepo) UpdateNode(n *models.Node) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
 	defer cancel()
 	oid, err := primitive.ObjectIDFromHex(n.ID)
 	if err != nil {
 		return err
 	}
 	n.UpdatedAt = time.Now()
+	if n.TrashedAt == nil || n.TrashedWith != "" {
+		n.NameKey = NameKey(n.Name)
+	}
+	n.NameGrams = NameGrams(n.Name)
 	_, err = r.col.ReplaceOne(ctx, bson.M{"_id": oid}, n)
+	return nameError(err)
+}
+
+func (r *MongoFileRepo) WalkNodes(fn func(n *models.Node) error) error {
+	return r.walk(bson.M{}, fn)
+}
+
+func (r *MongoFileRepo) WalkVersionedNodes(fn func(n *models.Node) error) error {
+	return r.walk(bson.M{"versions.0": bson.M{"$exists": true}}, fn)
+}
+
+func (r *MongoFileRepo) walk(filter bson.M, fn func(n *models.Node) error) error {
+	ctx := r.ctx()
+	cur, err := r.col.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var n models.Node
+		if err := cur.Decode(&n); err != nil {
+			return err
+		}
+		if err := fn(&n); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func (r *MongoFileRepo) SetCorruptByBlob(blobID string, corrupt bool) error {
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
+	defer cancel()
+	// nodes stored before encryption refer to their blob by digest only
+	filter := bson.M{"$or": []bson.M{
+		{"blob_id": blobID},
+		{"blob_id": bson.M{"$exists": false}, "digest": blobID},
+	}}
+	update := bson.M{"$set": bson.M{"corrupt": true}}
+	if !corrupt {
+		update = bson.M{"$unset": bson.M{"corrupt": ""}}
+	}
+	_, err := r.col.UpdateMany(ctx, filter, update)
 	return err
 }
+
+func (r *MongoFileRepo) UpdateNodeContent(n *models.Node, prevUpdatedAt time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(n.ID)
+	if err != nil {
+		return false, err
+	}
+	n.UpdatedAt = time.Now()
+	set := bson.M{
+		"size":       n.Size,
+		"mime":       n.Mime,
+		"path":       n.Path,
+		"digest":     n.Digest,
+		"md5":        n.MD5,
+		"crc32c":     n.CRC32C,
+		"blob_id":    n.BlobID,
+		"updated_at": n.UpdatedAt,
+	}
+	unset := bson.M{}
+	if n.Corrupt {
+		set["corrupt"] = true
+	} else {
+		unset["corrupt"] = ""
+	}
+	if len(n.Versions) > 0 {
+		set["versions"] = n.Versions
+	} else {
+		unset["versions"] = ""
+	}
+	filter := bson.M{"_id": oid, "updated_at": prevUpdatedAt, "trashed_at": bson.M{"$exists": false}}
+	res, err := r.col.UpdateOne(ctx, filter, bson.M{"$set": set, "$unset": unset})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (r *MongoFileRepo) SetNodeBlob(nodeID, oldPath string, b *models.Blob) (bool, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(nodeID)
+	if err != nil {
+		return false, err
+	}
+	filter := bson.M{"_id": oid, "path": oldPath, "blob_id": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{
+		"path":    b.Key,
+		"size":    b.Size,
+		"digest":  b.Digest,
+		"md5":     b.MD5,
+		"crc32c":  b.CRC32C,
+		"blob_id": b.ID,
+	}}
+	res, err := r.col.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (r *MongoFileRepo) TrashNodes(ownerID, rootID, trashPath string, descendantIDs []string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(r.ctx(), 30*time.Second)
+	defer cancel()
+	rootOID, err := primitive.ObjectIDFromHex(rootID)
+	if err != nil {
+		return err
+	}
+	// descendants first: if the root update is lost they come back with a
+	// retried delete or restore of the root
+	if len(descendantIDs) > 0 {
+		oids := make([]primitive.ObjectID, 0, len(descendantIDs))
+		for _, id := range descendantIDs {
+			oid, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				return err
+			}
+			oids = append(oids, oid)
+		}
+		filter := bson.M{"_id": bson.M{"$in": oids}, "owner_id": ownerID}
+		update := bson.M{"$set": bson.M{"trashed_at": at, "trashed_with": rootID}}
+		if _, err := r.col.UpdateMany(ctx, filter, update); err != nil {
+			return err
+		}
+	}
+	set := bson.M{"trashed_at": at}
+	if trashPath != "" {
+		set["trash_path"] = trashPath
+	}
+	// the name is free for a new node while this one is in the trash
+	update := bson.M{"$set": set, "$unset": bson.M{"name_key": ""}}
+	res, err := r.col.UpdateOne(ctx, bson.M{"_id": rootOID, "owner_id": ownerID}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("node not found or not owner")
+	}
+	return nil
+}
+
+func (r *MongoFileRepo) FindTrashRoot(id string) (*models.Node, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var n models.Node
+	filter := bson.M{"_id": oid, "trashed_at": bson.M{"$exists": true}, "trashed_with": bson.M{"$exists": false}}
+	if err := r.col.FindOne(ctx, filter).Decode(&n); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (r *MongoFileRepo) ListTrash(ownerID string) ([]*models.Node, error) {
+	filter := bson.M{"owner_id": ownerID, "trashed_at": bson.M{"$exists": true}, "trashed_with": bson.M{"$exists": false}}
+	return r.findNodes(filter, options.Find().SetSort(bson.D{{Key: "trashed_at", Value: -1}}))
+}
+
+func (r *MongoFileRepo) ListTrashedWith(rootID string) ([]*models.Node, error) {
+	return r.findNodes(bson.M{"trashed_with": rootID})
+}
+
+func (r *MongoFileRepo) ListExpiredTrash(before time.Time) ([]*models.Node, error) {
+	filter := bson.M{"trashed_at": bson.M{"$lt": before}, "trashed_with": bson.M{"$exists": false}}
+	return r.findNodes(filter)
+}
+
+func (r *MongoFileRepo) RestoreNodes(ownerID, rootID, parentID, name string) error {
+	ctx, cancel := context.WithTimeout(r.ctx(), 30*time.Second)
+	defer cancel()
+	rootOID, err := primitive.ObjectIDFromHex(rootID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	update := bson.M{
+		"$set":   bson.M{"name": name, "name_key": NameKey(name), "name_grams": NameGrams(name), "updated_at": now},
+		"$unset": bson.M{"trashed_at": "", "trash_path": ""},
+	}
+	setParent(update, parentID)
+	res, err := r.col.UpdateOne(ctx, bson.M{"_id": rootOID, "owner_id": ownerID}, update)
+	if err != nil {
+		return nameError(err)
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("node not found or not owner")
+	}
+	_, err = r.col.UpdateMany(ctx, bson.M{"trashed_with": rootID, "owner_id": ownerID}, bson.M{
+		"$unset": bson.M{"trashed_at": "", "trashed_with": ""},
+	})
+	return err
+}
+
+func (r *MongoFileRepo) findNodes(filter bson.M, opts ...*options.FindOptions) ([]*models.Node, error) {
+	ctx, cancel := context.WithTimeout(r.ctx(), 30*time.Second)
+	defer cancel()
+	cur, err := r.col.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []*models.Node
+	for cur.Next(ctx) {
+		var n models.Node
+		if err := cur.Decode(&n); err != nil {
+			return nil, err
+		}
+		out = append(out, &n)
+	}
+	return out, cur.Err()
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoJobRepo struct {
+	col *mongo.Collection
+}
+
+func NewMongoJobRepo(client *mongo.Client, dbName string) (*MongoJobRepo, error) {
+	col := client.Database(dbName).Collection("jobs")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// finished jobs are dropped by mongo once they expire
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return &MongoJobRepo{col: col}, nil
+}
+
+func (r *MongoJobRepo) CreateJob(j *models.Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	j.CreatedAt = time.Now()
+	j.UpdatedAt = j.CreatedAt
+	_, err := r.col.InsertOne(ctx, j)
+	return err
+}
+
+func (r *MongoJobRepo) FindJob(id string) (*models.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var j models.Job
+	if err := r.col.FindOne(ctx, bson.M{"_id": id}).Decode(&j); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (r *MongoJobRepo) UpdateJobProgress(id string, done int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"done": done, "updated_at": time.Now()}}
+	_, err := r.col.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+func (r *MongoJobRepo) SetJobTotal(id string, total int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"total": total, "updated_at": time.Now()}}
+	_, err := r.col.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+func (r *MongoJobRepo) FinishJob(id, status, resultID, errMsg string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	set := bson.M{"status": status, "updated_at": time.Now()}
+	if resultID != "" {
+		set["result_id"] = resultID
+	}
+	if errMsg != "" {
+		set["error"] = errMsg
+	}
+	_, err := r.col.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	return err
+}
+
+func (r *MongoJobRepo) FailRunningJobs(errMsg string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"status": models.JobFailed, "error": errMsg, "updated_at": time.Now()}}
+	res, err := r.col.UpdateMany(ctx, bson.M{"status": models.JobRunning}, update)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoKeyRepo struct {
+	col *mongo.Collection
+}
+
+func NewMongoKeyRepo(client *mongo.Client, dbName string) (*MongoKeyRepo, error) {
+	col := client.Database(dbName).Collection("data_keys")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "master_key_id", Value: 1}},
+	})
+	return &MongoKeyRepo{col: col}, nil
+}
+
+func (r *MongoKeyRepo) CreateDataKey(k *models.DataKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	k.CreatedAt = time.Now()
+	k.UpdatedAt = k.CreatedAt
+	res, err := r.col.InsertOne(ctx, k)
+	if err != nil {
+		return err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		k.ID = oid.Hex()
+	}
+	return nil
+}
+
+func (r *MongoKeyRepo) FindDataKey(id string) (*models.DataKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var k models.DataKey
+	if err := r.col.FindOne(ctx, bson.M{"_id": oid}).Decode(&k); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *MongoKeyRepo) FindDataKeyByOwner(ownerID string) (*models.DataKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var k models.DataKey
+	if err := r.col.FindOne(ctx, bson.M{"owner_id": ownerID}).Decode(&k); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *MongoKeyRepo) ListDataKeysNotWrappedBy(masterKeyID string) ([]*models.DataKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cur, err := r.col.Find(ctx, bson.M{"master_key_id": bson.M{"$ne": masterKeyID}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []*models.DataKey
+	for cur.Next(ctx) {
+		var k models.DataKey
+		if err := cur.Decode(&k); err != nil {
+			return nil, err
+		}
+		out = append(out, &k)
+	}
+	return out, nil
+}
+
+func (r *MongoKeyRepo) RewrapDataKey(id, oldMasterKeyID, newMasterKeyID string, wrapped []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	update := bson.M{"$set": bson.M{
+		"wrapped_key":   wrapped,
+		"master_key_id": newMasterKeyID,
+		"updated_at":    time.Now(),
+	}}
+	res, err := r.col.UpdateOne(ctx, bson.M{"_id": oid, "master_key_id": oldMasterKeyID}, update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoMetadataKeyRepo struct {
+	col *mongo.Collection
+}
+
+func NewMongoMetadataKeyRepo(client *mongo.Client, dbName string) (*MongoMetadataKeyRepo, error) {
+	col := client.Database(dbName).Collection("metadata_keys")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "key", Value: 1}},
+	})
+	return &MongoMetadataKeyRepo{col: col}, nil
+}
+
+func (r *MongoMetadataKeyRepo) ListMetadataKeys(ownerID string) ([]*models.MetadataKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	opts := options.Find().SetSort(bson.D{{Key: "key", Value: 1}})
+	cur, err := r.col.Find(ctx, bson.M{"owner_id": ownerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []*models.MetadataKey
+	for cur.Next(ctx) {
+		var k models.MetadataKey
+		if err := cur.Decode(&k); err != nil {
+			return nil, err
+		}
+		out = append(out, &k)
+	}
+	return out, cur.Err()
+}
+
+func (r *MongoMetadataKeyRepo) FindMetadataKey(ownerID, key string) (*models.MetadataKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var k models.MetadataKey
+	if err := r.col.FindOne(ctx, bson.M{"_id": MetadataKeyID(ownerID, key)}).Decode(&k); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *MongoMetadataKeyRepo) AddMetadataKey(k *models.MetadataKey) (*models.MetadataKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	now := time.Now()
+	update := bson.M{"$setOnInsert": bson.M{
+		"owner_id":   k.OwnerID,
+		"key":        k.Key,
+		"type":       k.Type,
+		"created_at": now,
+		"updated_at": now,
+	}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var stored models.MetadataKey
+	err := r.col.FindOneAndUpdate(ctx, bson.M{"_id": MetadataKeyID(k.OwnerID, k.Key)}, update, opts).Decode(&stored)
+	if mongo.IsDuplicateKeyError(err) {
+		// inserted concurrently
+		return r.FindMetadataKey(k.OwnerID, k.Key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (r *MongoMetadataKeyRepo) PutMetadataKey(k *models.MetadataKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	k.ID = MetadataKeyID(k.OwnerID, k.Key)
+	k.UpdatedAt = time.Now()
+	update := bson.M{
+		"$set":         bson.M{"owner_id": k.OwnerID, "key": k.Key, "type": k.Type, "updated_at": k.UpdatedAt},
+		"$setOnInsert": bson.M{"created_at": k.UpdatedAt},
+	}
+	_, err := r.col.UpdateOne(ctx, bson.M{"_id": k.ID}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *MongoMetadataKeyRepo) DeleteMetadataKey(ownerID, key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := r.col.DeleteOne(ctx, bson.M{"_id": MetadataKeyID(ownerID, key)})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoQuotaRepo struct {
+	col *mongo.Collection
+}
+
+func NewMongoQuotaRepo(client *mongo.Client, dbName string) (*MongoQuotaRepo, error) {
+	col := client.Database(dbName).Collection("quotas")
+	return &MongoQuotaRepo{col: col}, nil
+}
+
+func (r *MongoQuotaRepo) FindQuota(ownerID string) (*models.Quota, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var q models.Quota
+	if err := r.col.FindOne(ctx, bson.M{"_id": ownerID}).Decode(&q); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &q, nil
+}
+
+func (r *MongoQuotaRepo) ChargeQuota(ownerID string, bytes, files, maxBytes, maxFiles int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the limit check below cannot upsert, so make sure the counter exists
+	ensure := bson.M{"$setOnInsert": bson.M{"used_bytes": int64(0), "used_files": int64(0), "updated_at": time.Now()}}
+	if _, err := r.col.UpdateOne(ctx, bson.M{"_id": ownerID}, ensure, options.Update().SetUpsert(true)); err != nil {
+		return false, err
+	}
+
+	filter := bson.M{"_id": ownerID}
+	if maxBytes > 0 {
+		filter["used_bytes"] = bson.M{"$lte": maxBytes - bytes}
+	}
+	if maxFiles > 0 {
+		filter["used_files"] = bson.M{"$lte": maxFiles - files}
+	}
+	update := bson.M{
+		"$inc": bson.M{"used_bytes": bytes, "used_files": files},
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+	res, err := r.col.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (r *MongoQuotaRepo) AddUsage(ownerID string, bytes, files int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	update := bson.M{
+		"$inc": bson.M{"used_bytes": bytes, "used_files": files},
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+	_, err := r.col.UpdateOne(ctx, bson.M{"_id": ownerID}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *MongoQuotaRepo) SetUsage(ownerID string, bytes, files int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"used_bytes": bytes, "used_files": files, "updated_at": time.Now()}}
+	_, err := r.col.UpdateOne(ctx, bson.M{"_id": ownerID}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *MongoQuotaRepo) SetQuotaLimits(ownerID string, maxBytes, maxFiles int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	update := bson.M{
+		"$set":         bson.M{"max_bytes": maxBytes, "max_files": maxFiles, "updated_at": time.Now()},
+		"$setOnInsert": bson.M{"used_bytes": int64(0), "used_files": int64(0)},
+	}
+	_, err := r.col.UpdateOne(ctx, bson.M{"_id": ownerID}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *MongoQuotaRepo) ListQuotas() ([]*models.Quota, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cur, err := r.col.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []*models.Quota
+	for cur.Next(ctx) {
+		var q models.Quota
+		if err := cur.Decode(&q); err != nil {
+			return nil, err
+		}
+		out = append(out, &q)
+	}
+	return out, cur.Err()
+}
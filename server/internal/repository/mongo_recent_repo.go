@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// recentRetention is how long a node stays in the recent list after it was
+// last touched.
+const recentRetention = 90 * 24 * time.Hour
+
+type MongoRecentRepo struct {
+	col *mongo.Collection
+}
+
+func NewMongoRecentRepo(client *mongo.Client, dbName string) (*MongoRecentRepo, error) {
+	col := client.Database(dbName).Collection("recent")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "accessed_at", Value: -1}, {Key: "node_id", Value: -1}},
+	})
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "accessed_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(recentRetention / time.Second)),
+	})
+	return &MongoRecentRepo{col: col}, nil
+}
+
+func (r *MongoRecentRepo) TouchRecent(e *models.RecentEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	e.ID = RecentID(e.OwnerID, e.NodeID)
+	_, err := r.col.ReplaceOne(ctx, bson.M{"_id": e.ID}, e, options.Replace().SetUpsert(true))
+	return err
+}
+
+// recentCursor is the position after the last entry of a page.
+type recentCursor struct {
+	AccessedAt time.Time `json:"t"`
+	NodeID     string    `json:"id"`
+}
+
+func (r *MongoRecentRepo) ListRecent(ownerID, nodeType, cursor string, limit int) ([]*models.RecentEntry, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	filter := bson.M{"owner_id": ownerID}
+	if nodeType != "" {
+		filter["type"] = nodeType
+	}
+	if cursor != "" {
+		var after recentCursor
+		raw, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || json.Unmarshal(raw, &after) != nil || after.NodeID == "" {
+			return nil, "", ErrInvalidCursor
+		}
+		filter["$or"] = bson.A{
+			bson.M{"accessed_at": bson.M{"$lt": after.AccessedAt}},
+			bson.M{"accessed_at": after.AccessedAt, "node_id": bson.M{"$lt": after.NodeID}},
+		}
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "accessed_at", Value: -1}, {Key: "node_id", Value: -1}}).
+		SetLimit(int64(limit) + 1)
+	cur, err := r.col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cur.Close(ctx)
+
+	var out []*models.RecentEntry
+	for cur.Next(ctx) {
+		var e models.RecentEntry
+		if err := cur.Decode(&e); err != nil {
+			return nil, "", err
+		}
+		out = append(out, &e)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, "", err
+	}
+	if len(out) <= limit {
+		return out, "", nil
+	}
+	out = out[:limit]
+	last := out[limit-1]
+	raw, _ := json.Marshal(recentCursor{AccessedAt: last.AccessedAt, NodeID: last.NodeID})
+	return out, base64.RawURLEncoding.EncodeToString(raw), nil
+}
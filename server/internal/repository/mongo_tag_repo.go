@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoTagRepo struct {
+	col *mongo.Collection
+}
+
+func NewMongoTagRepo(client *mongo.Client, dbName string) (*MongoTagRepo, error) {
+	col := client.Database(dbName).Collection("tags")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "name_key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return &MongoTagRepo{col: col}, nil
+}
+
+func (r *MongoTagRepo) CreateTag(t *models.Tag) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	t.ID = primitive.NewObjectID().Hex()
+	t.NameKey = NameKey(t.Name)
+	t.CreatedAt = time.Now()
+	t.UpdatedAt = t.CreatedAt
+	if _, err := r.col.InsertOne(ctx, t); err != nil {
+		t.ID = ""
+		return nameError(err)
+	}
+	return nil
+}
+
+func (r *MongoTagRepo) FindTag(id string) (*models.Tag, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var t models.Tag
+	if err := r.col.FindOne(ctx, bson.M{"_id": id}).Decode(&t); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *MongoTagRepo) ListTags(ownerID string) ([]*models.Tag, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}}).SetCollation(listCollation)
+	cur, err := r.col.Find(ctx, bson.M{"owner_id": ownerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []*models.Tag
+	for cur.Next(ctx) {
+		var t models.Tag
+		if err := cur.Decode(&t); err != nil {
+			return nil, err
+		}
+		out = append(out, &t)
+	}
+	return out, cur.Err()
+}
+
+func (r *MongoTagRepo) UpdateTag(t *models.Tag) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	t.NameKey = NameKey(t.Name)
+	t.UpdatedAt = time.Now()
+	_, err := r.col.ReplaceOne(ctx, bson.M{"_id": t.ID}, t)
+	return nameError(err)
+}
+
+func (r *MongoTagRepo) DeleteTag(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := r.col.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
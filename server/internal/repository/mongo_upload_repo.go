@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoUploadRepo struct {
+	col *mongo.Collection
+}
+
+func NewMongoUploadRepo(client *mongo.Client, dbName string) (*MongoUploadRepo, error) {
+	col := client.Database(dbName).Collection("upload_sessions")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "expires_at", Value: 1}},
+	})
+	_, _ = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "owner_id", Value: 1}},
+	})
+	return &MongoUploadRepo{col: col}, nil
+}
+
+func (r *MongoUploadRepo) CreateUpload(u *models.UploadSession) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	u.CreatedAt = time.Now()
+	u.UpdatedAt = u.CreatedAt
+	_, err := r.col.InsertOne(ctx, u)
+	return err
+}
+
+func (r *MongoUploadRepo) FindUpload(id string) (*models.UploadSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var u models.UploadSession
+	if err := r.col.FindOne(ctx, bson.M{"_id": id}).Decode(&u); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *MongoUploadRepo) AdvanceUpload(id string, from, to int64, expiresAt time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"offset": to, "expires_at": expiresAt, "updated_at": time.Now()}}
+	res, err := r.col.UpdateOne(ctx, bson.M{"_id": id, "offset": from}, update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (r *MongoUploadRepo) CompleteUpload(id, nodeID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"node_id": nodeID, "updated_at": time.Now()}}
+	_, err := r.col.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+func (r *MongoUploadRepo) ReserveUploadNode(id, nodeID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"pending_id": nodeID, "updated_at": time.Now()}}
+	_, err := r.col.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+func (r *MongoUploadRepo) DeleteUpload(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := r.col.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (r *MongoUploadRepo) ListExpiredUploads(before time.Time) ([]*models.UploadSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cur, err := r.col.Find(ctx, bson.M{"expires_at": bson.M{"$lt": before}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []*models.UploadSession
+	for cur.Next(ctx) {
+		var u models.UploadSession
+		if err := cur.Decode(&u); err != nil {
+			return nil, err
+		}
+		out = append(out, &u)
+	}
+	return out, nil
+}
+
+func (r *MongoUploadRepo) StagedUploadBytes(ownerID string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"owner_id": ownerID,
+			"node_id":  bson.M{"$exists": false},
+			"parts":    bson.M{"$exists": false},
+		}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$length"}}}},
+	}
+	cur, err := r.col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+	var res struct {
+		Total int64 `bson:"total"`
+	}
+	if cur.Next(ctx) {
+		if err := cur.Decode(&res); err != nil {
+			return 0, err
+		}
+	}
+	return res.Total, cur.Err()
+}
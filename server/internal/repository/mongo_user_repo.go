@@ -154,11 +154,12 @@ func (r *MongoUserRepo) UpdateUser(u *models.User) error {
 
 	update := bson.M{
 		"$set": bson.M{
-			"email":         u.Email,
-			"name":          u.Name,
-			"avatar_url":    u.AvatarURL,
-			"password_hash": u.PasswordHash,
-			"updated_at":    time.Now(),
+			"email":          u.Email,
+			"name":           u.Name,
+			"avatar_url":     u.AvatarURL,
+			"avatar_blob_id": u.AvatarBlobID,
+			"password_hash":  u.PasswordHash,
+			"updated_at":     time.Now(),
 		},
 	}
 
@@ -172,6 +173,9 @@ func (r *MongoUserRepo) UpdateUser(u *models.User) error {
 	if u.AvatarURL == "" {
 		delete(set, "avatar_url")
 	}
+	if u.AvatarBlobID == "" {
+		delete(set, "avatar_blob_id")
+	}
 	if u.PasswordHash == "" {
 		delete(set, "password_hash")
 	}
@@ -186,3 +190,59 @@ func (r *MongoUserRepo) UpdateUser(u *models.User) error {
 	_, err = r.usersCol.UpdateOne(ctx, bson.M{"_id": oid}, update)
 	return err
 }
+
+func (r *MongoUserRepo) ListUsersWithAvatar() ([]*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	filter := bson.M{"avatar_url": bson.M{"$exists": true, "$ne": ""}}
+	projection := bson.M{"avatar_url": 1, "avatar_blob_id": 1}
+	cur, err := r.usersCol.Find(ctx, filter, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var out []*models.User
+	for cur.Next(ctx) {
+		var u models.User
+		if err := cur.Decode(&u); err != nil {
+			return nil, err
+		}
+		out = append(out, &u)
+	}
+	return out, cur.Err()
+}
+
+func (r *MongoUserRepo) SetAvatarBlob(userID, oldKey string, b *models.Blob) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false, err
+	}
+	filter := bson.M{"_id": oid, "avatar_url": oldKey}
+	update := bson.M{"$set": bson.M{"avatar_url": b.Key, "avatar_blob_id": b.ID, "updated_at": time.Now()}}
+	res, err := r.usersCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (r *MongoUserRepo) SetVersioning(userID string, v *models.VersioningSettings) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"versioning": v, "updated_at": time.Now()}}
+	res, err := r.usersCol.UpdateOne(ctx, bson.M{"_id": oid}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
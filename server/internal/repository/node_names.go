@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrNameTaken is returned when a node would get the name of one of its
+// siblings.
+var ErrNameTaken = errors.New("a node with this name already exists")
+
+// NameKey is the form of a node name that has to be unique among its
+// siblings: NFC normalized and case folded, so "Report.pdf" and "report.pdf"
+// clash.
+func NameKey(name string) string {
+	return cases.Fold().String(norm.NFC.String(name))
+}
+
+// NameGrams returns the distinct pairs of adjacent characters of the name
+// key, which SearchNodes looks names up by. Names of one character have
+// none.
+func NameGrams(name string) []string {
+	key := []rune(NameKey(name))
+	var grams []string
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(key); i++ {
+		g := string(key[i : i+2])
+		if !seen[g] {
+			seen[g] = true
+			grams = append(grams, g)
+		}
+	}
+	return grams
+}
+
+// nameError reports a violation of the sibling name index as ErrNameTaken.
+func nameError(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrNameTaken
+	}
+	return err
+}
+
+// parentValue is how parent_id is stored: the ObjectID of the parent, so
+// the same folder is never referenced in two forms.
+func parentValue(parentID string) interface{} {
+	if oid, err := primitive.ObjectIDFromHex(parentID); err == nil {
+		return oid
+	}
+	return parentID
+}
@@ -0,0 +1,336 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not issued for the
+// same folder and order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Sort orders of ListChildrenPage.
+const (
+	SortName    = "name"    // natural order, "file2" before "file10"
+	SortSize    = "size"    // folders count as empty
+	SortType    = "type"    // by MIME type, then name
+	SortCreated = "created" // created_at
+	SortUpdated = "updated" // updated_at
+)
+
+// MaxPageSize is the most children ListChildrenPage returns at once.
+const MaxPageSize = 1000
+
+// listCollation orders names the way people read them: numbers by value and
+// Japanese kana and kanji in dictionary order. Listings are queried with it,
+// so the listing indexes are built with it too.
+var listCollation = &options.Collation{Locale: "ja", NumericOrdering: true}
+
+// ListOptions orders and pages a node listing.
+type ListOptions struct {
+	Sort         string // one of the Sort constants, SortName if empty
+	Desc         bool
+	FoldersFirst bool
+
+	Limit  int    // all nodes if 0
+	Cursor string // from the previous page
+}
+
+// NodeFilter narrows a node listing to some kinds of nodes.
+type NodeFilter struct {
+	Type       string   // "file" or "folder", both if empty
+	Mimes      []string // exact types or prefixes such as "image/*"
+	Extensions []string // without the dot, matched ignoring case
+	Tags       []string // IDs of tags the nodes must all have
+	// Metadata maps metadata keys to values the nodes must have one of,
+	// see MetadataValues. Every key has to match.
+	Metadata map[string][]string
+}
+
+var metadataKey = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidMetadataKey reports whether k may be used as a metadata key: 1 to 64
+// letters, digits, "_" or "-", so it is safe in a field path.
+func ValidMetadataKey(k string) bool {
+	return metadataKey.MatchString(k)
+}
+
+// MetadataValues returns the stored values a metadata filter value v
+// matches: the string itself and the number or boolean it spells.
+func MetadataValues(v string) []interface{} {
+	out := []interface{}{v}
+	if f, err := strconv.ParseFloat(v, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		out = append(out, f)
+	}
+	if v == "true" || v == "false" {
+		out = append(out, v == "true")
+	}
+	return out
+}
+
+// ChildrenQuery selects and orders the children of a folder.
+type ChildrenQuery struct {
+	OwnerID  string
+	ParentID string
+	NodeFilter
+	ListOptions
+}
+
+// SearchQuery selects nodes of an owner wherever they are.
+type SearchQuery struct {
+	OwnerID string
+	// Name is looked for in node names ignoring case, see NameKey.
+	Name   string
+	Prefix bool // names starting with Name rather than containing it
+	// ParentIDs limits the search to the nodes directly in these folders.
+	ParentIDs []string
+	// MinSize and MaxSize bound the file size, MaxSize only if not 0.
+	MinSize, MaxSize int64
+	// UpdatedAfter and UpdatedBefore bound updated_at if not zero.
+	UpdatedAfter, UpdatedBefore time.Time
+	// Starred limits the search to starred nodes.
+	Starred bool
+	NodeFilter
+	ListOptions
+}
+
+// listCursor is the position after the last node of a page.
+type listCursor struct {
+	Sort      string    `json:"s"`
+	Desc      bool      `json:"d,omitempty"`
+	Folders   bool      `json:"f,omitempty"`
+	ID        string    `json:"id"`
+	Type      string    `json:"t"`
+	Name      string    `json:"n,omitempty"`
+	Mime      string    `json:"m,omitempty"`
+	Size      int64     `json:"z,omitempty"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	UpdatedAt time.Time `json:"u,omitempty"`
+}
+
+func encodeCursor(o ListOptions, n *models.Node) string {
+	raw, _ := json.Marshal(listCursor{
+		Sort: o.Sort, Desc: o.Desc, Folders: o.FoldersFirst,
+		ID: n.ID, Type: n.Type, Name: n.Name, Mime: n.Mime, Size: n.Size,
+		CreatedAt: n.CreatedAt, UpdatedAt: n.UpdatedAt,
+	})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(o ListOptions) (*listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(o.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cur listCursor
+	if err := json.Unmarshal(raw, &cur); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cur.Sort != o.Sort || cur.Desc != o.Desc || cur.Folders != o.FoldersFirst {
+		return nil, ErrInvalidCursor
+	}
+	return &cur, nil
+}
+
+// sortKey is one field of a listing order. Zero sizes and empty MIME types
+// are not stored, so their value is nil like that of a missing field.
+type sortKey struct {
+	field string
+	desc  bool
+	value func(c *listCursor) interface{}
+}
+
+func orNil[T comparable](v T) interface{} {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
+
+// sortKeys returns the order of o, ending with _id so that every node has
+// its own position.
+func sortKeys(o ListOptions) ([]sortKey, error) {
+	var keys []sortKey
+	if o.FoldersFirst {
+		// "folder" sorts after "file"
+		keys = append(keys, sortKey{"type", true, func(c *listCursor) interface{} { return c.Type }})
+	}
+	name := sortKey{"name", o.Desc, func(c *listCursor) interface{} { return c.Name }}
+	switch o.Sort {
+	case SortName:
+		keys = append(keys, name)
+	case SortSize:
+		keys = append(keys, sortKey{"size", o.Desc, func(c *listCursor) interface{} { return orNil(c.Size) }})
+	case SortType:
+		keys = append(keys, sortKey{"mime", o.Desc, func(c *listCursor) interface{} { return orNil(c.Mime) }}, name)
+	case SortCreated:
+		keys = append(keys, sortKey{"created_at", o.Desc, func(c *listCursor) interface{} { return c.CreatedAt }})
+	case SortUpdated:
+		keys = append(keys, sortKey{"updated_at", o.Desc, func(c *listCursor) interface{} { return c.UpdatedAt }})
+	default:
+		return nil, errors.New("unknown sort " + o.Sort)
+	}
+	keys = append(keys, sortKey{"_id", o.Desc, func(c *listCursor) interface{} {
+		oid, err := primitive.ObjectIDFromHex(c.ID)
+		if err != nil {
+			return c.ID
+		}
+		return oid
+	}})
+	return keys, nil
+}
+
+func sortDoc(keys []sortKey) bson.D {
+	d := make(bson.D, 0, len(keys))
+	for _, k := range keys {
+		dir := 1
+		if k.desc {
+			dir = -1
+		}
+		d = append(d, bson.E{Key: k.field, Value: dir})
+	}
+	return d
+}
+
+// afterCursor matches the nodes that come after cur in the order of keys:
+// those equal to it in the first keys and past it in the next one.
+func afterCursor(keys []sortKey, cur *listCursor) bson.M {
+	var or []bson.M
+	for i, k := range keys {
+		past := pastValue(k.field, k.value(cur), k.desc)
+		if past == nil {
+			continue
+		}
+		and := make([]bson.M, 0, i+1)
+		for _, prev := range keys[:i] {
+			and = append(and, bson.M{prev.field: prev.value(cur)})
+		}
+		or = append(or, bson.M{"$and": append(and, past)})
+	}
+	if len(or) == 0 {
+		return bson.M{"_id": bson.M{"$exists": false}}
+	}
+	return bson.M{"$or": or}
+}
+
+// pastValue matches values that sort after v. Missing values sort first,
+// and comparisons never match them, so they need their own cases.
+func pastValue(field string, v interface{}, desc bool) bson.M {
+	switch {
+	case v == nil && desc:
+		return nil
+	case v == nil:
+		return bson.M{field: bson.M{"$ne": nil}}
+	case desc:
+		return bson.M{"$or": []bson.M{{field: bson.M{"$lt": v}}, {field: nil}}}
+	default:
+		return bson.M{field: bson.M{"$gt": v}}
+	}
+}
+
+// nodeFilter returns the conditions of f.
+func nodeFilter(f NodeFilter) []bson.M {
+	var and []bson.M
+	if f.Type != "" {
+		and = append(and, bson.M{"type": f.Type})
+	}
+	if len(f.Mimes) > 0 {
+		var or []bson.M
+		for _, m := range f.Mimes {
+			if prefix, ok := strings.CutSuffix(m, "*"); ok {
+				or = append(or, bson.M{"mime": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}})
+			} else {
+				or = append(or, bson.M{"mime": m})
+			}
+		}
+		and = append(and, bson.M{"$or": or})
+	}
+	if len(f.Tags) > 0 {
+		and = append(and, bson.M{"tags": bson.M{"$all": f.Tags}})
+	}
+	keys := make([]string, 0, len(f.Metadata))
+	for k := range f.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var values []interface{}
+		for _, v := range f.Metadata[k] {
+			values = append(values, MetadataValues(v)...)
+		}
+		and = append(and, bson.M{"metadata." + k: bson.M{"$in": values}})
+	}
+	if len(f.Extensions) > 0 {
+		exts := make([]string, len(f.Extensions))
+		for i, e := range f.Extensions {
+			exts[i] = regexp.QuoteMeta(strings.TrimPrefix(e, "."))
+		}
+		and = append(and, bson.M{"name": bson.M{"$regex": `\.(` + strings.Join(exts, "|") + `)$`, "$options": "i"}})
+	}
+	return and
+}
+
+// withConditions adds and to filter.
+func withConditions(filter bson.M, and []bson.M) bson.M {
+	if len(and) > 0 {
+		filter["$and"] = and
+	}
+	return filter
+}
+
+// searchFilter matches the nodes of q. Names are looked up by their
+// n-grams, see NameGrams, which also works for Japanese names that have no
+// spaces between words; the regular expression then drops nodes that have
+// the n-grams in another order.
+func searchFilter(q SearchQuery) bson.M {
+	filter := bson.M{"owner_id": q.OwnerID, "trashed_at": bson.M{"$exists": false}}
+	and := nodeFilter(q.NodeFilter)
+	if key := NameKey(q.Name); key != "" {
+		if grams := NameGrams(q.Name); len(grams) > 0 {
+			filter["name_grams"] = bson.M{"$all": grams}
+		}
+		pattern := regexp.QuoteMeta(key)
+		if q.Prefix {
+			pattern = "^" + pattern
+		}
+		filter["name_key"] = bson.M{"$regex": pattern}
+	}
+	if q.Starred {
+		filter["starred"] = true
+	}
+	if q.ParentIDs != nil {
+		parents := make([]interface{}, 0, 2*len(q.ParentIDs))
+		for _, id := range q.ParentIDs {
+			// see childrenFilter
+			parents = append(parents, parentValue(id), id)
+		}
+		filter["parent_id"] = bson.M{"$in": parents}
+	}
+	if q.MinSize > 0 {
+		and = append(and, bson.M{"size": bson.M{"$gte": q.MinSize}})
+	}
+	if q.MaxSize > 0 {
+		and = append(and, bson.M{"size": bson.M{"$lte": q.MaxSize}})
+	}
+	if !q.UpdatedAfter.IsZero() {
+		and = append(and, bson.M{"updated_at": bson.M{"$gte": q.UpdatedAfter}})
+	}
+	if !q.UpdatedBefore.IsZero() {
+		and = append(and, bson.M{"updated_at": bson.M{"$lt": q.UpdatedBefore}})
+	}
+	return withConditions(filter, and)
+}
@@ -0,0 +1,362 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The tests below run the filters and sort documents of a listing against
+// nodes held in memory, with MongoDB's rules for missing values: they sort
+// before any other value, equal nil and match no comparison.
+
+type storedNode struct {
+	node *models.Node
+	doc  bson.M
+}
+
+func storeNode(t *testing.T, n *models.Node) storedNode {
+	t.Helper()
+	raw, err := bson.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	oid, err := primitive.ObjectIDFromHex(n.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc["_id"] = oid
+	return storedNode{node: n, doc: doc}
+}
+
+// bsonRank orders values of different types like MongoDB does for the
+// types listings sort by.
+func bsonRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case int64:
+		return 1
+	case string:
+		return 2
+	case primitive.ObjectID:
+		return 3
+	case primitive.DateTime:
+		return 4
+	}
+	panic(fmt.Sprintf("unexpected value %T", v))
+}
+
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case time.Time:
+		return primitive.NewDateTimeFromTime(v)
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	}
+	return v
+}
+
+func compareBSON(a, b interface{}) int {
+	a, b = normalize(a), normalize(b)
+	if ra, rb := bsonRank(a), bsonRank(b); ra != rb {
+		return ra - rb
+	}
+	switch a := a.(type) {
+	case int64:
+		return compareOrdered(a, b.(int64))
+	case string:
+		return strings.Compare(a, b.(string))
+	case primitive.ObjectID:
+		return strings.Compare(a.Hex(), b.(primitive.ObjectID).Hex())
+	case primitive.DateTime:
+		return compareOrdered(a, b.(primitive.DateTime))
+	}
+	return 0
+}
+
+func compareOrdered[T int64 | primitive.DateTime](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func matches(doc bson.M, filter bson.M) bool {
+	for field, cond := range filter {
+		switch field {
+		case "$or":
+			any := false
+			for _, f := range cond.([]bson.M) {
+				if matches(doc, f) {
+					any = true
+					break
+				}
+			}
+			if !any {
+				return false
+			}
+			continue
+		case "$and":
+			for _, f := range cond.([]bson.M) {
+				if !matches(doc, f) {
+					return false
+				}
+			}
+			continue
+		}
+		v := doc[field]
+		ops, ok := cond.(bson.M)
+		if !ok {
+			if compareBSON(v, cond) != 0 {
+				return false
+			}
+			continue
+		}
+		for op, arg := range ops {
+			comparable := v != nil && arg != nil && bsonRank(normalize(v)) == bsonRank(normalize(arg))
+			switch op {
+			case "$ne":
+				if compareBSON(v, arg) == 0 {
+					return false
+				}
+			case "$gt":
+				if !comparable || compareBSON(v, arg) <= 0 {
+					return false
+				}
+			case "$lt":
+				if !comparable || compareBSON(v, arg) >= 0 {
+					return false
+				}
+			case "$exists":
+				if _, present := doc[field]; present != arg.(bool) {
+					return false
+				}
+			default:
+				panic("unexpected operator " + op)
+			}
+		}
+	}
+	return true
+}
+
+func sortStored(nodes []storedNode, order bson.D) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		for _, e := range order {
+			c := compareBSON(nodes[i].doc[e.Key], nodes[j].doc[e.Key])
+			if e.Value.(int) < 0 {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+// listPage is MongoFileRepo.listPage over nodes held in memory.
+func listPage(t *testing.T, all []storedNode, o ListOptions) ([]*models.Node, string) {
+	t.Helper()
+	keys, err := sortKeys(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := bson.M{}
+	if o.Cursor != "" {
+		cur, err := decodeCursor(o)
+		if err != nil {
+			t.Fatalf("decode cursor: %v", err)
+		}
+		filter = afterCursor(keys, cur)
+	}
+	var found []storedNode
+	for _, n := range all {
+		if matches(n.doc, filter) {
+			found = append(found, n)
+		}
+	}
+	sortStored(found, sortDoc(keys))
+	var page []*models.Node
+	for _, n := range found {
+		page = append(page, n.node)
+	}
+	if len(page) <= o.Limit {
+		return page, ""
+	}
+	page = page[:o.Limit]
+	return page, encodeCursor(o, page[len(page)-1])
+}
+
+func testNodes(t *testing.T) []storedNode {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	specs := []struct {
+		name, typ, mime string
+		size            int64
+		created, update int // minutes after base
+	}{
+		{"a.txt", "file", "text/plain", 10, 0, 5},
+		{"a.txt", "file", "text/plain", 10, 0, 5},
+		{"b.txt", "file", "text/plain", 0, 1, 5},
+		{"b.txt", "file", "", 0, 1, 6},
+		{"c.png", "file", "image/png", 5, 2, 6},
+		{"c.png", "file", "image/png", 10, 2, 7},
+		{"d", "file", "", 0, 3, 7},
+		{"e.bin", "file", "", 20, 3, 7},
+		{"a.txt", "folder", "", 0, 0, 5},
+		{"docs", "folder", "", 0, 4, 8},
+		{"docs", "folder", "", 0, 4, 8},
+		{"z", "folder", "", 0, 5, 1},
+		{"f.txt", "file", "text/plain", 5, 5, 1},
+		{"g.png", "file", "image/png", 0, 6, 2},
+	}
+	var out []storedNode
+	for i, s := range specs {
+		out = append(out, storeNode(t, &models.Node{
+			ID:        fmt.Sprintf("%024x", i+1),
+			OwnerID:   "owner",
+			Name:      s.name,
+			Type:      s.typ,
+			Mime:      s.mime,
+			Size:      s.size,
+			CreatedAt: base.Add(time.Duration(s.created) * time.Minute),
+			UpdatedAt: base.Add(time.Duration(s.update) * time.Minute),
+		}))
+	}
+	return out
+}
+
+func TestListPagesCoverEveryNodeOnce(t *testing.T) {
+	all := testNodes(t)
+	for _, sortBy := range []string{SortName, SortSize, SortType, SortCreated, SortUpdated} {
+		for _, desc := range []bool{false, true} {
+			for _, foldersFirst := range []bool{false, true} {
+				o := ListOptions{Sort: sortBy, Desc: desc, FoldersFirst: foldersFirst}
+				keys, err := sortKeys(o)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := append([]storedNode(nil), all...)
+				sortStored(want, sortDoc(keys))
+
+				for _, limit := range []int{1, 2, 3, 5} {
+					t.Run(fmt.Sprintf("%s desc=%v folders_first=%v limit=%d", sortBy, desc, foldersFirst, limit), func(t *testing.T) {
+						o := o
+						o.Limit = limit
+						var got []string
+						seen := map[string]bool{}
+						for pages := 0; ; pages++ {
+							if pages > len(all) {
+								t.Fatal("paging does not end")
+							}
+							page, next := listPage(t, all, o)
+							for _, n := range page {
+								if seen[n.ID] {
+									t.Fatalf("node %s (%s) listed twice", n.ID, n.Name)
+								}
+								seen[n.ID] = true
+								got = append(got, n.ID)
+							}
+							if next == "" {
+								break
+							}
+							o.Cursor = next
+						}
+						if len(got) != len(want) {
+							t.Fatalf("listed %d nodes, want %d", len(got), len(want))
+						}
+						for i, n := range want {
+							if got[i] != n.node.ID {
+								t.Fatalf("position %d: got node %s, want %s", i, got[i], n.node.ID)
+							}
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestListOrderPutsMissingValuesFirst(t *testing.T) {
+	all := testNodes(t)
+	ids := func(o ListOptions) []string {
+		keys, err := sortKeys(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes := append([]storedNode(nil), all...)
+		sortStored(nodes, sortDoc(keys))
+		var out []string
+		for _, n := range nodes {
+			out = append(out, fmt.Sprintf("%s:%d", n.node.Name, n.node.Size))
+		}
+		return out
+	}
+
+	asc := ids(ListOptions{Sort: SortSize})
+	// empty sizes are not stored and sort before every size
+	for i, s := range asc[:8] {
+		if !strings.HasSuffix(s, ":0") {
+			t.Fatalf("ascending by size, position %d is %s, want an empty size", i, s)
+		}
+	}
+	if got := asc[len(asc)-1]; got != "e.bin:20" {
+		t.Fatalf("ascending by size ends with %s, want e.bin:20", got)
+	}
+	desc := ids(ListOptions{Sort: SortSize, Desc: true})
+	if got := desc[0]; got != "e.bin:20" {
+		t.Fatalf("descending by size starts with %s, want e.bin:20", got)
+	}
+	for i, s := range desc[len(desc)-8:] {
+		if !strings.HasSuffix(s, ":0") {
+			t.Fatalf("descending by size, position %d from the end is %s, want an empty size", 8-i, s)
+		}
+	}
+
+	keys, err := sortKeys(ListOptions{Sort: SortName, FoldersFirst: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := append([]storedNode(nil), all...)
+	sortStored(nodes, sortDoc(keys))
+	for i, n := range nodes {
+		if want := i < 4; (n.node.Type == "folder") != want {
+			t.Fatalf("folders first, position %d is the %s %s", i, n.node.Type, n.node.Name)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsOtherOrders(t *testing.T) {
+	n := testNodes(t)[0].node
+	cursor := encodeCursor(ListOptions{Sort: SortSize, Desc: true}, n)
+	for _, o := range []ListOptions{
+		{Sort: SortName, Desc: true, Cursor: cursor},
+		{Sort: SortSize, Cursor: cursor},
+		{Sort: SortSize, Desc: true, FoldersFirst: true, Cursor: cursor},
+		{Sort: SortSize, Desc: true, Cursor: "not a cursor"},
+	} {
+		if _, err := decodeCursor(o); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("decodeCursor(%+v): err = %v, want %v", o, err, ErrInvalidCursor)
+		}
+	}
+	if _, err := decodeCursor(ListOptions{Sort: SortSize, Desc: true, Cursor: cursor}); err != nil {
+		t.Errorf("decodeCursor of its own order: %v", err)
+	}
+}
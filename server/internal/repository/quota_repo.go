@@ -0,0 +1,16 @@
+package repository
+
+//go:generate mockery --name=QuotaRepository --output=mocks --outpkg=mocks
+
+import "server/internal/models"
+
+type QuotaRepository interface {
+	FindQuota(ownerID string) (*models.Quota, error)
+	// ChargeQuota adds to the usage unless that would exceed maxBytes or
+	// maxFiles; a limit of zero or less is not checked.
+	ChargeQuota(ownerID string, bytes, files, maxBytes, maxFiles int64) (bool, error)
+	AddUsage(ownerID string, bytes, files int64) error
+	SetUsage(ownerID string, bytes, files int64) error
+	SetQuotaLimits(ownerID string, maxBytes, maxFiles int64) error
+	ListQuotas() ([]*models.Quota, error)
+}
@@ -0,0 +1,19 @@
+package repository
+
+//go:generate mockery --name=RecentRepository --output=mocks --outpkg=mocks
+
+import "server/internal/models"
+
+type RecentRepository interface {
+	// TouchRecent stores e, replacing the owner's entry for the same node.
+	TouchRecent(e *models.RecentEntry) error
+	// ListRecent returns up to limit of the owner's entries, most recent
+	// first, only those of nodeType if it is not empty, and the cursor of
+	// the next page if there are more.
+	ListRecent(ownerID, nodeType, cursor string, limit int) ([]*models.RecentEntry, string, error)
+}
+
+// RecentID is the ID of the owner's entry for the node.
+func RecentID(ownerID, nodeID string) string {
+	return ownerID + "." + nodeID
+}
@@ -10,6 +10,10 @@ type UserRepository interface {
 	FindByEmail(email string) (*models.User, error)
 	FindByID(id string) (*models.User, error)
 	UpdateUser(u *models.User) error
+	ListUsersWithAvatar() ([]*models.User, error)
+	// SetAvatarBlob points the avatar at b if it is still stored at oldKey.
+	SetAvatarBlob(userID, oldKey string, b *models.Blob) (bool, error)
+	SetVersioning(userID string, v *models.VersioningSettings) error
 
 	StoreRefreshToken(tokenHash, userID string, expiresAt int64) error
 	FindUserIDByRefreshToken(tokenHash string) (string, int64, error)
@@ -0,0 +1,16 @@
+package repository
+
+//go:generate mockery --name=TagRepository --output=mocks --outpkg=mocks
+
+import "server/internal/models"
+
+type TagRepository interface {
+	// CreateTag and UpdateTag return ErrNameTaken when the owner already
+	// has a tag of the name.
+	CreateTag(t *models.Tag) error
+	FindTag(id string) (*models.Tag, error)
+	// ListTags returns the owner's tags by name.
+	ListTags(ownerID string) ([]*models.Tag, error)
+	UpdateTag(t *models.Tag) error
+	DeleteTag(id string) error
+}
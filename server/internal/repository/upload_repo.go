@@ -0,0 +1,25 @@
+package repository
+
+//go:generate mockery --name=UploadRepository --output=mocks --outpkg=mocks
+
+import (
+	"time"
+
+	"server/internal/models"
+)
+
+type UploadRepository interface {
+	CreateUpload(u *models.UploadSession) error
+	FindUpload(id string) (*models.UploadSession, error)
+	// AdvanceUpload moves the offset forward only if it still equals from.
+	AdvanceUpload(id string, from, to int64, expiresAt time.Time) (bool, error)
+	CompleteUpload(id, nodeID string) error
+	// ReserveUploadNode records the id the upload's file node is created
+	// with, see repository.NewNodeID.
+	ReserveUploadNode(id, nodeID string) error
+	DeleteUpload(id string) error
+	ListExpiredUploads(before time.Time) ([]*models.UploadSession, error)
+	// StagedUploadBytes sums the lengths of the owner's uploads that are
+	// staged on disk, neither assembled into a file nor concatenations.
+	StagedUploadBytes(ownerID string) (int64, error)
+}
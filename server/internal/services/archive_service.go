@@ -0,0 +1,249 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+// Formats of ArchiveService.Write.
+const (
+	ArchiveZip   = "zip"
+	ArchiveTarGz = "tar.gz"
+)
+
+var (
+	ErrArchiveTooLarge      = errors.New("too much to download as one archive")
+	ErrUnknownArchiveFormat = errors.New("unknown archive format (want zip or tar.gz)")
+)
+
+// ArchiveEntry is a node and its slash separated path in an archive.
+type ArchiveEntry struct {
+	Path string
+	Node *models.Node
+}
+
+// Archive is what Plan decided to put into an archive, parents before
+// children.
+type Archive struct {
+	Entries []ArchiveEntry
+	Bytes   int64
+}
+
+// ArchiveService streams folders and selections of nodes as ZIP or tar.gz.
+// The tree is walked up front so the caps are checked before anything is
+// sent; the content is then read file by file while it is written, nothing
+// is staged on disk.
+type ArchiveService struct {
+	MaxBytes   int64
+	MaxEntries int
+
+	fileRepo repository.FileRepository
+	storage  *StorageService
+}
+
+func NewArchiveService(fileRepo repository.FileRepository, storage *StorageService) *ArchiveService {
+	return &ArchiveService{MaxBytes: 10 << 30, MaxEntries: 50000, fileRepo: fileRepo, storage: storage}
+}
+
+// Plan collects roots, with everything below the folders among them, of
+// one owner. A root that is also below another one is only archived once.
+// Names are made unique per folder the way UniqueName does, as names that
+// differ only in case clash when extracted on most desktops.
+func (s *ArchiveService) Plan(roots []*models.Node) (*Archive, error) {
+	trees := make([][]*models.Node, len(roots))
+	rootIndex := map[string]int{}
+	for i, r := range roots {
+		rootIndex[r.ID] = i
+	}
+	nested := map[int]bool{}
+	entries := 0
+	for i, r := range roots {
+		tree, err := s.collect(r, entries)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range tree[1:] {
+			if j, ok := rootIndex[n.ID]; ok {
+				nested[j] = true
+			}
+		}
+		trees[i] = tree
+		entries += len(tree)
+	}
+
+	a := &Archive{}
+	dirs := map[string]string{}           // folder id -> path
+	taken := map[string]map[string]bool{} // path -> name keys used in it
+	for i, tree := range trees {
+		if nested[i] {
+			continue
+		}
+		for j, n := range tree {
+			dir := ""
+			if j > 0 {
+				dir = dirs[n.ParentID]
+			}
+			if taken[dir] == nil {
+				taken[dir] = map[string]bool{}
+			}
+			name := UniqueName(archiveName(n.Name), n.Type, taken[dir])
+			taken[dir][repository.NameKey(name)] = true
+			p := path.Join(dir, name)
+			if n.Type == "folder" {
+				dirs[n.ID] = p
+			} else {
+				a.Bytes += n.Size
+			}
+			a.Entries = append(a.Entries, ArchiveEntry{Path: p, Node: n})
+		}
+	}
+	if s.MaxEntries > 0 && len(a.Entries) > s.MaxEntries {
+		return nil, fmt.Errorf("%w (max %d files and folders)", ErrArchiveTooLarge, s.MaxEntries)
+	}
+	if s.MaxBytes > 0 && a.Bytes > s.MaxBytes {
+		return nil, fmt.Errorf("%w (max %d bytes)", ErrArchiveTooLarge, s.MaxBytes)
+	}
+	return a, nil
+}
+
+// collect returns node and its descendants, parents before children,
+// failing once more than MaxEntries minus counted nodes are found.
+func (s *ArchiveService) collect(node *models.Node, counted int) ([]*models.Node, error) {
+	tree := []*models.Node{node}
+	seen := map[string]bool{node.ID: true}
+	for i := 0; i < len(tree); i++ {
+		if tree[i].Type != "folder" {
+			continue
+		}
+		children, err := s.fileRepo.ListChildren(node.OwnerID, tree[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range children {
+			if seen[ch.ID] {
+				continue
+			}
+			seen[ch.ID] = true
+			tree = append(tree, ch)
+		}
+		if s.MaxEntries > 0 && counted+len(tree) > s.MaxEntries {
+			return nil, fmt.Errorf("%w (max %d files and folders)", ErrArchiveTooLarge, s.MaxEntries)
+		}
+	}
+	return tree, nil
+}
+
+// archiveName makes a node name safe as one path element; names stored
+// before they were validated may contain slashes.
+func archiveName(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// Write streams a to w in format. An error after the first byte leaves a
+// truncated archive, which the client sees as a failed download.
+func (s *ArchiveService) Write(w io.Writer, a *Archive, format string) error {
+	switch format {
+	case ArchiveZip:
+		return s.writeZip(w, a)
+	case ArchiveTarGz:
+		return s.writeTarGz(w, a)
+	}
+	return ErrUnknownArchiveFormat
+}
+
+func (s *ArchiveService) writeZip(w io.Writer, a *Archive) error {
+	zw := zip.NewWriter(w)
+	for _, e := range a.Entries {
+		// names that are not ASCII get the UTF-8 flag from CreateHeader
+		hdr := &zip.FileHeader{Name: e.Path, Modified: e.Node.UpdatedAt}
+		if e.Node.Type == "folder" {
+			hdr.Name += "/"
+			if _, err := zw.CreateHeader(hdr); err != nil {
+				return err
+			}
+			continue
+		}
+		hdr.Method = zip.Deflate
+		if !compressible(e.Node.Mime) {
+			hdr.Method = zip.Store
+		}
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if err := s.copyContent(fw, e); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (s *ArchiveService) writeTarGz(w io.Writer, a *Archive) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, e := range a.Entries {
+		// tar switches to PAX headers for long and non-ASCII names
+		hdr := &tar.Header{Name: e.Path, ModTime: e.Node.UpdatedAt, Mode: 0o644, Typeflag: tar.TypeReg, Size: e.Node.Size}
+		if e.Node.Type == "folder" {
+			hdr.Name += "/"
+			hdr.Mode = 0o755
+			hdr.Typeflag = tar.TypeDir
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if e.Node.Type == "folder" {
+			continue
+		}
+		if err := s.copyContent(tw, e); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (s *ArchiveService) copyContent(w io.Writer, e ArchiveEntry) error {
+	blob, err := s.storage.OpenFile(e.Node)
+	if err != nil {
+		return fmt.Errorf("%s: %w", e.Path, err)
+	}
+	defer blob.Close()
+	if _, err := io.CopyN(w, blob, e.Node.Size); err != nil {
+		return fmt.Errorf("%s: %w", e.Path, err)
+	}
+	return nil
+}
+
+// compressible reports whether deflating content of mimeType is worth it;
+// media and archives are compressed already.
+func compressible(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "image/") && mimeType != "image/svg+xml" && mimeType != "image/bmp":
+		return false
+	case strings.HasPrefix(mimeType, "video/"), strings.HasPrefix(mimeType, "audio/"):
+		return false
+	}
+	switch mimeType {
+	case "application/zip", "application/gzip", "application/x-gzip", "application/x-7z-compressed",
+		"application/x-rar-compressed", "application/vnd.rar", "application/x-xz", "application/zstd":
+		return false
+	}
+	return true
+}
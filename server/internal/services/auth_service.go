@@ -192,7 +192,9 @@ func (s *AuthService) GetProfile(userID string) (*models.User, error) {
 	return u, nil
 }
 
-func (s *AuthService) UpdateProfile(userID, name, avatarURL string) (*models.User, error) {
+// UpdateProfile changes the display name and, when avatar is not nil, the
+// avatar. Releasing the storage of the previous avatar is up to the caller.
+func (s *AuthService) UpdateProfile(userID, name string, avatar *models.Blob) (*models.User, error) {
 	u, err := s.repo.FindByID(userID)
 	if err != nil {
 		return nil, err
@@ -203,8 +205,9 @@ func (s *AuthService) UpdateProfile(userID, name, avatarURL string) (*models.Use
 	if name != "" {
 		u.Name = name
 	}
-	if avatarURL != "" {
-		u.AvatarURL = avatarURL
+	if avatar != nil {
+		u.AvatarURL = avatar.Key
+		u.AvatarBlobID = avatar.ID
 	}
 	u.UpdatedAt = time.Now()
 	if err := s.repo.UpdateUser(u); err != nil {
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+// Operations of a batch, see BatchService.
+const (
+	BatchMove   = "move"
+	BatchDelete = "delete"
+	BatchRename = "rename"
+	BatchCopy   = "copy"
+)
+
+var (
+	ErrNodeNotFound     = repository.ErrNodeNotFound
+	ErrForbidden        = errors.New("forbidden")
+	ErrParentNotFound   = errors.New("target parent not found")
+	ErrParentNotFolder  = errors.New("target parent is not a folder")
+	ErrMoveIntoSelf     = errors.New("cannot move folder into its own descendant")
+	ErrParentDeleted    = errors.New("target parent is deleted by this batch")
+	ErrBatchTooLarge    = errors.New("too many operations in batch")
+	ErrBatchDuplicateID = errors.New("a node may appear only once in a batch")
+	ErrBatchAtomicCopy  = errors.New("copy cannot be part of an atomic batch")
+	// a name clash aborts a transaction, so it cannot be resolved in one
+	ErrBatchAtomicConflict = errors.New("atomic batches only move with conflict=fail")
+	// ErrBatchRolledBack is the result of the operations of an atomic batch
+	// that were undone because another one failed.
+	ErrBatchRolledBack = errors.New("rolled back, another operation of the batch failed")
+)
+
+// BatchOp is one operation of a batch. ParentID is the target of move and
+// copy, Name the new name of rename. Conflict applies to move, see
+// NodeService.
+type BatchOp struct {
+	Op       string
+	ID       string
+	ParentID string
+	Name     string
+	Conflict string
+}
+
+// BatchResult is the outcome of one BatchOp. Node is the moved, renamed or
+// copied node, Job the background job of a large copy.
+type BatchResult struct {
+	Node *models.Node
+	Job  *models.Job
+	Err  error
+}
+
+// BatchService applies many operations of one owner at once. The nodes and
+// target folders of the whole batch are loaded and checked up front, then
+// the operations run in order. An atomic batch runs in a transaction and
+// stops at the first failure; copies cannot be part of it as their content
+// is shared outside the file tree.
+type BatchService struct {
+	MaxOps int
+
+	fileRepo repository.FileRepository
+	nodes    *NodeService
+	trash    *TrashService
+	copies   *CopyService
+}
+
+func NewBatchService(fileRepo repository.FileRepository, nodes *NodeService, trash *TrashService, copies *CopyService) *BatchService {
+	return &BatchService{MaxOps: 1000, fileRepo: fileRepo, nodes: nodes, trash: trash, copies: copies}
+}
+
+// Run applies ops for ownerID and returns one result per operation. The
+// error is set if the batch could not run at all; a failed atomic batch
+// returns results with ErrBatchRolledBack and a nil error.
+func (s *BatchService) Run(ownerID string, ops []BatchOp, atomic bool) ([]BatchResult, error) {
+	if s.MaxOps > 0 && len(ops) > s.MaxOps {
+		return nil, fmt.Errorf("%w (max %d)", ErrBatchTooLarge, s.MaxOps)
+	}
+	seen := map[string]bool{}
+	for _, op := range ops {
+		if seen[op.ID] {
+			return nil, fmt.Errorf("%w: %s", ErrBatchDuplicateID, op.ID)
+		}
+		seen[op.ID] = true
+		if atomic && op.Op == BatchCopy {
+			return nil, ErrBatchAtomicCopy
+		}
+		if atomic && op.Op == BatchMove && op.Conflict != "" && op.Conflict != ConflictFail {
+			return nil, ErrBatchAtomicConflict
+		}
+	}
+
+	checked, covered, err := s.check(ownerID, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	if !atomic {
+		results := append([]BatchResult(nil), checked...)
+		s.apply(ops, results, covered, false, s.fileRepo, s.nodes, s.trash)
+		return results, nil
+	}
+	var (
+		results []BatchResult
+		failed  bool
+	)
+	err = s.fileRepo.Transaction(func(tx repository.FileRepository) error {
+		// a retried transaction starts over
+		results = append([]BatchResult(nil), checked...)
+		trash := s.trash.withRepo(tx)
+		if failed = !s.apply(ops, results, covered, true, tx, s.nodes.withRepo(tx, trash), trash); failed {
+			return ErrBatchRolledBack
+		}
+		return nil
+	})
+	if failed {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i] = BatchResult{Err: ErrBatchRolledBack}
+			}
+		}
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// check loads the nodes and targets of ops and fills in the results of the
+// operations that cannot succeed. The moves are played through on the
+// loaded tree, so a batch moving A into B and B into A is caught as well.
+// covered marks deletes of nodes that go to the trash with a folder deleted
+// by the same batch.
+func (s *BatchService) check(ownerID string, ops []BatchOp) (results []BatchResult, covered []bool, err error) {
+	results = make([]BatchResult, len(ops))
+	covered = make([]bool, len(ops))
+	ids := make([]string, 0, len(ops))
+	for _, op := range ops {
+		ids = append(ids, op.ID)
+		if op.ParentID != "" {
+			ids = append(ids, op.ParentID)
+		}
+	}
+	found, err := s.fileRepo.FindNodesByIDs(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	t := &batchTree{fileRepo: s.fileRepo, nodes: map[string]*models.Node{}, parents: map[string]string{}}
+	for _, n := range found {
+		t.nodes[n.ID] = n
+	}
+
+	deleted := map[string]bool{}
+	for _, op := range ops {
+		if op.Op == BatchDelete {
+			deleted[op.ID] = true
+		}
+	}
+
+	for i, op := range ops {
+		n := t.nodes[op.ID]
+		switch {
+		case n == nil:
+			results[i].Err = ErrNodeNotFound
+			continue
+		case n.OwnerID != ownerID:
+			results[i].Err = ErrForbidden
+			continue
+		}
+		if op.Op == BatchDelete {
+			chain, err := t.ancestors(n.ParentID)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, id := range chain {
+				covered[i] = covered[i] || deleted[id]
+			}
+		}
+		if op.Op != BatchMove && op.Op != BatchCopy {
+			continue
+		}
+		if op.ParentID != "" {
+			p := t.nodes[op.ParentID]
+			switch {
+			case p == nil:
+				results[i].Err = ErrParentNotFound
+				continue
+			case p.OwnerID != ownerID:
+				results[i].Err = ErrForbidden
+				continue
+			case p.Type != "folder":
+				results[i].Err = ErrParentNotFolder
+				continue
+			}
+		}
+		chain, err := t.ancestors(op.ParentID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, id := range chain {
+			if deleted[id] {
+				results[i].Err = ErrParentDeleted
+				break
+			}
+			if id == op.ID && op.Op == BatchMove {
+				results[i].Err = ErrMoveIntoSelf
+				break
+			}
+		}
+		if results[i].Err == nil && op.Op == BatchMove {
+			t.parents[op.ID] = op.ParentID
+		}
+	}
+	return results, covered, nil
+}
+
+// apply runs the operations that passed check. In an atomic batch it stops
+// and returns false at the first failure.
+func (s *BatchService) apply(ops []BatchOp, results []BatchResult, covered []bool, atomic bool, fileRepo repository.FileRepository, nodes *NodeService, trash *TrashService) bool {
+	for i, op := range ops {
+		if results[i].Err != nil {
+			if atomic {
+				return false
+			}
+			continue
+		}
+		if covered[i] {
+			continue
+		}
+		results[i] = s.applyOne(op, fileRepo, nodes, trash)
+		if results[i].Err != nil && atomic {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *BatchService) applyOne(op BatchOp, fileRepo repository.FileRepository, nodes *NodeService, trash *TrashService) BatchResult {
+	// the node may have changed since check loaded it
+	n, err := fileRepo.FindNodeByID(op.ID)
+	if err != nil {
+		return BatchResult{Err: err}
+	}
+	if n == nil {
+		// e.g. deleted along with a folder earlier in the batch
+		return BatchResult{Err: ErrNodeNotFound}
+	}
+	switch op.Op {
+	case BatchMove:
+		if n.ParentID == op.ParentID {
+			return BatchResult{Node: n}
+		}
+		if _, err := nodes.Move(n, op.ParentID, op.Conflict); err != nil {
+			return BatchResult{Err: err}
+		}
+		return BatchResult{Node: n}
+	case BatchRename:
+		if op.Name != n.Name {
+			if err := fileRepo.RenameNode(n.OwnerID, n.ID, op.Name); err != nil {
+				return BatchResult{Err: err}
+			}
+			n.Name = op.Name
+		}
+		return BatchResult{Node: n}
+	case BatchDelete:
+		return BatchResult{Err: trash.Trash(n)}
+	case BatchCopy:
+		copied, job, err := s.copies.Copy(n, op.ParentID)
+		return BatchResult{Node: copied, Job: job, Err: err}
+	}
+	return BatchResult{Err: fmt.Errorf("unknown operation %q", op.Op)}
+}
+
+// batchTree answers ancestor questions for check, fetching each folder at
+// most once and taking the moves of the batch into account.
+type batchTree struct {
+	fileRepo repository.FileRepository
+	nodes    map[string]*models.Node
+	parents  map[string]string // parent after the moves checked so far
+}
+
+func (t *batchTree) parent(id string) (string, error) {
+	if pid, ok := t.parents[id]; ok {
+		return pid, nil
+	}
+	n, ok := t.nodes[id]
+	if !ok {
+		var err error
+		if n, err = t.fileRepo.FindNodeByID(id); err != nil {
+			return "", err
+		}
+		t.nodes[id] = n
+	}
+	if n == nil {
+		return "", nil
+	}
+	return n.ParentID, nil
+}
+
+// ancestors returns id and the folders above it.
+func (t *batchTree) ancestors(id string) ([]string, error) {
+	var chain []string
+	for depth := 0; id != "" && depth < 1000; depth++ {
+		chain = append(chain, id)
+		pid, err := t.parent(id)
+		if err != nil {
+			return nil, err
+		}
+		id = pid
+	}
+	return chain, nil
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Encrypted blobs are a short header followed by the plaintext cut into
+// fixed size segments, each sealed with AES-GCM under its own nonce
+// (prefix | segment index | last flag). Fixed segments let a range read
+// decrypt only the segments it covers.
+const (
+	encMagic           = "ecb1"
+	encNoncePrefixSize = 7
+	encHeaderSize      = len(encMagic) + encNoncePrefixSize
+	encSegmentSize     = 64 << 10
+	encTagSize         = 16
+)
+
+var errCorruptCiphertext = errors.New("corrupt encrypted blob")
+
+func segmentNonce(prefix []byte, index uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[encNoncePrefixSize:], uint32(index))
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// plainSize computes the plaintext size from the size of the stored blob.
+func plainSize(storedSize int64) (int64, error) {
+	body := storedSize - int64(encHeaderSize)
+	if body < encTagSize {
+		return 0, errCorruptCiphertext
+	}
+	full := body / (encSegmentSize + encTagSize)
+	rem := body % (encSegmentSize + encTagSize)
+	if rem == 0 {
+		return full * encSegmentSize, nil
+	}
+	if rem < encTagSize {
+		return 0, errCorruptCiphertext
+	}
+	return full*encSegmentSize + rem - encTagSize, nil
+}
+
+type encryptingReader struct {
+	src    *bufio.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	index  uint64
+	plain  []byte
+	sealed []byte
+	out    []byte
+	done   bool
+}
+
+func newEncryptingReader(src io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, encNoncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	header := append([]byte(encMagic), prefix...)
+	return &encryptingReader{
+		src:    bufio.NewReaderSize(src, encSegmentSize),
+		aead:   aead,
+		prefix: prefix,
+		plain:  make([]byte, encSegmentSize),
+		sealed: make([]byte, 0, encSegmentSize+encTagSize),
+		out:    header,
+	}, nil
+}
+
+func (e *encryptingReader) Read(p []byte) (int, error) {
+	for len(e.out) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(e.src, e.plain)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		last := n < encSegmentSize
+		if !last {
+			if _, err := e.src.Peek(1); err == io.EOF {
+				last = true
+			}
+		}
+		e.out = e.aead.Seal(e.sealed[:0], segmentNonce(e.prefix, e.index, last), e.plain[:n], nil)
+		e.index++
+		e.done = last
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+type decryptingReader struct {
+	src       io.ReadCloser
+	aead      cipher.AEAD
+	prefix    []byte
+	index     uint64
+	lastIndex uint64
+	plainSize int64
+	skip      int64
+	remaining int64
+	seg       []byte
+	out       []byte
+}
+
+// openDecryptedRange returns length plaintext bytes starting at offset of an
+// encrypted blob, fetching only the segments that cover the range.
+func openDecryptedRange(blobs BlobStore, key string, dataKey []byte, size, offset, length int64) (io.ReadCloser, error) {
+	if length < 0 || offset+length > size {
+		length = size - offset
+	}
+	if length <= 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	hr, err := blobs.GetRange(key, 0, int64(encHeaderSize))
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, encHeaderSize)
+	_, err = io.ReadFull(hr, header)
+	hr.Close()
+	if err != nil || string(header[:len(encMagic)]) != encMagic {
+		return nil, errCorruptCiphertext
+	}
+
+	segments := (size + encSegmentSize - 1) / encSegmentSize
+	if segments == 0 {
+		segments = 1
+	}
+	first := offset / encSegmentSize
+	last := (offset + length - 1) / encSegmentSize
+	ctOffset := int64(encHeaderSize) + first*(encSegmentSize+encTagSize)
+	ctEnd := int64(encHeaderSize) + last*(encSegmentSize+encTagSize) + min(encSegmentSize, size-last*encSegmentSize) + encTagSize
+
+	body, err := blobs.GetRange(key, ctOffset, ctEnd-ctOffset)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{
+		src:       body,
+		aead:      aead,
+		prefix:    header[len(encMagic):],
+		index:     uint64(first),
+		lastIndex: uint64(segments - 1),
+		plainSize: size,
+		skip:      offset - first*encSegmentSize,
+		remaining: length,
+		seg:       make([]byte, encSegmentSize+encTagSize),
+	}, nil
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	if d.remaining <= 0 {
+		return 0, io.EOF
+	}
+	for len(d.out) == 0 {
+		segLen := int64(encSegmentSize)
+		if d.index == d.lastIndex {
+			segLen = d.plainSize - int64(d.index)*encSegmentSize
+		}
+		ct := d.seg[:segLen+encTagSize]
+		if _, err := io.ReadFull(d.src, ct); err != nil {
+			return 0, fmt.Errorf("%w: %v", errCorruptCiphertext, err)
+		}
+		plain, err := d.aead.Open(ct[:0], segmentNonce(d.prefix, d.index, d.index == d.lastIndex), ct, nil)
+		if err != nil {
+			return 0, errCorruptCiphertext
+		}
+		d.index++
+		if d.skip > 0 {
+			plain = plain[d.skip:]
+			d.skip = 0
+		}
+		d.out = plain
+	}
+	if int64(len(d.out)) > d.remaining {
+		d.out = d.out[:d.remaining]
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	d.remaining -= int64(n)
+	return n, nil
+}
+
+func (d *decryptingReader) Close() error {
+	return d.src.Close()
+}
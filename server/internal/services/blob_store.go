@@ -0,0 +1,30 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+type BlobInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore is the physical storage backend behind StorageService. Keys are
+// slash separated and relative to the backend root.
+type BlobStore interface {
+	Put(key string, r io.Reader) (int64, error)
+	Get(key string) (io.ReadCloser, error)
+	// GetRange reads length bytes starting at offset; a negative length reads to the end.
+	GetRange(key string, offset, length int64) (io.ReadCloser, error)
+	Stat(key string) (*BlobInfo, error)
+	Delete(key string) error
+	// Move renames a blob inside the backend, replacing dst if it exists.
+	Move(src, dst string) error
+	// List calls fn for every blob whose key starts with prefix.
+	List(prefix string, fn func(info BlobInfo) error) error
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strings"
+
+	"server/internal/models"
+)
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksummer computes every supported checksum in a single pass.
+type checksummer struct {
+	sha256 hash.Hash
+	md5    hash.Hash
+	crc32c hash.Hash32
+}
+
+func newChecksummer() *checksummer {
+	return &checksummer{sha256: sha256.New(), md5: md5.New(), crc32c: crc32.New(crc32cTable)}
+}
+
+func (c *checksummer) Write(p []byte) (int, error) {
+	c.sha256.Write(p)
+	c.md5.Write(p)
+	c.crc32c.Write(p)
+	return len(p), nil
+}
+
+func (c *checksummer) checksums() *models.Checksums {
+	return &models.Checksums{
+		SHA256: hex.EncodeToString(c.sha256.Sum(nil)),
+		MD5:    hex.EncodeToString(c.md5.Sum(nil)),
+		CRC32C: hex.EncodeToString(c.crc32c.Sum(nil)),
+	}
+}
+
+func verifyChecksums(want, got *models.Checksums) error {
+	if want == nil {
+		return nil
+	}
+	check := func(name, w, g string) error {
+		if w != "" && !strings.EqualFold(w, g) {
+			return fmt.Errorf("%w: %s is %s, expected %s", ErrChecksumMismatch, name, g, strings.ToLower(w))
+		}
+		return nil
+	}
+	if err := check("sha-256", want.SHA256, got.SHA256); err != nil {
+		return err
+	}
+	if err := check("md5", want.MD5, got.MD5); err != nil {
+		return err
+	}
+	return check("crc32c", want.CRC32C, got.CRC32C)
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"server/internal/models"
+	"server/internal/repository"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrEmptyQuery is returned for a content search without any words.
+var ErrEmptyQuery = errors.New("query is empty")
+
+// contentQueueSize is how many files may wait for indexing; files queued
+// beyond it are left to the index-content command.
+const contentQueueSize = 1000
+
+// contentSearchPage is how many indexed contents Search reads at once.
+const contentSearchPage = 100
+
+// maxContentCandidates bounds the indexed contents a search looks into.
+const maxContentCandidates = 1000
+
+// ContentIndexService extracts the text of plain text files, source code and
+// Office Open XML documents (docx, xlsx, pptx) and searches it. Files are
+// indexed in the background after they are uploaded or get new content.
+// The index is kept per owner and digest: files found by a search are those
+// whose current content is indexed, so it never points at replaced content,
+// and an index is dropped once no file of the owner has the content.
+type ContentIndexService struct {
+	contentRepo repository.ContentRepository
+	fileRepo    repository.FileRepository
+	storage     *StorageService
+	queue       chan *models.Node
+}
+
+func NewContentIndexService(contentRepo repository.ContentRepository, fileRepo repository.FileRepository, storage *StorageService) *ContentIndexService {
+	return &ContentIndexService{
+		contentRepo: contentRepo,
+		fileRepo:    fileRepo,
+		storage:     storage,
+		queue:       make(chan *models.Node, contentQueueSize),
+	}
+}
+
+// Queue has the content of file node n indexed in the background, if text
+// can be extracted from it. It never blocks.
+func (s *ContentIndexService) Queue(n *models.Node) {
+	if n.Type != "file" || n.Digest == "" || contentKind(n.Name, n.Mime) == "" {
+		return
+	}
+	select {
+	case s.queue <- n:
+	default:
+		log.Printf("content index: queue full, not indexing %s", n.ID)
+	}
+}
+
+// Start indexes queued files in the background.
+func (s *ContentIndexService) Start() {
+	go func() {
+		for n := range s.queue {
+			if _, err := s.Index(n); err != nil {
+				log.Printf("content index: %s: %v", n.ID, err)
+			}
+		}
+	}()
+}
+
+// Index extracts and stores the text of file node n unless its content is
+// indexed already. indexed is false if there was nothing to do, also for
+// content without text.
+func (s *ContentIndexService) Index(n *models.Node) (indexed bool, err error) {
+	kind := contentKind(n.Name, n.Mime)
+	if n.Type != "file" || n.Digest == "" || kind == "" {
+		return false, nil
+	}
+	has, err := s.contentRepo.HasContent(n.OwnerID, n.Digest)
+	if err != nil || has {
+		return false, err
+	}
+	r, err := s.storage.OpenFile(n)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	text, truncated, err := extractText(kind, n.Name, r, n.Size)
+	if errors.Is(err, ErrUnsupportedContent) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	err = s.contentRepo.PutContent(&models.ContentIndex{
+		OwnerID:   n.OwnerID,
+		Digest:    n.Digest,
+		Text:      text,
+		Tokens:    contentTokens(text),
+		Truncated: truncated,
+	})
+	return err == nil, err
+}
+
+// Forget drops the index of the owner's content with the digest once no
+// file, also none in the trash, has it anymore.
+func (s *ContentIndexService) Forget(ownerID, digest string) error {
+	if digest == "" {
+		return nil
+	}
+	used, err := s.fileRepo.HasFileWithDigest(ownerID, digest)
+	if err != nil || used {
+		return err
+	}
+	return s.contentRepo.DeleteContent(ownerID, digest)
+}
+
+// IndexAll indexes every file not in the trash whose content is not indexed
+// yet, such as files stored before content search or dropped from the
+// queue.
+func (s *ContentIndexService) IndexAll() (indexed int, err error) {
+	var pending []*models.Node
+	err = s.fileRepo.WalkNodes(func(n *models.Node) error {
+		if n.Type == "file" && n.TrashedAt == nil && contentKind(n.Name, n.Mime) != "" {
+			pending = append(pending, n)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, n := range pending {
+		ok, err := s.Index(n)
+		if err != nil {
+			return indexed, fmt.Errorf("node %s: %w", n.ID, err)
+		}
+		if ok {
+			indexed++
+		}
+	}
+	return indexed, nil
+}
+
+// ContentSearchResult is a file found by ContentIndexService.Search.
+type ContentSearchResult struct {
+	Node *models.Node
+	// Path is the file's slash separated path from the root.
+	Path    string
+	Snippet []SnippetPart
+}
+
+// Search returns up to limit files of the owner containing every word of
+// query, most recently updated first, with a snippet around the first
+// match. Words are matched ignoring case; words of scripts without spaces,
+// such as Japanese, also inside longer runs of text. Contents are looked
+// into the most recently indexed first, until limit files are found or
+// maxContentCandidates contents were looked into.
+func (s *ContentIndexService) Search(ownerID, query string, limit int) ([]ContentSearchResult, error) {
+	query = norm.NFKC.String(query)
+	terms := strings.Fields(query)
+	tokens := contentTokens(query)
+	if len(terms) == 0 || len(tokens) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	termPatterns := make([]*regexp.Regexp, len(terms))
+	for i, t := range terms {
+		termPatterns[i] = termsPattern([]string{t})
+	}
+
+	// a file's content is indexed when the file gets it, so the newest
+	// contents are read first, a page at a time, and reading stops once
+	// enough files are found; only the texts of found files are held on to
+	var files []*models.Node
+	texts := map[string]string{}
+	var after *models.ContentIndex
+	for scanned := 0; len(files) < limit && scanned < maxContentCandidates; {
+		candidates, err := s.contentRepo.SearchContent(ownerID, tokens, after, contentSearchPage)
+		if err != nil {
+			return nil, err
+		}
+		var digests []string
+		for _, c := range candidates {
+			// tokens may match where the words do not, in another order
+			if containsAll(c.Text, termPatterns) {
+				texts[c.Digest] = c.Text
+				digests = append(digests, c.Digest)
+			}
+		}
+		if len(digests) > 0 {
+			// files in the trash are left out here
+			found, err := s.fileRepo.FindFilesByDigests(ownerID, digests)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, found...)
+		}
+		for _, d := range digests {
+			if !hasDigest(files, d) {
+				delete(texts, d)
+			}
+		}
+		scanned += len(candidates)
+		if len(candidates) < contentSearchPage {
+			break
+		}
+		after = candidates[len(candidates)-1]
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].UpdatedAt.Equal(files[j].UpdatedAt) {
+			return files[i].UpdatedAt.After(files[j].UpdatedAt)
+		}
+		return files[i].ID > files[j].ID
+	})
+	if len(files) > limit {
+		files = files[:limit]
+	}
+
+	pattern := termsPattern(terms)
+	cache := map[string]*models.Node{}
+	out := make([]ContentSearchResult, 0, len(files))
+	for _, n := range files {
+		above, err := ancestors(s.fileRepo, n, cache)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ContentSearchResult{
+			Node:    n,
+			Path:    nodePath(above, n),
+			Snippet: snippet(texts[n.Digest], pattern),
+		})
+	}
+	return out, nil
+}
+
+func hasDigest(files []*models.Node, digest string) bool {
+	for _, n := range files {
+		if n.Digest == digest {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrUnsupportedContent is returned for content no text is extracted from.
+var ErrUnsupportedContent = errors.New("content cannot be indexed")
+
+const (
+	// maxIndexedText is how much text of a file is indexed.
+	maxIndexedText = 256 << 10
+	// maxIndexedDocument is the largest Office document that is indexed;
+	// documents are read into memory.
+	maxIndexedDocument = 50 << 20
+	// maxDocumentPart caps what is decompressed of one part of a document.
+	maxDocumentPart = 100 << 20
+	// maxDocumentXML caps what is decompressed of all parts of a document
+	// together; the text of the parts beyond it is not indexed.
+	maxDocumentXML = 200 << 20
+)
+
+const (
+	contentText     = "text"
+	contentDocument = "document" // Office Open XML
+)
+
+var textExtensions = map[string]bool{
+	".txt": true, ".text": true, ".md": true, ".markdown": true, ".csv": true, ".tsv": true,
+	".json": true, ".log": true, ".xml": true, ".yaml": true, ".yml": true, ".toml": true,
+	".ini": true, ".html": true, ".htm": true, ".css": true, ".scss": true, ".sql": true,
+	".go": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".mjs": true, ".vue": true,
+	".py": true, ".rb": true, ".php": true, ".java": true, ".kt": true, ".scala": true,
+	".c": true, ".h": true, ".cc": true, ".cpp": true, ".hpp": true, ".cs": true,
+	".rs": true, ".swift": true, ".m": true, ".sh": true, ".bash": true, ".ps1": true,
+	".lua": true, ".pl": true, ".r": true, ".dart": true, ".proto": true,
+}
+
+// contentKind tells how text is extracted from a file, "" if it is not.
+func contentKind(name, mimeType string) string {
+	switch ext := strings.ToLower(path.Ext(name)); {
+	case ext == ".docx" || ext == ".xlsx" || ext == ".pptx":
+		return contentDocument
+	case textExtensions[ext], strings.HasPrefix(mimeType, "text/"), mimeType == "application/json":
+		return contentText
+	}
+	return ""
+}
+
+// extractText returns the text of a file of the given kind, NFKC normalized
+// and cut off after maxIndexedText bytes; truncated tells whether it was.
+func extractText(kind, name string, r io.Reader, size int64) (text string, truncated bool, err error) {
+	var raw string
+	switch kind {
+	case contentText:
+		raw, truncated, err = plainText(r)
+	case contentDocument:
+		if size > maxIndexedDocument {
+			return "", false, ErrUnsupportedContent
+		}
+		raw, truncated, err = documentText(strings.ToLower(path.Ext(name)), r)
+	default:
+		return "", false, ErrUnsupportedContent
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return norm.NFKC.String(raw), truncated, nil
+}
+
+// plainText reads UTF-8 text; anything else, such as binary data or other
+// encodings, is unsupported.
+func plainText(r io.Reader) (string, bool, error) {
+	buf, err := io.ReadAll(io.LimitReader(r, maxIndexedText+1))
+	if err != nil {
+		return "", false, err
+	}
+	truncated := len(buf) > maxIndexedText
+	if truncated {
+		buf = buf[:maxIndexedText]
+		// the cut may split a character
+		for i := 0; i < utf8.UTFMax && len(buf) > 0 && !utf8.Valid(buf); i++ {
+			buf = buf[:len(buf)-1]
+		}
+	}
+	if !utf8.Valid(buf) || bytes.IndexByte(buf, 0) >= 0 {
+		return "", false, ErrUnsupportedContent
+	}
+	return string(buf), truncated, nil
+}
+
+// documentText collects the text runs of a docx, xlsx or pptx file: the
+// body of a document, the shared strings of a workbook or the slides of a
+// presentation in order.
+func documentText(ext string, r io.Reader) (string, bool, error) {
+	buf, err := io.ReadAll(io.LimitReader(r, maxIndexedDocument))
+	if err != nil {
+		return "", false, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		return "", false, ErrUnsupportedContent
+	}
+
+	var parts []*zip.File
+	slideNumber := func(f *zip.File) int {
+		n, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(f.Name, "ppt/slides/slide"), ".xml"))
+		return n
+	}
+	for _, f := range zr.File {
+		switch {
+		case ext == ".docx" && f.Name == "word/document.xml",
+			ext == ".xlsx" && f.Name == "xl/sharedStrings.xml",
+			ext == ".pptx" && strings.HasPrefix(f.Name, "ppt/slides/slide") && strings.HasSuffix(f.Name, ".xml"):
+			parts = append(parts, f)
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return slideNumber(parts[i]) < slideNumber(parts[j]) })
+
+	var sb strings.Builder
+	budget := int64(maxDocumentXML)
+	exhausted := false
+	for _, f := range parts {
+		if sb.Len() >= maxIndexedText {
+			break
+		}
+		if budget <= 0 {
+			exhausted = true
+			break
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", false, ErrUnsupportedContent
+		}
+		limit := min(int64(maxDocumentPart), budget)
+		lr := &io.LimitedReader{R: rc, N: limit}
+		err = xmlText(&sb, lr)
+		rc.Close()
+		budget -= limit - lr.N
+		if err != nil {
+			if budget <= 0 {
+				// cut off by the budget, not broken
+				exhausted = true
+				break
+			}
+			return "", false, ErrUnsupportedContent
+		}
+	}
+	text := sb.String()
+	truncated := exhausted || len(text) > maxIndexedText
+	if truncated {
+		text = strings.ToValidUTF8(text[:maxIndexedText], "")
+	}
+	return text, truncated, nil
+}
+
+// xmlText writes the character data of the <t> elements, which hold the
+// text in every Office Open XML format, ending paragraphs and shared
+// strings with a newline.
+func xmlText(sb *strings.Builder, r io.Reader) error {
+	dec := xml.NewDecoder(r)
+	inText := false
+	for sb.Len() < maxIndexedText {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteByte('\t')
+			case "br":
+				sb.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p", "si":
+				sb.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+	return nil
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) || r == 'ー'
+}
+
+// maxTokenLen drops long runs of letters such as base64 data from the index.
+const maxTokenLen = 64
+
+// contentTokens returns the distinct tokens a text is searched by. CJK text
+// has no spaces between words, so every character and every pair of
+// adjacent characters is a token; other letters and digits form words,
+// folded to lower case. text is expected to be NFKC normalized.
+func contentTokens(text string) []string {
+	var out []string
+	seen := map[string]bool{}
+	add := func(t string) {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	var word []rune
+	endWord := func() {
+		if len(word) > 0 && len(word) <= maxTokenLen {
+			add(string(word))
+		}
+		word = word[:0]
+	}
+	var prev rune // previous CJK character, 0 after anything else
+	for _, r := range text {
+		r = unicode.ToLower(r)
+		switch {
+		case isCJK(r):
+			endWord()
+			add(string(r))
+			if prev != 0 {
+				add(string([]rune{prev, r}))
+			}
+			prev = r
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+			prev = 0
+		default:
+			endWord()
+			prev = 0
+		}
+	}
+	endWord()
+	return out
+}
+
+// SnippetPart is a piece of a search snippet; Match marks the pieces that
+// matched the query.
+type SnippetPart struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// snippetRadius is how much context, in characters, is shown around the
+// first match.
+const snippetRadius = 60
+
+// termsPattern matches any of the terms, ignoring case.
+func termsPattern(terms []string) *regexp.Regexp {
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
+// containsAll reports whether text matches every term pattern.
+func containsAll(text string, terms []*regexp.Regexp) bool {
+	for _, t := range terms {
+		if !t.MatchString(text) {
+			return false
+		}
+	}
+	return true
+}
+
+// snippet cuts the text around the first match of pattern and marks the
+// matches in it.
+func snippet(text string, pattern *regexp.Regexp) []SnippetPart {
+	loc := pattern.FindStringIndex(text)
+	if loc == nil {
+		loc = []int{0, 0}
+	}
+	start, end := loc[0], loc[1]
+	for i := 0; i < snippetRadius && start > 0; i++ {
+		_, n := utf8.DecodeLastRuneInString(text[:start])
+		start -= n
+	}
+	for i := 0; i < snippetRadius && end < len(text); i++ {
+		_, n := utf8.DecodeRuneInString(text[end:])
+		end += n
+	}
+	window := text[start:end]
+
+	var parts []SnippetPart
+	addText := func(s string, match bool) {
+		s = whitespace.ReplaceAllString(s, " ")
+		if s != "" {
+			parts = append(parts, SnippetPart{Text: s, Match: match})
+		}
+	}
+	if start > 0 {
+		window = "… " + window
+	}
+	if end < len(text) {
+		window += " …"
+	}
+	last := 0
+	for _, m := range pattern.FindAllStringIndex(window, -1) {
+		addText(window[last:m[0]], false)
+		addText(window[m[0]:m[1]], true)
+		last = m[1]
+	}
+	addText(window[last:], false)
+	return parts
+}
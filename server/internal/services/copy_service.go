@@ -0,0 +1,248 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"server/internal/models"
+	"server/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCopyIntoSelf = errors.New("cannot copy a folder into itself")
+	ErrCopyTooLarge = errors.New("too many nodes to copy")
+)
+
+// CopyService duplicates nodes and folder trees. File content is shared
+// through StorageService rather than written again. Trees with more than
+// SyncLimit nodes are copied in the background as a tracked job.
+type CopyService struct {
+	MaxNodes  int
+	SyncLimit int
+	JobTTL    time.Duration
+
+	fileRepo repository.FileRepository
+	jobRepo  repository.JobRepository
+	storage  *StorageService
+}
+
+func NewCopyService(fileRepo repository.FileRepository, jobRepo repository.JobRepository, storage *StorageService) *CopyService {
+	return &CopyService{
+		MaxNodes:  100000,
+		SyncLimit: 200,
+		JobTTL:    24 * time.Hour,
+		fileRepo:  fileRepo,
+		jobRepo:   jobRepo,
+		storage:   storage,
+	}
+}
+
+// Copy duplicates node, with everything below it for a folder, into the
+// folder parentID ("" for the root) of the same owner. The caller checks
+// ownership of both. Small trees are copied right away and the new node is
+// returned; otherwise the returned job collects and copies the tree and
+// reports the progress.
+func (s *CopyService) Copy(node *models.Node, parentID string) (*models.Node, *models.Job, error) {
+	if err := s.checkNotInto(node, parentID); err != nil {
+		return nil, nil, err
+	}
+	// only enough of the tree is collected here to tell whether it is small
+	tree, err := s.collect(node, s.SyncLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tree) <= s.SyncLimit {
+		if err := s.checkQuota(node, tree); err != nil {
+			return nil, nil, err
+		}
+		root, err := s.copyTree(tree, parentID, nil)
+		return root, nil, err
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, nil, err
+	}
+	job := &models.Job{
+		ID:        strings.ReplaceAll(id.String(), "-", ""),
+		OwnerID:   node.OwnerID,
+		Type:      "copy",
+		Status:    models.JobRunning,
+		SourceID:  node.ID,
+		TargetID:  parentID,
+		ExpiresAt: time.Now().Add(s.JobTTL),
+	}
+	if err := s.jobRepo.CreateJob(job); err != nil {
+		return nil, nil, err
+	}
+	go func() {
+		root, err := s.runJob(job, node, parentID)
+		if err != nil {
+			log.Printf("copy job %s failed: %v", job.ID, err)
+			_ = s.jobRepo.FinishJob(job.ID, models.JobFailed, "", err.Error())
+			return
+		}
+		_ = s.jobRepo.FinishJob(job.ID, models.JobDone, root.ID, "")
+	}()
+	return nil, job, nil
+}
+
+// runJob collects and copies the tree of a copy job.
+func (s *CopyService) runJob(job *models.Job, node *models.Node, parentID string) (*models.Node, error) {
+	tree, err := s.collect(node, s.MaxNodes)
+	if err != nil {
+		return nil, err
+	}
+	if s.MaxNodes > 0 && len(tree) > s.MaxNodes {
+		return nil, fmt.Errorf("%w (max %d)", ErrCopyTooLarge, s.MaxNodes)
+	}
+	_ = s.jobRepo.SetJobTotal(job.ID, len(tree))
+	if err := s.checkQuota(node, tree); err != nil {
+		return nil, err
+	}
+	return s.copyTree(tree, parentID, func(done int) {
+		_ = s.jobRepo.UpdateJobProgress(job.ID, done)
+	})
+}
+
+func (s *CopyService) Job(id string) (*models.Job, error) {
+	return s.jobRepo.FindJob(id)
+}
+
+// FailInterruptedJobs marks the jobs still running as failed. Jobs run in
+// the process that started them, so at startup none is running anymore.
+func (s *CopyService) FailInterruptedJobs() (int64, error) {
+	return s.jobRepo.FailRunningJobs("interrupted by a server restart")
+}
+
+// checkNotInto returns ErrCopyIntoSelf if the folder parentID is node or
+// below it.
+func (s *CopyService) checkNotInto(node *models.Node, parentID string) error {
+	if parentID == "" || node.Type != "folder" {
+		return nil
+	}
+	if parentID == node.ID {
+		return ErrCopyIntoSelf
+	}
+	parent, err := s.fileRepo.FindNodeByID(parentID)
+	if err != nil || parent == nil {
+		return err
+	}
+	above, err := ancestors(s.fileRepo, parent, nil)
+	if err != nil {
+		return err
+	}
+	for _, a := range above {
+		if a.ID == node.ID {
+			return ErrCopyIntoSelf
+		}
+	}
+	return nil
+}
+
+// checkQuota checks that the owner can store the files of tree once more.
+func (s *CopyService) checkQuota(node *models.Node, tree []*models.Node) error {
+	var bytes, files int64
+	for _, n := range tree {
+		if n.Type == "file" {
+			bytes += n.Size
+			files++
+		}
+	}
+	return s.storage.CheckQuota(node.OwnerID, bytes, files)
+}
+
+// collect returns node and its descendants, parents before children. It
+// stops once it has more than limit nodes, unless limit is 0.
+func (s *CopyService) collect(node *models.Node, limit int) ([]*models.Node, error) {
+	tree := []*models.Node{node}
+	seen := map[string]bool{node.ID: true}
+	for i := 0; i < len(tree); i++ {
+		if tree[i].Type != "folder" {
+			continue
+		}
+		children, err := s.fileRepo.ListChildren(node.OwnerID, tree[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range children {
+			if seen[ch.ID] {
+				continue
+			}
+			seen[ch.ID] = true
+			tree = append(tree, ch)
+		}
+		if limit > 0 && len(tree) > limit {
+			return tree, nil
+		}
+	}
+	return tree, nil
+}
+
+// copyTree creates the copies of a tree collected by collect. The first node
+// is placed in parentID, as "name (n)" if a sibling has its name. Everything
+// created is removed again if a step fails.
+func (s *CopyService) copyTree(tree []*models.Node, parentID string, progress func(done int)) (*models.Node, error) {
+	newIDs := map[string]string{}
+	created := make([]*models.Node, 0, len(tree))
+	rollback := func() {
+		for i := len(created) - 1; i >= 0; i-- {
+			if created[i].Type == "file" {
+				_ = s.storage.ReleaseFile(created[i])
+			}
+			_ = s.fileRepo.DeleteNode(created[i].ID)
+		}
+	}
+
+	for i, src := range tree {
+		dst := &models.Node{
+			OwnerID:     src.OwnerID,
+			ParentID:    newIDs[src.ParentID],
+			Name:        src.Name,
+			Type:        src.Type,
+			Mime:        src.Mime,
+			Tags:        src.Tags,
+			Description: src.Description,
+			Metadata:    src.Metadata,
+		}
+		if i == 0 {
+			dst.ParentID = parentID
+		}
+		if src.Type == "file" {
+			blob, err := s.storage.CopyFile(src)
+			if err != nil {
+				rollback()
+				return nil, fmt.Errorf("failed to copy %q: %w", src.Name, err)
+			}
+			dst.Size = src.Size
+			dst.Path = blob.Key
+			dst.Digest = blob.Digest
+			dst.MD5 = blob.MD5
+			dst.CRC32C = blob.CRC32C
+			dst.BlobID = blob.ID
+			dst.Corrupt = src.Corrupt
+			if err := createUnique(s.fileRepo, dst); err != nil {
+				_ = s.storage.ReleaseFile(dst)
+				rollback()
+				return nil, err
+			}
+		} else if err := createUnique(s.fileRepo, dst); err != nil {
+			rollback()
+			return nil, err
+		}
+		newIDs[src.ID] = dst.ID
+		created = append(created, dst)
+		if progress != nil && (i+1)%50 == 0 {
+			progress(i + 1)
+		}
+	}
+	if progress != nil {
+		progress(len(tree))
+	}
+	return created[0], nil
+}
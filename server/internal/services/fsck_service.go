@@ -0,0 +1,532 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+// What fsck does with the problems it finds. Not every action applies to
+// every kind of problem, see FsckOptions.
+const (
+	FsckReport     = "report"
+	FsckQuarantine = "quarantine"
+	FsckDelete     = "delete"
+	FsckLostFound  = "lostfound"
+)
+
+const (
+	// LostFoundName is the root folder fsck moves detached nodes into.
+	LostFoundName = "Lost+Found"
+	// quarantinePrefix holds orphaned blobs set aside by fsck. It is never
+	// scanned itself.
+	quarantinePrefix = "quarantine"
+)
+
+type FsckOptions struct {
+	// DryRun only reports, whatever the actions below say.
+	DryRun bool
+	// Orphans handles stored blobs nothing refers to: report, quarantine or delete.
+	Orphans string
+	// Missing handles file nodes whose content is gone: report or delete.
+	Missing string
+	// Dangling handles nodes whose parent is missing, not a folder of the
+	// same owner, or part of a cycle: report, lostfound or delete.
+	Dangling string
+	// Grace skips anything changed more recently, so uploads and deletes in
+	// flight are not mistaken for inconsistencies.
+	Grace time.Duration
+}
+
+func DefaultFsckOptions() FsckOptions {
+	return FsckOptions{
+		Orphans:  FsckQuarantine,
+		Missing:  FsckReport,
+		Dangling: FsckLostFound,
+		Grace:    24 * time.Hour,
+	}
+}
+
+func (o FsckOptions) validate() error {
+	check := func(name, v string, allowed ...string) error {
+		for _, a := range allowed {
+			if v == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid %s action %q (want one of %s)", name, v, strings.Join(allowed, ", "))
+	}
+	if err := check("orphans", o.Orphans, FsckReport, FsckQuarantine, FsckDelete); err != nil {
+		return err
+	}
+	if err := check("missing", o.Missing, FsckReport, FsckDelete); err != nil {
+		return err
+	}
+	return check("dangling", o.Dangling, FsckReport, FsckLostFound, FsckDelete)
+}
+
+type FsckIssue struct {
+	Kind    string // orphan_blob, refcount, missing_content, dangling_parent, cycle
+	OwnerID string
+	NodeID  string
+	Key     string
+	Detail  string
+	Action  string
+}
+
+func (i FsckIssue) String() string {
+	var b strings.Builder
+	b.WriteString(i.Kind)
+	if i.NodeID != "" {
+		fmt.Fprintf(&b, " node=%s owner=%s", i.NodeID, i.OwnerID)
+	}
+	if i.Key != "" {
+		fmt.Fprintf(&b, " key=%s", i.Key)
+	}
+	if i.Detail != "" {
+		fmt.Fprintf(&b, ": %s", i.Detail)
+	}
+	fmt.Fprintf(&b, " [%s]", i.Action)
+	return b.String()
+}
+
+type FsckResult struct {
+	Nodes   int
+	Blobs   int
+	Objects int
+	Issues  []FsckIssue
+}
+
+// FsckService cross-checks the node tree, the blob documents and the blob
+// store, and optionally repairs what does not match.
+type FsckService struct {
+	fileRepo repository.FileRepository
+	blobRepo repository.BlobRepository
+	userRepo repository.UserRepository
+	storage  *StorageService
+}
+
+func NewFsckService(fileRepo repository.FileRepository, blobRepo repository.BlobRepository, userRepo repository.UserRepository, storage *StorageService) *FsckService {
+	return &FsckService{fileRepo: fileRepo, blobRepo: blobRepo, userRepo: userRepo, storage: storage}
+}
+
+// fsckRun holds the snapshot a single check works on.
+type fsckRun struct {
+	*FsckService
+	opts      FsckOptions
+	cutoff    time.Time
+	result    *FsckResult
+	blobs     map[string]*models.Blob
+	nodes     map[string]*models.Node
+	children  map[string][]string
+	objects   map[string]BlobInfo
+	removed   map[string]bool
+	lostFound map[string]string
+}
+
+// Run checks storage consistency. Blob documents are loaded before nodes
+// and nodes before listing the store, so that content written while the
+// check runs is seen as referenced rather than orphaned.
+func (f *FsckService) Run(opts FsckOptions) (*FsckResult, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	r := &fsckRun{
+		FsckService: f,
+		opts:        opts,
+		cutoff:      time.Now().Add(-opts.Grace),
+		result:      &FsckResult{},
+		blobs:       map[string]*models.Blob{},
+		nodes:       map[string]*models.Node{},
+		children:    map[string][]string{},
+		objects:     map[string]BlobInfo{},
+		removed:     map[string]bool{},
+		lostFound:   map[string]string{},
+	}
+
+	if err := f.blobRepo.WalkBlobs(func(b *models.Blob) error {
+		r.blobs[b.ID] = b
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to load blobs: %w", err)
+	}
+	if err := f.fileRepo.WalkNodes(func(n *models.Node) error {
+		r.nodes[n.ID] = n
+		if n.ParentID != "" {
+			r.children[n.ParentID] = append(r.children[n.ParentID], n.ID)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to load nodes: %w", err)
+	}
+	if err := f.storage.Blobs.List("", func(info BlobInfo) error {
+		if !strings.HasPrefix(info.Key, quarantinePrefix+"/") {
+			r.objects[info.Key] = info
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to list blob store: %w", err)
+	}
+	r.result.Nodes = len(r.nodes)
+	r.result.Blobs = len(r.blobs)
+	r.result.Objects = len(r.objects)
+
+	referenced, err := r.checkContent()
+	if err != nil {
+		return r.result, err
+	}
+	if err := r.checkOrphans(referenced); err != nil {
+		return r.result, err
+	}
+	r.checkTree()
+	return r.result, nil
+}
+
+func (r *fsckRun) repairing(action string) bool {
+	return !r.opts.DryRun && action != FsckReport
+}
+
+func (r *fsckRun) issue(i FsckIssue, err error) {
+	if err != nil {
+		i.Action = "failed: " + err.Error()
+	} else if i.Action == "" {
+		i.Action = "reported"
+	}
+	r.result.Issues = append(r.result.Issues, i)
+}
+
+func (r *fsckRun) sortedNodeIDs() []string {
+	ids := make([]string, 0, len(r.nodes))
+	for id := range r.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// checkContent finds file nodes without content, corrects blob reference
+// counts (nodes and avatars) and returns the set of stored keys in use.
+func (r *fsckRun) checkContent() (map[string]bool, error) {
+	referenced := map[string]bool{}
+	refs := map[string]int64{}
+
+	for _, id := range r.sortedNodeIDs() {
+		n := r.nodes[id]
+		if n.Type != "file" {
+			continue
+		}
+		for _, v := range n.Versions {
+			if v.BlobID != "" {
+				refs[v.BlobID]++
+			} else if v.Path != "" {
+				referenced[r.storage.canonicalKey(v.Path)] = true
+			}
+		}
+		var key string
+		blobID := nodeBlobID(n)
+		if blobID != "" {
+			if b := r.blobs[blobID]; b != nil {
+				key = r.storage.canonicalKey(b.Key)
+			}
+		} else if n.Path != "" {
+			key = r.storage.canonicalKey(n.Path)
+			referenced[key] = true
+		} else {
+			continue
+		}
+		if _, ok := r.objects[key]; ok || n.CreatedAt.After(r.cutoff) {
+			if blobID != "" {
+				refs[blobID]++
+			}
+			continue
+		}
+
+		i := FsckIssue{Kind: "missing_content", OwnerID: n.OwnerID, NodeID: n.ID, Key: key, Detail: n.Name}
+		if key == "" {
+			i.Detail = fmt.Sprintf("%s: blob %s is unknown", n.Name, blobID)
+		}
+		if !r.repairing(r.opts.Missing) {
+			if blobID != "" {
+				refs[blobID]++
+			}
+			r.issue(i, nil)
+			continue
+		}
+		// the content is gone, so the reference is dropped with the node and
+		// the count is corrected below; only the quota is given back
+		deleted, err := r.fileRepo.PurgeNode(n.ID)
+		if err == nil {
+			r.removed[n.ID] = true
+			i.Action = "deleted node"
+			for _, v := range n.Versions {
+				if v.BlobID != "" {
+					refs[v.BlobID]--
+				}
+			}
+			if deleted {
+				if qerr := r.storage.UnchargeFile(n); qerr != nil {
+					log.Printf("fsck: failed to give back the quota of %s: %v", n.ID, qerr)
+				}
+			}
+		} else if blobID != "" {
+			refs[blobID]++
+		}
+		r.issue(i, err)
+	}
+
+	users, err := r.userRepo.ListUsersWithAvatar()
+	if err != nil {
+		return referenced, fmt.Errorf("failed to load avatars: %w", err)
+	}
+	for _, u := range users {
+		if u.AvatarBlobID != "" {
+			refs[u.AvatarBlobID]++
+		} else {
+			referenced[r.storage.canonicalKey(u.AvatarURL)] = true
+		}
+	}
+
+	ids := make([]string, 0, len(r.blobs))
+	for id := range r.blobs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		b := r.blobs[id]
+		if b.DeletingAt != nil {
+			// Release is deleting it; one that failed is finished here
+			if time.Since(*b.DeletingAt) > staleBlobDeletion && r.repairing(r.opts.Orphans) {
+				if err := r.storage.purgeBlob(b); err != nil {
+					return referenced, err
+				}
+			}
+			continue
+		}
+		actual := refs[id]
+		if actual > 0 || b.UpdatedAt.After(r.cutoff) {
+			referenced[r.storage.canonicalKey(b.Key)] = true
+		}
+		if b.UpdatedAt.After(r.cutoff) {
+			continue
+		}
+		current := b.RefCount
+		if actual != b.RefCount {
+			i := FsckIssue{Kind: "refcount", Key: b.Key, Detail: fmt.Sprintf("blob %s has ref_count %d but %d nodes refer to it", id, b.RefCount, actual)}
+			var err error
+			if !r.opts.DryRun {
+				var ok bool
+				if ok, err = r.blobRepo.SetBlobRefCount(id, b.RefCount, actual); err == nil && ok {
+					current = actual
+					i.Action = "fixed"
+				} else if err == nil {
+					i.Action = "skipped: changed concurrently"
+				}
+			}
+			r.issue(i, err)
+		}
+		// without references the blob document goes too; the content itself
+		// is handled as an orphan
+		if current <= 0 && r.repairing(r.opts.Orphans) {
+			if _, err := r.blobRepo.DeleteBlobIfUnreferenced(id); err != nil {
+				return referenced, err
+			}
+		}
+	}
+
+	return referenced, nil
+}
+
+func (r *fsckRun) checkOrphans(referenced map[string]bool) error {
+	keys := make([]string, 0, len(r.objects))
+	for k := range r.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		info := r.objects[key]
+		if referenced[key] || info.ModTime.After(r.cutoff) {
+			continue
+		}
+		// thumbnails and renditions are kept as long as their blob
+		if id, ok := derivedBlobID(key); ok && r.blobs[id] != nil {
+			continue
+		}
+		i := FsckIssue{Kind: "orphan_blob", Key: key, Detail: fmt.Sprintf("%d bytes, modified %s", info.Size, info.ModTime.UTC().Format(time.RFC3339))}
+		var err error
+		if r.repairing(r.opts.Orphans) {
+			switch r.opts.Orphans {
+			case FsckQuarantine:
+				err = r.storage.Blobs.Move(key, path.Join(quarantinePrefix, key))
+				i.Action = "quarantined"
+			case FsckDelete:
+				err = r.storage.Blobs.Delete(key)
+				i.Action = "deleted"
+			}
+		}
+		r.issue(i, err)
+	}
+	return nil
+}
+
+// checkTree finds nodes whose parent is not a folder of the same owner and
+// folder cycles, which are unreachable from the root.
+func (r *fsckRun) checkTree() {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	ids := r.sortedNodeIDs()
+
+	for _, id := range ids {
+		n := r.nodes[id]
+		if n.ParentID == "" || r.removed[id] || n.UpdatedAt.After(r.cutoff) {
+			continue
+		}
+		if n.TrashedAt != nil && n.TrashedWith == "" {
+			// the folder a deleted node came from may be gone for good
+			continue
+		}
+		p := r.nodes[n.ParentID]
+		var problem string
+		switch {
+		case p == nil || r.removed[p.ID]:
+			problem = "parent " + n.ParentID + " does not exist"
+		case p.TrashedAt != nil && n.TrashedAt == nil:
+			problem = "parent " + n.ParentID + " is in the trash"
+		case p.OwnerID != n.OwnerID:
+			problem = "parent " + n.ParentID + " belongs to another user"
+		case p.Type != "folder":
+			problem = "parent " + n.ParentID + " is not a folder"
+		default:
+			continue
+		}
+		state[id] = done
+		r.detach(FsckIssue{Kind: "dangling_parent", OwnerID: n.OwnerID, NodeID: n.ID, Detail: n.Name + ": " + problem})
+	}
+
+	for _, id := range ids {
+		var chain []string
+		cur := id
+		for cur != "" && state[cur] == 0 {
+			n := r.nodes[cur]
+			if n == nil || r.removed[cur] {
+				break
+			}
+			state[cur] = visiting
+			chain = append(chain, cur)
+			cur = n.ParentID
+		}
+		if cur != "" && state[cur] == visiting {
+			// cur is where the chain closes; the cycle is everything from there
+			var cycle []string
+			for i, c := range chain {
+				if c == cur {
+					cycle = chain[i:]
+					break
+				}
+			}
+			victim := cycle[0]
+			for _, c := range cycle {
+				if c < victim {
+					victim = c
+				}
+			}
+			n := r.nodes[victim]
+			r.detach(FsckIssue{Kind: "cycle", OwnerID: n.OwnerID, NodeID: n.ID, Detail: "folders " + strings.Join(cycle, " -> ") + " form a cycle"})
+		}
+		for _, c := range chain {
+			state[c] = done
+		}
+	}
+}
+
+// detach applies the dangling action to the node of the issue.
+func (r *fsckRun) detach(i FsckIssue) {
+	if !r.repairing(r.opts.Dangling) {
+		r.issue(i, nil)
+		return
+	}
+	n := r.nodes[i.NodeID]
+	switch r.opts.Dangling {
+	case FsckLostFound:
+		folderID, err := r.lostFoundFolder(n.OwnerID)
+		if err == nil {
+			_, err = placeUnique(r.fileRepo, n.OwnerID, folderID, n.Name, n.Type, func(name string) error {
+				return r.fileRepo.MoveNode(n.OwnerID, n.ID, folderID, name)
+			})
+		}
+		i.Action = "moved to " + LostFoundName
+		r.issue(i, err)
+	case FsckDelete:
+		i.Action = "deleted subtree"
+		r.issue(i, r.deleteSubtree(n.ID))
+	}
+}
+
+func (r *fsckRun) deleteSubtree(rootID string) error {
+	queue := []string{rootID}
+	seen := map[string]bool{}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] || r.removed[id] {
+			continue
+		}
+		seen[id] = true
+		queue = append(queue, r.children[id]...)
+
+		// deleted first so that a node is never released twice
+		n := r.nodes[id]
+		deleted, err := r.fileRepo.PurgeNode(id)
+		if err != nil {
+			return err
+		}
+		r.removed[id] = true
+		if deleted && n.Type == "file" {
+			if err := r.storage.ReleaseFile(n); err != nil {
+				log.Printf("fsck: failed to release content of %s: %v", id, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *fsckRun) lostFoundFolder(ownerID string) (string, error) {
+	if id, ok := r.lostFound[ownerID]; ok {
+		return id, nil
+	}
+	folder, _, err := ensureFolder(r.fileRepo, ownerID, "", LostFoundName)
+	if err != nil {
+		return "", err
+	}
+	r.lostFound[ownerID] = folder.ID
+	return folder.ID, nil
+}
+
+// StartPeriodic runs Run every interval in the background and logs what it
+// found.
+func (f *FsckService) StartPeriodic(interval time.Duration, opts FsckOptions) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			res, err := f.Run(opts)
+			if err != nil {
+				log.Printf("fsck: %v", err)
+				continue
+			}
+			for _, i := range res.Issues {
+				log.Printf("fsck: %s", i)
+			}
+			log.Printf("fsck: checked %d nodes, %d blobs, %d stored objects: %d issues", res.Nodes, res.Blobs, res.Objects, len(res.Issues))
+		}
+	}()
+}
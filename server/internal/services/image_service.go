@@ -0,0 +1,233 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"server/internal/models"
+)
+
+var (
+	// ErrNotImage is returned for files that are no image ImageService can
+	// decode, or are larger than its limits.
+	ErrNotImage = errors.New("file is not an image that can be converted")
+	// ErrInvalidImageOptions is returned, wrapped with the reason, for
+	// options ImageService does not support.
+	ErrInvalidImageOptions = errors.New("invalid image options")
+)
+
+// How a resized image fills the requested width and height.
+const (
+	FitContain = "contain" // fits inside, keeping the aspect ratio
+	FitCover   = "cover"   // fills it, cropping the middle of the image
+	FitFill    = "fill"    // fills it, stretching the image
+)
+
+// The formats images are converted to.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+const (
+	DefaultImageQuality = 85
+	// maxImageSide bounds the width and the height of a rendition.
+	maxImageSide = 4096
+	// maxRenditions is how many renditions are kept of a content; more
+	// are made on every request.
+	maxRenditions = 50
+)
+
+// ImageOptions is how an image is converted.
+type ImageOptions struct {
+	// Width and Height are in pixels; 0 follows from the other by the
+	// aspect ratio, both 0 keep the size.
+	Width, Height int
+	Fit           string // FitContain if empty
+	// Format is FormatPNG for PNG files and FormatJPEG for others if
+	// empty.
+	Format string
+	// Quality of JPEG images from 1 to 100, DefaultImageQuality if 0.
+	Quality int
+}
+
+// Name identifies the rendition made with the options.
+func (o ImageOptions) Name() string {
+	ext := "jpg"
+	if o.Format == FormatPNG {
+		ext = "png"
+	}
+	return fmt.Sprintf("w%d-h%d-%s-q%d.%s", o.Width, o.Height, o.Fit, o.Quality, ext)
+}
+
+// ContentType is the media type of images made with the options.
+func (o ImageOptions) ContentType() string {
+	if o.Format == FormatPNG {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// resolve checks the options and fills in the defaults for file node n, so
+// that options making the same image have the same Name.
+func (o ImageOptions) resolve(n *models.Node) (ImageOptions, error) {
+	if o.Width < 0 || o.Width > maxImageSide || o.Height < 0 || o.Height > maxImageSide {
+		return o, fmt.Errorf("%w: w and h must be 0 to %d", ErrInvalidImageOptions, maxImageSide)
+	}
+	switch o.Fit {
+	case "":
+		o.Fit = FitContain
+	case FitContain, FitCover, FitFill:
+	default:
+		return o, fmt.Errorf("%w: fit must be contain, cover or fill", ErrInvalidImageOptions)
+	}
+	if o.Width == 0 || o.Height == 0 {
+		// the aspect ratio is kept whatever the fit
+		o.Fit = FitContain
+	}
+	switch o.Format {
+	case "":
+		o.Format = FormatJPEG
+		if n.Mime == "image/png" || strings.ToLower(path.Ext(n.Name)) == ".png" {
+			o.Format = FormatPNG
+		}
+	case "jpg":
+		o.Format = FormatJPEG
+	case FormatJPEG, FormatPNG:
+	default:
+		return o, fmt.Errorf("%w: format must be jpeg or png", ErrInvalidImageOptions)
+	}
+	switch {
+	case o.Quality < 0 || o.Quality > 100:
+		return o, fmt.Errorf("%w: quality must be 1 to 100", ErrInvalidImageOptions)
+	case o.Format == FormatPNG:
+		o.Quality = 0
+	case o.Quality == 0:
+		o.Quality = DefaultImageQuality
+	}
+	return o, nil
+}
+
+// ImageService resizes, crops and converts JPEG, PNG and GIF images, turned
+// upright by their EXIF orientation. The renditions are stored next to the
+// content like thumbnails, so that each is made once. Images are never
+// enlarged.
+type ImageService struct {
+	storage *StorageService
+}
+
+func NewImageService(storage *StorageService) *ImageService {
+	return &ImageService{storage: storage}
+}
+
+// Open returns a reader over the rendition of file node n made with the
+// options, and the options with their defaults filled in. It returns
+// ErrNotImage if n is no image it can convert.
+func (s *ImageService) Open(n *models.Node, o ImageOptions) (*BlobReader, ImageOptions, error) {
+	o, err := o.resolve(n)
+	if err != nil {
+		return nil, o, err
+	}
+	if !IsImage(n) {
+		return nil, o, ErrNotImage
+	}
+	name := o.Name()
+	r, err := s.storage.OpenRendition(n, name)
+	if !errors.Is(err, ErrBlobNotFound) {
+		return r, o, err
+	}
+	data, err := s.render(n, o)
+	if err != nil {
+		return nil, o, err
+	}
+	if _, err := s.storage.PutRendition(n, name, bytes.NewReader(data)); err != nil {
+		log.Printf("images: %s: %v", n.ID, err)
+	}
+	return &BlobReader{
+		info: &BlobInfo{Size: int64(len(data)), ModTime: time.Now()},
+		open: func(offset, length int64) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data[offset : offset+length])), nil
+		},
+	}, o, nil
+}
+
+// render decodes file node n and encodes it as o asks.
+func (s *ImageService) render(n *models.Node, o ImageOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	err := withImage(s.storage, n, func(img image.Image, orientation int) error {
+		// the geometry is worked out upright and turned back to how the
+		// image is stored, which the centered crop does not depend on
+		b := img.Bounds()
+		sw, sh := b.Dx(), b.Dy()
+		if orientation >= 5 {
+			sw, sh = sh, sw
+		}
+		cw, ch, w, h := imageGeometry(sw, sh, o)
+		if orientation >= 5 {
+			cw, ch, w, h = ch, cw, h, w
+		}
+		crop := image.Rect(0, 0, cw, ch).Add(b.Min).Add(image.Pt((b.Dx()-cw)/2, (b.Dy()-ch)/2))
+		out := orient(resample(img, crop, w, h, o.Format == FormatJPEG), orientation)
+		if o.Format == FormatPNG {
+			return png.Encode(&buf, out)
+		}
+		return jpeg.Encode(&buf, out, &jpeg.Options{Quality: o.Quality})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// imageGeometry returns the size of the middle area of an image of sw x sh
+// pixels that o uses, and the size it is scaled to.
+func imageGeometry(sw, sh int, o ImageOptions) (cw, ch, w, h int) {
+	cw, ch = sw, sh
+	w, h = o.Width, o.Height
+	switch {
+	case w == 0 && h == 0:
+		w, h = sw, sh
+	case w == 0:
+		w = scaleSide(sw, h, sh)
+	case h == 0:
+		h = scaleSide(sh, w, sw)
+	}
+	switch o.Fit {
+	case FitContain:
+		if w*sh > h*sw {
+			w = scaleSide(sw, h, sh)
+		} else {
+			h = scaleSide(sh, w, sw)
+		}
+	case FitCover:
+		if w*sh > h*sw {
+			ch = scaleSide(sw, h, w)
+		} else {
+			cw = scaleSide(sh, w, h)
+		}
+	}
+	// never larger than the area used, nor than maxImageSide
+	mw, mh := min(cw, maxImageSide), min(ch, maxImageSide)
+	if w > mw || h > mh {
+		if w*mh > h*mw {
+			w, h = mw, scaleSide(h, mw, w)
+		} else {
+			w, h = scaleSide(w, mh, h), mh
+		}
+	}
+	return cw, ch, w, h
+}
+
+// scaleSide returns side * num / den rounded, at least 1.
+func scaleSide(side, num, den int) int {
+	return maxInt((side*num+den/2)/den, 1)
+}
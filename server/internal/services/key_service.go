@@ -0,0 +1,214 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"server/internal/models"
+	"server/internal/repository"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrUnknownMasterKey = errors.New("unknown master key")
+
+// KeyService implements envelope encryption: every user gets a random data
+// key that encrypts their blobs, and data keys are stored wrapped by the
+// active master key. Rotating the master key only re-wraps data keys.
+type KeyService struct {
+	repo        repository.KeyRepository
+	activeID    string
+	masterKeys  map[string][]byte
+	mu          sync.RWMutex
+	dataKeys    map[string][]byte
+	ownerKeyIDs map[string]string
+}
+
+// ParseMasterKeys parses "id:base64key,id:base64key" into a keyring.
+func ParseMasterKeys(spec string) (map[string][]byte, error) {
+	keys := map[string][]byte{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid master key entry %q (want id:base64)", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key %s: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master key %s must be 32 bytes", id)
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+func NewKeyService(repo repository.KeyRepository, activeID string, masterKeys map[string][]byte) (*KeyService, error) {
+	if _, ok := masterKeys[activeID]; !ok {
+		return nil, fmt.Errorf("active master key %q not in keyring", activeID)
+	}
+	return &KeyService{
+		repo:        repo,
+		activeID:    activeID,
+		masterKeys:  masterKeys,
+		dataKeys:    map[string][]byte{},
+		ownerKeyIDs: map[string]string{},
+	}, nil
+}
+
+func (k *KeyService) ActiveMasterKeyID() string {
+	return k.activeID
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *KeyService) wrap(masterKeyID, ownerID string, dataKey []byte) ([]byte, error) {
+	master, ok := k.masterKeys[masterKeyID]
+	if !ok {
+		return nil, ErrUnknownMasterKey
+	}
+	aead, err := newGCM(master)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(ownerID)), nil
+}
+
+func (k *KeyService) unwrap(dk *models.DataKey) ([]byte, error) {
+	master, ok := k.masterKeys[dk.MasterKeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMasterKey, dk.MasterKeyID)
+	}
+	aead, err := newGCM(master)
+	if err != nil {
+		return nil, err
+	}
+	if len(dk.WrappedKey) < aead.NonceSize() {
+		return nil, errors.New("wrapped key too short")
+	}
+	nonce, ct := dk.WrappedKey[:aead.NonceSize()], dk.WrappedKey[aead.NonceSize():]
+	key, err := aead.Open(nil, nonce, ct, []byte(dk.OwnerID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key %s: %w", dk.ID, err)
+	}
+	return key, nil
+}
+
+// DataKeyFor returns the owner's data key, creating it on first use.
+func (k *KeyService) DataKeyFor(ownerID string) (string, []byte, error) {
+	k.mu.RLock()
+	id, ok := k.ownerKeyIDs[ownerID]
+	if ok {
+		key := k.dataKeys[id]
+		k.mu.RUnlock()
+		return id, key, nil
+	}
+	k.mu.RUnlock()
+
+	dk, err := k.repo.FindDataKeyByOwner(ownerID)
+	if err != nil {
+		return "", nil, err
+	}
+	if dk == nil {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return "", nil, err
+		}
+		wrapped, err := k.wrap(k.activeID, ownerID, raw)
+		if err != nil {
+			return "", nil, err
+		}
+		dk = &models.DataKey{OwnerID: ownerID, WrappedKey: wrapped, MasterKeyID: k.activeID}
+		if err := k.repo.CreateDataKey(dk); err != nil {
+			if !mongo.IsDuplicateKeyError(err) {
+				return "", nil, err
+			}
+			// another request created the key first
+			if dk, err = k.repo.FindDataKeyByOwner(ownerID); err != nil || dk == nil {
+				return "", nil, fmt.Errorf("failed to load data key: %v", err)
+			}
+		}
+	}
+	key, err := k.unwrap(dk)
+	if err != nil {
+		return "", nil, err
+	}
+	k.mu.Lock()
+	k.dataKeys[dk.ID] = key
+	k.ownerKeyIDs[ownerID] = dk.ID
+	k.mu.Unlock()
+	return dk.ID, key, nil
+}
+
+func (k *KeyService) DataKey(id string) ([]byte, error) {
+	k.mu.RLock()
+	key, ok := k.dataKeys[id]
+	k.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	dk, err := k.repo.FindDataKey(id)
+	if err != nil {
+		return nil, err
+	}
+	if dk == nil {
+		return nil, fmt.Errorf("data key %s not found", id)
+	}
+	key, err = k.unwrap(dk)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	k.dataKeys[id] = key
+	k.mu.Unlock()
+	return key, nil
+}
+
+// RewrapAll re-wraps every data key that is not yet wrapped by the active
+// master key. Blob contents are untouched.
+func (k *KeyService) RewrapAll() (int, error) {
+	stale, err := k.repo.ListDataKeysNotWrappedBy(k.activeID)
+	if err != nil {
+		return 0, err
+	}
+	rewrapped := 0
+	for _, dk := range stale {
+		key, err := k.unwrap(dk)
+		if err != nil {
+			return rewrapped, err
+		}
+		wrapped, err := k.wrap(k.activeID, dk.OwnerID, key)
+		if err != nil {
+			return rewrapped, err
+		}
+		ok, err := k.repo.RewrapDataKey(dk.ID, dk.MasterKeyID, k.activeID, wrapped)
+		if err != nil {
+			return rewrapped, err
+		}
+		if ok {
+			rewrapped++
+		}
+	}
+	return rewrapped, nil
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+// LayoutMigration moves content written under the old per-owner layouts
+// (file nodes and avatars whose path is not a blob key) into content
+// addressed blobs. Every item is copied, switched over and only then
+// deleted, so an interrupted run is simply started again.
+type LayoutMigration struct {
+	fileRepo repository.FileRepository
+	userRepo repository.UserRepository
+	storage  *StorageService
+	// OldBases lists previous storage base directories; paths below them are
+	// looked up relative to the current one.
+	OldBases []string
+}
+
+func NewLayoutMigration(fileRepo repository.FileRepository, userRepo repository.UserRepository, storage *StorageService) *LayoutMigration {
+	return &LayoutMigration{fileRepo: fileRepo, userRepo: userRepo, storage: storage}
+}
+
+type MigrationResult struct {
+	Pending  int
+	Migrated int
+	Missing  int
+	Failed   int
+}
+
+// Run migrates every legacy file node and avatar; with dryRun it only
+// counts them.
+func (m *LayoutMigration) Run(dryRun bool) (*MigrationResult, error) {
+	// collect first, copying takes long enough for a cursor to time out
+	var nodes []*models.Node
+	if err := m.fileRepo.WalkNodes(func(n *models.Node) error {
+		if n.Type == "file" && nodeBlobID(n) == "" && n.Path != "" {
+			nodes = append(nodes, n)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	users, err := m.userRepo.ListUsersWithAvatar()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &MigrationResult{}
+	for _, u := range users {
+		if u.AvatarBlobID == "" {
+			res.Pending++
+		}
+	}
+	res.Pending += len(nodes)
+	if dryRun {
+		return res, nil
+	}
+
+	for _, n := range nodes {
+		m.migrate(res, "node "+n.ID, n.OwnerID, n.Path, func(b *models.Blob) (bool, error) {
+			return m.fileRepo.SetNodeBlob(n.ID, n.Path, b)
+		})
+	}
+	for _, u := range users {
+		if u.AvatarBlobID != "" {
+			continue
+		}
+		m.migrate(res, "avatar of "+u.ID, u.ID, u.AvatarURL, func(b *models.Blob) (bool, error) {
+			return m.userRepo.SetAvatarBlob(u.ID, u.AvatarURL, b)
+		})
+	}
+	return res, nil
+}
+
+func (m *LayoutMigration) migrate(res *MigrationResult, what, ownerID, oldPath string, switchTo func(b *models.Blob) (bool, error)) {
+	key := m.legacyKey(oldPath)
+	src, err := m.storage.Open(key)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			log.Printf("migrate-storage: %s: %s is missing", what, oldPath)
+			res.Missing++
+			return
+		}
+		log.Printf("migrate-storage: %s: %v", what, err)
+		res.Failed++
+		return
+	}
+	blob, err := m.storage.IngestLimited(ownerID, src, 0, nil)
+	src.Close()
+	if err != nil {
+		log.Printf("migrate-storage: %s: %v", what, err)
+		res.Failed++
+		return
+	}
+	ok, err := switchTo(blob)
+	if err != nil || !ok {
+		_ = m.storage.Release(blob.ID)
+		if err == nil {
+			err = fmt.Errorf("changed while migrating, will retry on the next run")
+		}
+		log.Printf("migrate-storage: %s: %v", what, err)
+		res.Failed++
+		return
+	}
+	if err := m.storage.DeleteFile(key); err != nil {
+		log.Printf("migrate-storage: %s: failed to delete %s: %v", what, oldPath, err)
+	}
+	res.Migrated++
+}
+
+// legacyKey translates a path recorded under one of OldBases.
+func (m *LayoutMigration) legacyKey(p string) string {
+	for _, base := range m.OldBases {
+		rel, err := filepath.Rel(base, filepath.FromSlash(p))
+		if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return p
+}
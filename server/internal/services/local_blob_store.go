@@ -0,0 +1,220 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type LocalBlobStore struct {
+	Root string
+}
+
+func NewLocalBlobStore(root string) *LocalBlobStore {
+	return &LocalBlobStore{Root: root}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (l *LocalBlobStore) resolve(key string) (string, error) {
+	if l.Root == "" {
+		return "", fmt.Errorf("storage base path not set")
+	}
+	absRoot, err := filepath.Abs(l.Root)
+	if err != nil {
+		return "", fmt.Errorf("internal error: %w", err)
+	}
+
+	native := filepath.FromSlash(key)
+	var absPath string
+	if rel, err := filepath.Rel(l.Root, native); filepath.IsAbs(native) || (err == nil && !strings.HasPrefix(rel, "..")) {
+		// nodes written before the blob store existed carry the full path
+		// until migrate-storage has moved them
+		absPath, err = filepath.Abs(native)
+		if err != nil {
+			return "", fmt.Errorf("internal error: %w", err)
+		}
+	} else {
+		clean := strings.TrimLeft(path.Clean("/"+filepath.ToSlash(key)), "/")
+		if clean == "" {
+			return "", fmt.Errorf("empty blob key")
+		}
+		absPath = filepath.Join(absRoot, filepath.FromSlash(clean))
+	}
+
+	if absPath == absRoot || !strings.HasPrefix(absPath, absRoot+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid blob key")
+	}
+	return absPath, nil
+}
+
+func (l *LocalBlobStore) Put(key string, r io.Reader) (int64, error) {
+	dest, err := l.resolve(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create directories: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".put-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		tmp.Close()
+		_ = os.Remove(tmpPath)
+	}()
+
+	n, err := io.Copy(tmp, r)
+	if err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to finalize temp file: %w", err)
+	}
+	if err := os.Rename(tmpPath, dest); err != nil {
+		return 0, fmt.Errorf("failed to move temp file: %w", err)
+	}
+	return n, nil
+}
+
+func (l *LocalBlobStore) Get(key string) (io.ReadCloser, error) {
+	return l.GetRange(key, 0, -1)
+}
+
+func (l *LocalBlobStore) GetRange(key string, offset, length int64) (io.ReadCloser, error) {
+	p, err := l.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if length < 0 {
+		return f, nil
+	}
+	return readCloser{Reader: io.LimitReader(f, length), Closer: f}, nil
+}
+
+func (l *LocalBlobStore) Stat(key string) (*BlobInfo, error) {
+	p, err := l.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, ErrBlobNotFound
+	}
+	return &BlobInfo{Key: key, Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+func (l *LocalBlobStore) Delete(key string) error {
+	p, err := l.resolve(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *LocalBlobStore) Move(src, dst string) error {
+	from, err := l.resolve(src)
+	if err != nil {
+		return err
+	}
+	to, err := l.resolve(dst)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+	if err := os.Rename(from, to); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrBlobNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (l *LocalBlobStore) List(prefix string, fn func(info BlobInfo) error) error {
+	absRoot, err := filepath.Abs(l.Root)
+	if err != nil {
+		return fmt.Errorf("internal error: %w", err)
+	}
+	err = filepath.WalkDir(absRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(absRoot, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		return fn(BlobInfo{Key: key, Size: fi.Size(), ModTime: fi.ModTime()})
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// CanonicalKey maps any key this store accepts, including the full paths
+// stored by older nodes, to the root relative key reported by List.
+func (l *LocalBlobStore) CanonicalKey(key string) (string, error) {
+	p, err := l.resolve(key)
+	if err != nil {
+		return "", err
+	}
+	absRoot, err := filepath.Abs(l.Root)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absRoot, p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"unicode/utf8"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+var (
+	// ErrInvalidMetadata is returned, wrapped with the reason, for a
+	// description or metadata the limits or the key definitions do not
+	// allow.
+	ErrInvalidMetadata = errors.New("invalid metadata")
+	// ErrMetadataKeyNotFound is returned for a key the owner has not
+	// defined.
+	ErrMetadataKeyNotFound = errors.New("metadata key not defined")
+	// ErrMetadataKeyInUse is returned for a definition nodes contradict.
+	ErrMetadataKeyInUse = errors.New("metadata key is in use")
+)
+
+// The types of metadata values.
+const (
+	MetadataString  = "string"
+	MetadataNumber  = "number"
+	MetadataBoolean = "boolean"
+)
+
+const (
+	maxDescription   = 4000 // characters
+	maxMetadataKeys  = 50
+	maxMetadataValue = 1024 // characters of a string value
+	// maxMetadataSize bounds the metadata of a node as JSON.
+	maxMetadataSize = 16 << 10
+)
+
+// MetadataService keeps the description and the metadata of nodes. Metadata
+// maps keys, see repository.ValidMetadataKey, to a string, a number or a
+// boolean, so that listings and search can filter by them. Each key of an
+// owner holds values of one type, declared with DefineKey or taken from the
+// first value stored under it.
+type MetadataService struct {
+	fileRepo repository.FileRepository
+	keyRepo  repository.MetadataKeyRepository
+}
+
+func NewMetadataService(fileRepo repository.FileRepository, keyRepo repository.MetadataKeyRepository) *MetadataService {
+	return &MetadataService{fileRepo: fileRepo, keyRepo: keyRepo}
+}
+
+// MetadataUpdate changes the description and metadata of a node.
+type MetadataUpdate struct {
+	// Description replaces the description unless it is nil; "" removes it.
+	Description *string
+	// Set adds or replaces keys, a nil value removes the key.
+	Set map[string]interface{}
+	// Replace drops the keys not in Set.
+	Replace bool
+}
+
+// Update applies u to the owner's node. It returns ErrNodeNotFound if the
+// owner has no such node outside the trash, and ErrNodeChanged if the node
+// kept changing while it was updated.
+func (s *MetadataService) Update(ownerID, nodeID string, u MetadataUpdate) (*models.Node, error) {
+	if u.Description != nil && utf8.RuneCountInString(*u.Description) > maxDescription {
+		return nil, fmt.Errorf("%w: description is longer than %d characters", ErrInvalidMetadata, maxDescription)
+	}
+	set := map[string]interface{}{}
+	for k, v := range u.Set {
+		if !repository.ValidMetadataKey(k) {
+			return nil, fmt.Errorf("%w: key %q must be 1 to 64 letters, digits, _ or -", ErrInvalidMetadata, k)
+		}
+		if v == nil {
+			continue
+		}
+		v, err := metadataValue(k, v)
+		if err != nil {
+			return nil, err
+		}
+		set[k] = v
+	}
+
+	// the limits and the keys to drop depend on the metadata the node has,
+	// so the update only applies if the node did not change since it was
+	// read
+	for attempt := 0; attempt < 3; attempt++ {
+		n, err := s.fileRepo.FindNodeByID(nodeID)
+		if err != nil {
+			return nil, err
+		}
+		if n == nil || n.OwnerID != ownerID || n.TrashedAt != nil {
+			return nil, ErrNodeNotFound
+		}
+
+		var unset []string
+		result := map[string]interface{}{}
+		if !u.Replace {
+			for k, v := range n.Metadata {
+				result[k] = v
+			}
+		} else {
+			for k := range n.Metadata {
+				if _, ok := u.Set[k]; !ok {
+					unset = append(unset, k)
+				}
+			}
+		}
+		for k, v := range u.Set {
+			if v == nil {
+				if _, ok := n.Metadata[k]; ok {
+					unset = append(unset, k)
+				}
+				delete(result, k)
+				continue
+			}
+			result[k] = set[k]
+		}
+		if len(result) > maxMetadataKeys {
+			return nil, fmt.Errorf("%w: more than %d keys", ErrInvalidMetadata, maxMetadataKeys)
+		}
+		if raw, _ := json.Marshal(result); len(raw) > maxMetadataSize {
+			return nil, fmt.Errorf("%w: larger than %d bytes", ErrInvalidMetadata, maxMetadataSize)
+		}
+		if attempt == 0 {
+			if err := s.checkTypes(ownerID, set); err != nil {
+				return nil, err
+			}
+		}
+
+		sort.Strings(unset)
+		ok, err := s.fileRepo.UpdateMetadata(ownerID, n.ID, n.UpdatedAt, u.Description, set, unset)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		updated, err := s.fileRepo.FindNodeByID(n.ID)
+		if err != nil {
+			return nil, err
+		}
+		if updated == nil {
+			return nil, ErrNodeNotFound
+		}
+		return updated, nil
+	}
+	return nil, ErrNodeChanged
+}
+
+// checkTypes compares the values of set with the types of the owner's keys,
+// defining the keys that are not by the type of their value.
+func (s *MetadataService) checkTypes(ownerID string, set map[string]interface{}) error {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		typ := metadataType(set[k])
+		def, err := s.keyRepo.AddMetadataKey(&models.MetadataKey{OwnerID: ownerID, Key: k, Type: typ})
+		if err != nil {
+			return err
+		}
+		if def != nil && def.Type != typ {
+			return fmt.Errorf("%w: value of %q must be a %s", ErrInvalidMetadata, k, def.Type)
+		}
+	}
+	return nil
+}
+
+// ListKeys returns the owner's key definitions by key.
+func (s *MetadataService) ListKeys(ownerID string) ([]*models.MetadataKey, error) {
+	keys, err := s.keyRepo.ListMetadataKeys(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []*models.MetadataKey{}
+	}
+	return keys, nil
+}
+
+// DefineKey declares the type of the owner's key, replacing its type if it
+// is defined already. It returns ErrMetadataKeyInUse if a node has a value
+// of another type for it.
+func (s *MetadataService) DefineKey(ownerID, key, typ string) (*models.MetadataKey, error) {
+	if !repository.ValidMetadataKey(key) {
+		return nil, fmt.Errorf("%w: key %q must be 1 to 64 letters, digits, _ or -", ErrInvalidMetadata, key)
+	}
+	switch typ {
+	case MetadataString, MetadataNumber, MetadataBoolean:
+	default:
+		return nil, fmt.Errorf("%w: type must be string, number or boolean", ErrInvalidMetadata)
+	}
+	inUse, err := s.fileRepo.MetadataKeyInUse(ownerID, key, typ)
+	if err != nil {
+		return nil, err
+	}
+	if inUse {
+		return nil, fmt.Errorf("%w: nodes have values of another type for %q", ErrMetadataKeyInUse, key)
+	}
+	if err := s.keyRepo.PutMetadataKey(&models.MetadataKey{OwnerID: ownerID, Key: key, Type: typ}); err != nil {
+		return nil, err
+	}
+	return s.keyRepo.FindMetadataKey(ownerID, key)
+}
+
+// DeleteKey removes the definition of the owner's key. It returns
+// ErrMetadataKeyInUse while a node, also in the trash, has a value for it.
+func (s *MetadataService) DeleteKey(ownerID, key string) error {
+	inUse, err := s.fileRepo.MetadataKeyInUse(ownerID, key, "")
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return fmt.Errorf("%w: nodes have values for %q", ErrMetadataKeyInUse, key)
+	}
+	deleted, err := s.keyRepo.DeleteMetadataKey(ownerID, key)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrMetadataKeyNotFound
+	}
+	return nil
+}
+
+// metadataType returns the type of a value checked by metadataValue.
+func metadataType(v interface{}) string {
+	switch v.(type) {
+	case float64:
+		return MetadataNumber
+	case bool:
+		return MetadataBoolean
+	}
+	return MetadataString
+}
+
+// metadataValue checks the value of key k as decoded from JSON.
+func metadataValue(k string, v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		if utf8.RuneCountInString(v) > maxMetadataValue {
+			return nil, fmt.Errorf("%w: value of %q is longer than %d characters", ErrInvalidMetadata, k, maxMetadataValue)
+		}
+		return v, nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, fmt.Errorf("%w: value of %q is not a finite number", ErrInvalidMetadata, k)
+		}
+		return v, nil
+	case bool:
+		return v, nil
+	}
+	return nil, fmt.Errorf("%w: value of %q must be a string, a number or a boolean", ErrInvalidMetadata, k)
+}
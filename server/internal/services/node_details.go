@@ -0,0 +1,151 @@
+package services
+
+import (
+	"strings"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+// NodeDetails is what the dashboard shows about a node besides the node
+// itself.
+type NodeDetails struct {
+	// Ancestors are the folders above the node, the top level one first.
+	Ancestors []*models.Node
+	// Path is the node's slash separated path from the root, e.g. "/a/b.txt".
+	Path string
+	// ChildFolders and ChildFiles count the direct children of a folder.
+	ChildFolders int
+	ChildFiles   int
+	// TotalSize and TotalFiles sum up every file below a folder.
+	TotalSize  int64
+	TotalFiles int
+	// Partial is set when the folder has more than maxDetailFolders
+	// folders below it; the totals then only cover the files in the
+	// first of them, nearest first.
+	Partial bool
+}
+
+const (
+	// maxDetailFolders bounds the folders Details sums up files in.
+	maxDetailFolders = 10000
+	// folderBatch is how many folders are looked into with one query.
+	folderBatch = 500
+)
+
+// Details returns the ancestors of n and, for a folder, its child counts
+// and recursive size.
+func (s *NodeService) Details(n *models.Node) (*NodeDetails, error) {
+	above, err := ancestors(s.fileRepo, n, nil)
+	if err != nil {
+		return nil, err
+	}
+	d := &NodeDetails{Ancestors: above, Path: nodePath(above, n)}
+
+	if n.Type != "folder" {
+		return d, nil
+	}
+	seen := map[string]bool{n.ID: true}
+	for _, a := range above {
+		seen[a.ID] = true
+	}
+	// the tree is walked level by level, a batch of folders per query
+	queue := []string{n.ID}
+	for i := 0; i < len(queue); {
+		if i >= maxDetailFolders {
+			d.Partial = true
+			break
+		}
+		batch := queue[i:min(i+folderBatch, len(queue), maxDetailFolders)]
+		files, size, err := s.fileRepo.SumChildFiles(n.OwnerID, batch)
+		if err != nil {
+			return nil, err
+		}
+		folders, err := s.fileRepo.ListChildFolderIDs(n.OwnerID, batch)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			d.ChildFiles = files
+			d.ChildFolders = len(folders)
+		}
+		d.TotalFiles += files
+		d.TotalSize += size
+		for _, id := range folders {
+			if !seen[id] {
+				seen[id] = true
+				queue = append(queue, id)
+			}
+		}
+		i += len(batch)
+	}
+	return d, nil
+}
+
+// ancestors returns the folders above n, the top level one first. The chain
+// stops at a parent that is gone or belongs to someone else. Parents are
+// looked up in cache first and added to it, if it is not nil.
+func ancestors(fileRepo repository.FileRepository, n *models.Node, cache map[string]*models.Node) ([]*models.Node, error) {
+	var out []*models.Node
+	seen := map[string]bool{n.ID: true}
+	for pid := n.ParentID; pid != "" && !seen[pid] && len(out) < 1000; {
+		seen[pid] = true
+		p, ok := cache[pid]
+		if !ok {
+			var err error
+			if p, err = fileRepo.FindNodeByID(pid); err != nil {
+				return nil, err
+			}
+			if cache != nil {
+				cache[pid] = p
+			}
+		}
+		if p == nil || p.OwnerID != n.OwnerID {
+			break
+		}
+		out = append(out, p)
+		pid = p.ParentID
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+// nodePath returns the slash separated path of n below ancestors.
+func nodePath(ancestors []*models.Node, n *models.Node) string {
+	names := make([]string, 0, len(ancestors)+1)
+	for _, a := range ancestors {
+		names = append(names, a.Name)
+	}
+	return "/" + strings.Join(append(names, n.Name), "/")
+}
+
+// Resolve returns the node of ownerID at a slash separated path such as
+// "/a/b/c.txt". Names are matched like sibling names are kept unique, so
+// case does not matter. Returns ErrNodeNotFound if any part is missing.
+func (s *NodeService) Resolve(ownerID, path string) (*models.Node, error) {
+	var n *models.Node
+	parentID := ""
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		if n != nil && n.Type != "folder" {
+			return nil, ErrNodeNotFound
+		}
+		child, err := s.fileRepo.FindChildByName(ownerID, parentID, name)
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			return nil, ErrNodeNotFound
+		}
+		n = child
+		parentID = child.ID
+	}
+	if n == nil {
+		return nil, ErrNodeNotFound
+	}
+	return n, nil
+}
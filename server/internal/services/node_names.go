@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+// maxNameAttempts bounds the retries of placing a node under a free name.
+const maxNameAttempts = 100
+
+// UniqueName returns name, or "name (n)" with the smallest n whose name key
+// is not in taken. File names keep their extension last: "report (1).pdf".
+func UniqueName(name, nodeType string, taken map[string]bool) string {
+	if !taken[repository.NameKey(name)] {
+		return name
+	}
+	ext := ""
+	if nodeType == "file" && path.Ext(name) != name {
+		ext = path.Ext(name)
+	}
+	base := strings.TrimSuffix(name, ext)
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if !taken[repository.NameKey(candidate)] {
+			return candidate
+		}
+	}
+}
+
+// placeUnique calls place with name and, as long as it fails with
+// repository.ErrNameTaken, with the next free "name (n)" of parentID. The
+// unique index decides, so concurrent callers never end up with the same
+// name. It returns the name that was placed.
+func placeUnique(fileRepo repository.FileRepository, ownerID, parentID, name, nodeType string, place func(name string) error) (string, error) {
+	var taken map[string]bool
+	candidate := name
+	for attempt := 0; attempt < maxNameAttempts; attempt++ {
+		err := place(candidate)
+		if !errors.Is(err, repository.ErrNameTaken) {
+			return candidate, err
+		}
+		if taken == nil {
+			siblings, err := fileRepo.ListChildren(ownerID, parentID)
+			if err != nil {
+				return "", err
+			}
+			taken = map[string]bool{}
+			for _, s := range siblings {
+				taken[repository.NameKey(s.Name)] = true
+			}
+		}
+		// a node in the trash or one created since the listing
+		taken[repository.NameKey(candidate)] = true
+		candidate = UniqueName(name, nodeType, taken)
+	}
+	return "", repository.ErrNameTaken
+}
+
+// createUnique creates n, renamed to "name (n)" if its name is taken.
+func createUnique(fileRepo repository.FileRepository, n *models.Node) error {
+	_, err := placeUnique(fileRepo, n.OwnerID, n.ParentID, n.Name, n.Type, func(name string) error {
+		n.Name = name
+		return fileRepo.CreateNode(n)
+	})
+	return err
+}
+
+// ensureFolder returns the folder called name in parentID, creating it if
+// there is none. If a file has the name, the folder is created as
+// "name (n)". created reports whether the folder is new.
+func ensureFolder(fileRepo repository.FileRepository, ownerID, parentID, name string) (folder *models.Node, created bool, err error) {
+	for attempt := 0; attempt < maxNameAttempts; attempt++ {
+		existing, err := fileRepo.FindChildByName(ownerID, parentID, name)
+		if err != nil {
+			return nil, false, err
+		}
+		if existing != nil && existing.Type == "folder" {
+			return existing, false, nil
+		}
+		folder := &models.Node{OwnerID: ownerID, ParentID: parentID, Name: name, Type: "folder"}
+		if existing != nil {
+			err = createUnique(fileRepo, folder)
+		} else {
+			err = fileRepo.CreateNode(folder)
+		}
+		if err == nil {
+			return folder, true, nil
+		}
+		if !errors.Is(err, repository.ErrNameTaken) {
+			return nil, false, err
+		}
+		// created concurrently: look again
+	}
+	return nil, false, repository.ErrNameTaken
+}
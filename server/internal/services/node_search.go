@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+// ErrTooManyFolders is returned when searching in a folder that has more
+// than maxSearchFolders folders below it.
+var ErrTooManyFolders = errors.New("too many folders to search in")
+
+// maxSearchFolders bounds the folders, at any depth, a search in a folder
+// looks into.
+const maxSearchFolders = 10000
+
+// SearchResult is a node found by Search.
+type SearchResult struct {
+	Node *models.Node
+	// Path is the node's slash separated path from the root.
+	Path string
+}
+
+// Search returns the nodes matching q and, if there are more, the cursor of
+// the next page. With inFolder set, only the nodes below that folder of
+// q.OwnerID are searched, at any depth; ErrNodeNotFound is returned if it is
+// not such a folder and ErrTooManyFolders if it has too many folders below.
+func (s *NodeService) Search(q repository.SearchQuery, inFolder string) ([]SearchResult, string, error) {
+	if inFolder != "" {
+		folder, err := s.fileRepo.FindNodeByID(inFolder)
+		if err != nil {
+			return nil, "", err
+		}
+		if folder == nil || folder.OwnerID != q.OwnerID || folder.Type != "folder" {
+			return nil, "", ErrNodeNotFound
+		}
+		if q.ParentIDs, err = s.folderTree(folder); err != nil {
+			return nil, "", err
+		}
+	}
+
+	nodes, next, err := s.fileRepo.SearchNodes(q)
+	if err != nil {
+		return nil, "", err
+	}
+	// results tend to share their folders
+	cache := map[string]*models.Node{}
+	out := make([]SearchResult, 0, len(nodes))
+	for _, n := range nodes {
+		above, err := ancestors(s.fileRepo, n, cache)
+		if err != nil {
+			return nil, "", err
+		}
+		out = append(out, SearchResult{Node: n, Path: nodePath(above, n)})
+	}
+	return out, next, nil
+}
+
+// folderTree returns the IDs of folder and of every folder below it, and
+// ErrTooManyFolders if there are more than maxSearchFolders.
+func (s *NodeService) folderTree(folder *models.Node) ([]string, error) {
+	ids := []string{folder.ID}
+	seen := map[string]bool{folder.ID: true}
+	for i := 0; i < len(ids); {
+		batch := ids[i:min(i+folderBatch, len(ids))]
+		children, err := s.fileRepo.ListChildFolderIDs(folder.OwnerID, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range children {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) > maxSearchFolders {
+			return nil, fmt.Errorf("%w: the folder has more than %d folders below it", ErrTooManyFolders, maxSearchFolders)
+		}
+		i += len(batch)
+	}
+	return ids, nil
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+// What happens when a node is created or moved into a folder that already
+// holds a node of the same name, see NodeService.
+const (
+	ConflictFail    = "fail"
+	ConflictRename  = "rename"
+	ConflictReplace = "replace"
+	ConflictSkip    = "skip"
+)
+
+// ErrReplaceAncestor is returned when a move would replace a folder the
+// moved node is in.
+var ErrReplaceAncestor = errors.New("cannot replace a folder the node is in")
+
+// ParseConflict validates a conflict parameter, returning def when it is
+// empty.
+func ParseConflict(v, def string) (string, error) {
+	switch v {
+	case "":
+		return def, nil
+	case ConflictFail, ConflictRename, ConflictReplace, ConflictSkip:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid conflict %q (want one of fail, rename, replace, skip)", v)
+}
+
+// NodeService places nodes into folders while keeping sibling names unique.
+// A name clash is detected by the unique index on the name key, never by
+// looking first, so concurrent requests cannot both take a name. The
+// conflict strategies resolve it:
+//   - ConflictFail returns repository.ErrNameTaken.
+//   - ConflictRename takes the next free "name (n)".
+//   - ConflictReplace moves the sibling to the trash. It has to be of the
+//     same type, a file does not replace a folder or the other way round.
+//   - ConflictSkip leaves everything as it is and returns the sibling.
+type NodeService struct {
+	fileRepo repository.FileRepository
+	storage  *StorageService
+	trash    *TrashService
+	versions *VersionService
+	content  *ContentIndexService
+	recent   *RecentService
+	thumbs   *ThumbnailService
+}
+
+func NewNodeService(fileRepo repository.FileRepository, storage *StorageService, trash *TrashService) *NodeService {
+	return &NodeService{fileRepo: fileRepo, storage: storage, trash: trash}
+}
+
+// EnableVersioning makes uploads that replace a file keep its content as a
+// version for owners who keep versions, instead of trashing the file.
+func (s *NodeService) EnableVersioning(versions *VersionService) {
+	s.versions = versions
+}
+
+// EnableContentIndex has the text of new files indexed for content search.
+func (s *NodeService) EnableContentIndex(content *ContentIndexService) {
+	s.content = content
+}
+
+// EnableRecent puts uploaded files on their owner's recent list.
+func (s *NodeService) EnableRecent(recent *RecentService) {
+	s.recent = recent
+}
+
+// EnableThumbnails has thumbnails made of new images.
+func (s *NodeService) EnableThumbnails(thumbs *ThumbnailService) {
+	s.thumbs = thumbs
+}
+
+// withRepo returns a copy of s working on fileRepo and trash, e.g. bound to
+// a transaction.
+func (s *NodeService) withRepo(fileRepo repository.FileRepository, trash *TrashService) *NodeService {
+	c := *s
+	c.fileRepo = fileRepo
+	c.trash = trash
+	return &c
+}
+
+// Create inserts n into its parent folder. It returns n, possibly renamed,
+// and created true, or the sibling and created false when it was skipped.
+func (s *NodeService) Create(n *models.Node, conflict string) (node *models.Node, created bool, err error) {
+	return s.place(n, n.ParentID, conflict, func(name string) error {
+		n.Name = name
+		return s.fileRepo.CreateNode(n)
+	})
+}
+
+// CreateFile creates the file node n for an uploaded blob charged as a file,
+// see StorageService.IngestFile. With ConflictReplace, or no conflict given,
+// a file of the same name whose owner keeps versions gets the blob as its
+// new content. No conflict otherwise means ConflictRename. created is false
+// when an existing file was returned; the blob is released unless it is in
+// use.
+func (s *NodeService) CreateFile(n *models.Node, blob *models.Blob, conflict string) (node *models.Node, created bool, err error) {
+	if (conflict == "" || conflict == ConflictReplace) && s.versions != nil {
+		target, err := s.versions.UploadTarget(n.OwnerID, n.ParentID, n.Name)
+		if err != nil {
+			_ = s.storage.DiscardIngested(n.OwnerID, blob)
+			return nil, false, err
+		}
+		if target != nil {
+			node, err := s.versions.ReplaceContent(target, blob, n.Mime, true)
+			return node, false, err
+		}
+	}
+	if conflict == "" {
+		conflict = ConflictRename
+	}
+	node, created, err = s.Create(n, conflict)
+	if err != nil || !created {
+		_ = s.storage.ReleaseFile(n)
+	}
+	if created && s.content != nil {
+		s.content.Queue(node)
+	}
+	if created && s.thumbs != nil {
+		s.thumbs.Queue(node)
+	}
+	if created && s.recent != nil {
+		s.recent.Touch(node.OwnerID, node.ID, node.Type, RecentUpload)
+	}
+	return node, created, err
+}
+
+// Move moves n into parentID, keeping its name unless it is renamed by
+// ConflictRename. moved is false when it was skipped and n stays where it
+// is.
+func (s *NodeService) Move(n *models.Node, parentID, conflict string) (moved bool, err error) {
+	_, moved, err = s.place(n, parentID, conflict, func(name string) error {
+		if err := s.fileRepo.MoveNode(n.OwnerID, n.ID, parentID, name); err != nil {
+			return err
+		}
+		n.ParentID = parentID
+		n.Name = name
+		n.UpdatedAt = time.Now()
+		return nil
+	})
+	return moved, err
+}
+
+// place runs put with the name of n in parentID and resolves a clash.
+func (s *NodeService) place(n *models.Node, parentID, conflict string, put func(name string) error) (*models.Node, bool, error) {
+	for attempt := 0; attempt < maxNameAttempts; attempt++ {
+		err := put(n.Name)
+		if err == nil {
+			return n, true, nil
+		}
+		if !errors.Is(err, repository.ErrNameTaken) {
+			return nil, false, err
+		}
+		switch conflict {
+		case ConflictRename:
+			if _, err := placeUnique(s.fileRepo, n.OwnerID, parentID, n.Name, n.Type, put); err != nil {
+				return nil, false, err
+			}
+			return n, true, nil
+		case ConflictSkip, ConflictReplace:
+			existing, err := s.fileRepo.FindChildByName(n.OwnerID, parentID, n.Name)
+			if err != nil {
+				return nil, false, err
+			}
+			if existing == nil {
+				// gone in the meantime
+				continue
+			}
+			if conflict == ConflictSkip {
+				return existing, false, nil
+			}
+			if existing.Type != n.Type {
+				return nil, false, repository.ErrNameTaken
+			}
+			if err := s.checkNotAncestor(existing, n); err != nil {
+				return nil, false, err
+			}
+			if err := s.trash.Trash(existing); err != nil {
+				return nil, false, err
+			}
+		default:
+			return nil, false, repository.ErrNameTaken
+		}
+	}
+	return nil, false, repository.ErrNameTaken
+}
+
+// checkNotAncestor makes sure replacing folder does not take n with it.
+func (s *NodeService) checkNotAncestor(folder, n *models.Node) error {
+	if folder.Type != "folder" || n.ID == "" {
+		return nil
+	}
+	for pid, depth := n.ParentID, 0; pid != "" && depth < 1000; depth++ {
+		if pid == folder.ID {
+			return ErrReplaceAncestor
+		}
+		p, err := s.fileRepo.FindNodeByID(pid)
+		if err != nil {
+			return err
+		}
+		if p == nil {
+			break
+		}
+		pid = p.ParentID
+	}
+	return nil
+}
+
+// EnsureFolder returns the folder called name in parentID, creating it if
+// needed, see ensureFolder.
+func (s *NodeService) EnsureFolder(ownerID, parentID, name string) (folder *models.Node, created bool, err error) {
+	return ensureFolder(s.fileRepo, ownerID, parentID, name)
+}
+
+// IndexNames gives the nodes stored before sibling names were unique their
+// name key. Where several siblings share a name all but the first are
+// renamed to "name (n)". Nodes in the trash get their key when restored.
+// Nodes stored before search get their name n-grams.
+func (s *NodeService) IndexNames() (indexed, renamed int, err error) {
+	var pending, ungrammed []*models.Node
+	err = s.fileRepo.WalkNodes(func(n *models.Node) error {
+		switch {
+		case n.NameKey == "" && (n.TrashedAt == nil || n.TrashedWith != ""):
+			pending = append(pending, n)
+		case n.NameGrams == nil && len(repository.NameGrams(n.Name)) > 0:
+			ungrammed = append(ungrammed, n)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, n := range ungrammed {
+		if err := s.fileRepo.IndexNameGrams(n); err != nil {
+			return indexed, renamed, fmt.Errorf("node %s: %w", n.ID, err)
+		}
+		indexed++
+	}
+	for _, n := range pending {
+		original := n.Name
+		name, err := placeUnique(s.fileRepo, n.OwnerID, n.ParentID, n.Name, n.Type, func(name string) error {
+			return s.fileRepo.IndexName(n, name)
+		})
+		if err != nil {
+			return indexed, renamed, fmt.Errorf("node %s: %w", n.ID, err)
+		}
+		indexed++
+		if name != original {
+			renamed++
+		}
+	}
+	return indexed, renamed, nil
+}
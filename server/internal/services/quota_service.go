@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// QuotaService keeps per-user usage counters and enforces byte and file
+// count limits. Users without their own limits get the defaults; zero
+// means unlimited.
+type QuotaService struct {
+	DefaultBytes int64
+	DefaultFiles int64
+
+	repo     repository.QuotaRepository
+	fileRepo repository.FileRepository
+}
+
+func NewQuotaService(repo repository.QuotaRepository, fileRepo repository.FileRepository, defaultBytes, defaultFiles int64) *QuotaService {
+	return &QuotaService{DefaultBytes: defaultBytes, DefaultFiles: defaultFiles, repo: repo, fileRepo: fileRepo}
+}
+
+func effectiveLimit(own, def int64) int64 {
+	switch {
+	case own > 0:
+		return own
+	case own < 0:
+		return 0
+	default:
+		return def
+	}
+}
+
+// Usage returns the owner's usage with the limits that apply to them
+// resolved; a zero limit is unlimited.
+func (q *QuotaService) Usage(ownerID string) (*models.Quota, error) {
+	quota, err := q.repo.FindQuota(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if quota == nil {
+		quota = &models.Quota{OwnerID: ownerID}
+	}
+	quota.MaxBytes = effectiveLimit(quota.MaxBytes, q.DefaultBytes)
+	quota.MaxFiles = effectiveLimit(quota.MaxFiles, q.DefaultFiles)
+	return quota, nil
+}
+
+// Check reports ErrQuotaExceeded if adding bytes in files more files would
+// go over the owner's limits. It reserves nothing, see Charge.
+func (q *QuotaService) Check(ownerID string, bytes, files int64) error {
+	u, err := q.Usage(ownerID)
+	if err != nil {
+		return err
+	}
+	if u.MaxBytes > 0 && u.UsedBytes+bytes > u.MaxBytes {
+		return fmt.Errorf("%w (%d of %d bytes used)", ErrQuotaExceeded, u.UsedBytes, u.MaxBytes)
+	}
+	if u.MaxFiles > 0 && u.UsedFiles+files > u.MaxFiles {
+		return fmt.Errorf("%w (%d of %d files used)", ErrQuotaExceeded, u.UsedFiles, u.MaxFiles)
+	}
+	return nil
+}
+
+// Remaining returns how many bytes may be added along with files more
+// files, or -1 without a byte limit.
+func (q *QuotaService) Remaining(ownerID string, files int64) (int64, error) {
+	if err := q.Check(ownerID, 0, files); err != nil {
+		return 0, err
+	}
+	u, err := q.Usage(ownerID)
+	if err != nil {
+		return 0, err
+	}
+	if u.MaxBytes <= 0 {
+		return -1, nil
+	}
+	return max(u.MaxBytes-u.UsedBytes, 0), nil
+}
+
+// Charge atomically adds to the owner's usage, failing with
+// ErrQuotaExceeded instead of going over a limit.
+func (q *QuotaService) Charge(ownerID string, bytes, files int64) error {
+	u, err := q.Usage(ownerID)
+	if err != nil {
+		return err
+	}
+	ok, err := q.repo.ChargeQuota(ownerID, bytes, files, u.MaxBytes, u.MaxFiles)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+func (q *QuotaService) Uncharge(ownerID string, bytes, files int64) error {
+	return q.repo.AddUsage(ownerID, -bytes, -files)
+}
+
+// SetLimits sets the owner's own limits; zero restores the defaults and a
+// negative value lifts the limit.
+func (q *QuotaService) SetLimits(ownerID string, maxBytes, maxFiles int64) error {
+	return q.repo.SetQuotaLimits(ownerID, maxBytes, maxFiles)
+}
+
+// Recalculate rebuilds every usage counter from the nodes collection.
+// Uploads finishing while it runs may be counted twice or not at all, so
+// run it while the server is quiet.
+func (q *QuotaService) Recalculate() (int, error) {
+	type usage struct{ bytes, files int64 }
+	totals := map[string]*usage{}
+	if err := q.fileRepo.WalkNodes(func(n *models.Node) error {
+		if n.Type != "file" {
+			return nil
+		}
+		u := totals[n.OwnerID]
+		if u == nil {
+			u = &usage{}
+			totals[n.OwnerID] = u
+		}
+		u.bytes += n.Size
+		u.files++
+		for _, v := range n.Versions {
+			u.bytes += v.Size
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	existing, err := q.repo.ListQuotas()
+	if err != nil {
+		return 0, err
+	}
+	for _, quota := range existing {
+		if totals[quota.OwnerID] == nil {
+			totals[quota.OwnerID] = &usage{}
+		}
+	}
+	for ownerID, u := range totals {
+		if err := q.repo.SetUsage(ownerID, u.bytes, u.files); err != nil {
+			return 0, err
+		}
+	}
+	return len(totals), nil
+}
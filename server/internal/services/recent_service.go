@@ -0,0 +1,93 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+// What a user did with a node, see RecentService.Touch.
+const (
+	RecentUpload   = "upload"
+	RecentDownload = "download"
+	RecentPreview  = "preview"
+	RecentOpen     = "open" // a folder
+)
+
+// RecentService remembers which nodes each user touched last. Touching a
+// node again moves it to the top of the list instead of adding it twice.
+type RecentService struct {
+	recentRepo repository.RecentRepository
+	fileRepo   repository.FileRepository
+}
+
+func NewRecentService(recentRepo repository.RecentRepository, fileRepo repository.FileRepository) *RecentService {
+	return &RecentService{recentRepo: recentRepo, fileRepo: fileRepo}
+}
+
+// Touch records that the owner did action with the node. It is best
+// effort: an error is logged, never returned, as it must not fail what the
+// user did.
+func (s *RecentService) Touch(ownerID, nodeID, nodeType, action string) {
+	err := s.recentRepo.TouchRecent(&models.RecentEntry{
+		OwnerID:    ownerID,
+		NodeID:     nodeID,
+		Type:       nodeType,
+		Action:     action,
+		AccessedAt: time.Now(),
+	})
+	if err != nil {
+		log.Printf("recent: failed to record %s of %s: %v", action, nodeID, err)
+	}
+}
+
+// RecentItem is a node in the recent list.
+type RecentItem struct {
+	Node *models.Node
+	// Path is the node's slash separated path from the root.
+	Path       string
+	Action     string
+	AccessedAt time.Time
+}
+
+// List returns a page of up to limit of the nodes the owner touched last,
+// only those of nodeType if it is not empty, and the cursor of the next page
+// if there are more. Nodes that were deleted since are left out, so a page
+// may be shorter.
+func (s *RecentService) List(ownerID, nodeType, cursor string, limit int) ([]RecentItem, string, error) {
+	entries, next, err := s.recentRepo.ListRecent(ownerID, nodeType, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.NodeID
+	}
+	nodes, err := s.fileRepo.FindNodesByIDs(ids)
+	if err != nil {
+		return nil, "", err
+	}
+	byID := make(map[string]*models.Node, len(nodes))
+	for _, n := range nodes {
+		if n.OwnerID == ownerID {
+			byID[n.ID] = n
+		}
+	}
+
+	cache := map[string]*models.Node{}
+	out := make([]RecentItem, 0, len(entries))
+	for _, e := range entries {
+		n := byID[e.NodeID]
+		if n == nil {
+			continue
+		}
+		above, err := ancestors(s.fileRepo, n, cache)
+		if err != nil {
+			return nil, "", err
+		}
+		out = append(out, RecentItem{Node: n, Path: nodePath(above, n), Action: e.Action, AccessedAt: e.AccessedAt})
+	}
+	return out, next, nil
+}
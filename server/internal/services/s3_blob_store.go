@@ -0,0 +1,536 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3PartSize        = 16 << 20
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// s3MaxCopySize is the largest object CopyObject copies in one request.
+	s3MaxCopySize = 5 << 30
+	// s3CopyPartSize is the smallest part larger objects are copied in.
+	s3CopyPartSize = 512 << 20
+	s3MaxParts     = 10000
+)
+
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.ap-northeast-1.amazonaws.com or http://minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+	Prefix    string
+}
+
+// S3BlobStore talks to any S3 compatible object store (AWS S3, MinIO, ...)
+// using plain HTTP requests signed with AWS Signature Version 4.
+type S3BlobStore struct {
+	cfg    S3Config
+	base   *url.URL
+	client *http.Client
+}
+
+func NewS3BlobStore(cfg S3Config) (*S3BlobStore, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket not set")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	base, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	if base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %s", cfg.Endpoint)
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	return &S3BlobStore{cfg: cfg, base: base, client: &http.Client{}}, nil
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (s *S3BlobStore) objectKey(key string) (string, error) {
+	key = strings.TrimLeft(key, "/")
+	if key == "" {
+		return "", fmt.Errorf("empty blob key")
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == ".." || seg == "." {
+			return "", fmt.Errorf("invalid blob key")
+		}
+	}
+	if s.cfg.Prefix != "" {
+		key = s.cfg.Prefix + "/" + key
+	}
+	return key, nil
+}
+
+func (s *S3BlobStore) objectURL(objectKey string, query url.Values) *url.URL {
+	u := *s.base
+	var p string
+	if s.cfg.PathStyle {
+		p = "/" + s.cfg.Bucket + "/" + objectKey
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		p = "/" + objectKey
+	}
+	if objectKey == "" {
+		p = strings.TrimRight(p, "/")
+	}
+	u.Path = strings.TrimRight(s.base.Path, "/") + p
+	u.RawPath = s3Escape(u.Path, false)
+	u.RawQuery = s3CanonicalQuery(query)
+	return &u
+}
+
+func (s *S3BlobStore) do(method string, u *url.URL, header http.Header, body io.Reader, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	s.sign(req, payloadHash, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrBlobNotFound
+		}
+		var e s3Error
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if xml.Unmarshal(raw, &e) == nil && e.Code != "" {
+			if e.Code == "NoSuchKey" {
+				return nil, ErrBlobNotFound
+			}
+			return nil, fmt.Errorf("s3 %s %s: %s: %s", method, u.Path, e.Code, e.Message)
+		}
+		return nil, fmt.Errorf("s3 %s %s: unexpected status %d", method, u.Path, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (s *S3BlobStore) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	for k := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-md5" || lk == "content-type" || (strings.HasPrefix(lk, "x-amz-") && lk != "x-amz-content-sha256" && lk != "x-amz-date") {
+			signed = append(signed, lk)
+		}
+	}
+	sort.Strings(signed)
+
+	var canonHeaders strings.Builder
+	for _, h := range signed {
+		v := req.Host
+		if h != "host" {
+			v = strings.TrimSpace(req.Header.Get(h))
+		}
+		canonHeaders.WriteString(h + ":" + v + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+
+	canonRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.cfg.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func (s *S3BlobStore) Put(key string, r io.Reader) (int64, error) {
+	objKey, err := s.objectKey(key)
+	if err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, s3PartSize)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, err
+	}
+	if n < s3PartSize {
+		resp, err := s.do(http.MethodPut, s.objectURL(objKey, nil), nil, bytes.NewReader(buf[:n]), s3UnsignedPayload)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return int64(n), nil
+	}
+	return s.putMultipart(objKey, buf, r)
+}
+
+func (s *S3BlobStore) putMultipart(objKey string, first []byte, r io.Reader) (int64, error) {
+	uploadID, err := s.initMultipart(objKey)
+	if err != nil {
+		return 0, err
+	}
+
+	var parts []s3Part
+	var total int64
+	buf := first
+	n := len(first)
+	for partNumber := 1; n > 0; partNumber++ {
+		q := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {uploadID}}
+		resp, err := s.do(http.MethodPut, s.objectURL(objKey, q), nil, bytes.NewReader(buf[:n]), s3UnsignedPayload)
+		if err != nil {
+			s.abortMultipart(objKey, uploadID)
+			return 0, err
+		}
+		resp.Body.Close()
+		parts = append(parts, s3Part{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+		total += int64(n)
+
+		n, err = io.ReadFull(r, buf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			s.abortMultipart(objKey, uploadID)
+			return 0, err
+		}
+	}
+
+	if err := s.completeMultipart(objKey, uploadID, parts); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+type s3Part struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (s *S3BlobStore) initMultipart(objKey string) (string, error) {
+	resp, err := s.do(http.MethodPost, s.objectURL(objKey, url.Values{"uploads": {""}}), nil, bytes.NewReader(nil), sha256Hex(nil))
+	if err != nil {
+		return "", err
+	}
+	var initRes struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initRes)
+	resp.Body.Close()
+	if err != nil {
+		return "", fmt.Errorf("s3 multipart init: %w", err)
+	}
+	return initRes.UploadID, nil
+}
+
+func (s *S3BlobStore) abortMultipart(objKey, uploadID string) {
+	if resp, err := s.do(http.MethodDelete, s.objectURL(objKey, url.Values{"uploadId": {uploadID}}), nil, nil, sha256Hex(nil)); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// completeMultipart joins the parts, aborting the upload if it fails.
+func (s *S3BlobStore) completeMultipart(objKey, uploadID string, parts []s3Part) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		s.abortMultipart(objKey, uploadID)
+		return err
+	}
+	resp, err := s.do(http.MethodPost, s.objectURL(objKey, url.Values{"uploadId": {uploadID}}), nil, bytes.NewReader(body), sha256Hex(body))
+	if err != nil {
+		s.abortMultipart(objKey, uploadID)
+		return err
+	}
+	// CompleteMultipartUpload can report failure with a 200 status
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	var e s3Error
+	if xml.Unmarshal(raw, &e) == nil && e.Code != "" {
+		s.abortMultipart(objKey, uploadID)
+		return fmt.Errorf("s3 multipart complete: %s: %s", e.Code, e.Message)
+	}
+	return nil
+}
+
+func (s *S3BlobStore) Get(key string) (io.ReadCloser, error) {
+	return s.GetRange(key, 0, -1)
+}
+
+func (s *S3BlobStore) GetRange(key string, offset, length int64) (io.ReadCloser, error) {
+	objKey, err := s.objectKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	header := http.Header{}
+	if length > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := s.do(http.MethodGet, s.objectURL(objKey, nil), header, nil, sha256Hex(nil))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3BlobStore) Stat(key string) (*BlobInfo, error) {
+	objKey, err := s.objectKey(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(http.MethodHead, s.objectURL(objKey, nil), nil, nil, sha256Hex(nil))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	info := &BlobInfo{Key: key, Size: resp.ContentLength}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = t
+	}
+	return info, nil
+}
+
+func (s *S3BlobStore) Delete(key string) error {
+	objKey, err := s.objectKey(key)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodDelete, s.objectURL(objKey, nil), nil, nil, sha256Hex(nil))
+	if err != nil {
+		if err == ErrBlobNotFound {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3BlobStore) Move(src, dst string) error {
+	srcKey, err := s.objectKey(src)
+	if err != nil {
+		return err
+	}
+	dstKey, err := s.objectKey(dst)
+	if err != nil {
+		return err
+	}
+	info, err := s.Stat(src)
+	if err != nil {
+		return err
+	}
+	copySource := s3Escape("/"+s.cfg.Bucket+"/"+srcKey, false)
+	if info.Size > s3MaxCopySize {
+		err = s.copyMultipart(copySource, dstKey, info.Size)
+	} else {
+		err = s.copyObject(copySource, dstKey)
+	}
+	if err != nil {
+		return fmt.Errorf("s3 copy %s: %w", src, err)
+	}
+	return s.Delete(src)
+}
+
+func (s *S3BlobStore) copyObject(copySource, dstKey string) error {
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", copySource)
+	resp, err := s.do(http.MethodPut, s.objectURL(dstKey, nil), header, nil, sha256Hex(nil))
+	if err != nil {
+		return err
+	}
+	// CopyObject can report failure with a 200 status
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	var e s3Error
+	if xml.Unmarshal(raw, &e) == nil && e.Code != "" {
+		return fmt.Errorf("%s: %s", e.Code, e.Message)
+	}
+	return nil
+}
+
+// copyMultipart copies objects larger than CopyObject allows with
+// UploadPartCopy, in parts of at least s3CopyPartSize.
+func (s *S3BlobStore) copyMultipart(copySource, dstKey string, size int64) error {
+	partSize := int64(s3CopyPartSize)
+	if n := (size + s3MaxParts - 1) / s3MaxParts; n > partSize {
+		partSize = n
+	}
+	uploadID, err := s.initMultipart(dstKey)
+	if err != nil {
+		return err
+	}
+	var parts []s3Part
+	for offset, partNumber := int64(0), 1; offset < size; offset, partNumber = offset+partSize, partNumber+1 {
+		end := offset + partSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		header := http.Header{}
+		header.Set("X-Amz-Copy-Source", copySource)
+		header.Set("X-Amz-Copy-Source-Range", fmt.Sprintf("bytes=%d-%d", offset, end))
+		q := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {uploadID}}
+		resp, err := s.do(http.MethodPut, s.objectURL(dstKey, q), header, nil, sha256Hex(nil))
+		if err != nil {
+			s.abortMultipart(dstKey, uploadID)
+			return err
+		}
+		// the ETag of the part is in the body, which can also hold an
+		// error with a 200 status
+		raw, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		var res struct {
+			ETag string `xml:"ETag"`
+			s3Error
+		}
+		if err := xml.Unmarshal(raw, &res); err != nil || res.Code != "" || res.ETag == "" {
+			s.abortMultipart(dstKey, uploadID)
+			if res.Code != "" {
+				return fmt.Errorf("%s: %s", res.Code, res.Message)
+			}
+			return fmt.Errorf("upload part copy: unexpected response")
+		}
+		parts = append(parts, s3Part{PartNumber: partNumber, ETag: res.ETag})
+	}
+	return s.completeMultipart(dstKey, uploadID, parts)
+}
+
+func (s *S3BlobStore) List(prefix string, fn func(info BlobInfo) error) error {
+	fullPrefix := prefix
+	if s.cfg.Prefix != "" {
+		fullPrefix = s.cfg.Prefix + "/" + prefix
+	}
+	var token string
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {fullPrefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.do(http.MethodGet, s.objectURL("", q), nil, nil, sha256Hex(nil))
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("s3 list: %w", err)
+		}
+		for _, obj := range page.Contents {
+			key := obj.Key
+			if s.cfg.Prefix != "" {
+				key = strings.TrimPrefix(key, s.cfg.Prefix+"/")
+			}
+			if err := fn(BlobInfo{Key: key, Size: obj.Size, ModTime: obj.LastModified}); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// s3Escape applies the URI encoding required by SigV4 (RFC 3986 unreserved
+// characters are kept, everything else is percent-encoded).
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3CanonicalQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+// ScrubService re-reads stored blobs and compares them with the checksums
+// taken on upload. Mismatching blobs and the file nodes stored in them are
+// flagged as corrupt; blobs that verify again are unflagged.
+type ScrubService struct {
+	blobRepo repository.BlobRepository
+	fileRepo repository.FileRepository
+	storage  *StorageService
+}
+
+func NewScrubService(blobRepo repository.BlobRepository, fileRepo repository.FileRepository, storage *StorageService) *ScrubService {
+	return &ScrubService{blobRepo: blobRepo, fileRepo: fileRepo, storage: storage}
+}
+
+type ScrubResult struct {
+	Checked int
+	Corrupt []string // blob ids
+	Failed  int      // could not be read, see log
+}
+
+// Run verifies every blob not verified since the given time.
+func (s *ScrubService) Run(verifiedBefore time.Time) (*ScrubResult, error) {
+	// collect first: hashing takes long enough for a cursor to time out
+	var due []*models.Blob
+	if err := s.blobRepo.WalkBlobs(func(b *models.Blob) error {
+		if b.DeletingAt == nil && b.VerifiedAt.Before(verifiedBefore) {
+			due = append(due, b)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	res := &ScrubResult{}
+	for _, b := range due {
+		corrupt, err := s.verify(b)
+		if err != nil {
+			log.Printf("scrub: failed to verify blob %s: %v", b.ID, err)
+			res.Failed++
+			continue
+		}
+		res.Checked++
+		if corrupt {
+			res.Corrupt = append(res.Corrupt, b.ID)
+		}
+		if err := s.blobRepo.MarkBlobVerified(b.ID, corrupt); err != nil {
+			return res, err
+		}
+		if corrupt != b.Corrupt {
+			if err := s.fileRepo.SetCorruptByBlob(b.ID, corrupt); err != nil {
+				return res, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// MarkRepaired unflags a blob whose content was stored again, and the file
+// nodes stored in it.
+func (s *ScrubService) MarkRepaired(blobID string) error {
+	if err := s.blobRepo.MarkBlobVerified(blobID, false); err != nil {
+		return err
+	}
+	return s.fileRepo.SetCorruptByBlob(blobID, false)
+}
+
+// verify hashes the plaintext of b. Errors are only returned when the
+// content could not be read at all; damaged ciphertext counts as corrupt.
+func (s *ScrubService) verify(b *models.Blob) (bool, error) {
+	r, err := s.storage.openBlob(b)
+	if err != nil {
+		if errors.Is(err, errCorruptCiphertext) {
+			return true, nil
+		}
+		return false, err
+	}
+	defer r.Close()
+
+	sum := newChecksummer()
+	n, err := io.Copy(sum, r)
+	if err != nil {
+		if errors.Is(err, errCorruptCiphertext) {
+			return true, nil
+		}
+		return false, err
+	}
+	want := &models.Checksums{SHA256: b.Digest, MD5: b.MD5, CRC32C: b.CRC32C}
+	return n != b.Size || verifyChecksums(want, sum.checksums()) != nil, nil
+}
+
+// StartPeriodic verifies every blob once per interval.
+func (s *ScrubService) StartPeriodic(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			res, err := s.Run(time.Now().Add(-interval))
+			if err != nil {
+				log.Printf("scrub: %v", err)
+				continue
+			}
+			for _, id := range res.Corrupt {
+				log.Printf("scrub: blob %s is corrupt", id)
+			}
+			log.Printf("scrub: verified %d blobs, %d corrupt, %d unreadable", res.Checked, len(res.Corrupt), res.Failed)
+		}
+	}()
+}
@@ -4,149 +4,724 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
+	"log"
 	"os"
 	"path"
-	"path/filepath"
 	"strings"
 	"time"
+
+	"server/internal/models"
+	"server/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var ErrTooLarge = errors.New("file too large")
+
+const (
+	// blobDeletionWait is how long Ingest waits for the content it stores
+	// to be deleted by a concurrent Release.
+	blobDeletionWait = 30 * time.Second
+	// staleBlobDeletion is how long a blob stays marked for deletion
+	// before Ingest takes over from a Release that failed.
+	staleBlobDeletion = 5 * time.Minute
 )
 
 type StorageService struct {
-	BasePath string
-	MaxSize  int64
+	Blobs   BlobStore
+	MaxSize int64
+	// TempDir holds request scoped scratch files such as uploaded archives;
+	// empty means the system default.
+	TempDir  string
+	blobRepo repository.BlobRepository
+	keys     *KeyService
+	quotas   *QuotaService
+	scrub    *ScrubService
+}
+
+func NewStorageService(blobs BlobStore, blobRepo repository.BlobRepository, maxSize int64) *StorageService {
+	return &StorageService{Blobs: blobs, MaxSize: maxSize, blobRepo: blobRepo}
+}
+
+// EnableEncryption makes Ingest encrypt new blobs with the owner's data key.
+// Blobs already stored in plaintext stay readable.
+func (s *StorageService) EnableEncryption(keys *KeyService) {
+	s.keys = keys
+}
+
+// EnableQuotas makes IngestFile and ReleaseFile maintain the owners' usage.
+func (s *StorageService) EnableQuotas(quotas *QuotaService) {
+	s.quotas = quotas
 }
 
-func NewStorageService(basePath string, maxSize int64) *StorageService {
-	return &StorageService{BasePath: basePath, MaxSize: maxSize}
+// EnableScrub makes Ingest unflag a corrupt blob, and the files stored in
+// it, once an upload of the same content has replaced it.
+func (s *StorageService) EnableScrub(scrub *ScrubService) {
+	s.scrub = scrub
 }
 
-func sanitizeFileName(name string) string {
-	name = filepath.Base(name)
-	name = strings.TrimSpace(name)
-	if name == "" {
-		name = "file"
+// CheckQuota reports ErrQuotaExceeded when the owner cannot store bytes more
+// in files more files. It is a cheap early check; IngestFile enforces the
+// quota.
+func (s *StorageService) CheckQuota(ownerID string, bytes, files int64) error {
+	if s.quotas == nil {
+		return nil
 	}
-	return name
+	return s.quotas.Check(ownerID, bytes, files)
 }
 
-func (s *StorageService) SaveFromReader(ownerID string, relPath string, r io.Reader) (string, int64, error) {
-	if s == nil {
-		return "", 0, fmt.Errorf("storage not configured")
+// IngestFile is IngestLimited for the content of a new file node. The upload
+// is cut off as soon as it would exceed the owner's quota and is charged to
+// it; ReleaseFile gives both the blob and the quota back.
+func (s *StorageService) IngestFile(ownerID string, r io.Reader, limit int64, want *models.Checksums) (*models.Blob, error) {
+	return s.ingestCharged(ownerID, r, limit, want, 1)
+}
+
+// IngestContent is IngestFile for new content of an existing file node: only
+// the bytes are charged.
+func (s *StorageService) IngestContent(ownerID string, r io.Reader, limit int64, want *models.Checksums) (*models.Blob, error) {
+	return s.ingestCharged(ownerID, r, limit, want, 0)
+}
+
+func (s *StorageService) ingestCharged(ownerID string, r io.Reader, limit int64, want *models.Checksums, files int64) (*models.Blob, error) {
+	if s.quotas == nil {
+		return s.IngestLimited(ownerID, r, limit, want)
 	}
-	if s.BasePath == "" {
-		return "", 0, fmt.Errorf("storage base path not set")
+	remaining, err := s.quotas.Remaining(ownerID, files)
+	if err != nil {
+		return nil, err
 	}
-	relPath = filepath.ToSlash(relPath)
-	relPath = strings.TrimLeft(relPath, "/")
-	relPath = path.Clean("/" + relPath)
-	relPath = strings.TrimLeft(relPath, "/")
-	if relPath == "" {
-		return "", 0, fmt.Errorf("empty relative path")
+	quotaBound := remaining >= 0 && (limit <= 0 || remaining < limit)
+	if quotaBound {
+		if remaining == 0 {
+			return nil, ErrQuotaExceeded
+		}
+		limit = remaining
 	}
-	for _, seg := range strings.Split(relPath, "/") {
-		if seg == ".." {
-			return "", 0, fmt.Errorf("invalid path segment")
+	blob, err := s.IngestLimited(ownerID, r, limit, want)
+	if err != nil {
+		if quotaBound && errors.Is(err, ErrTooLarge) {
+			return nil, ErrQuotaExceeded
 		}
+		return nil, err
+	}
+	if err := s.quotas.Charge(ownerID, blob.Size, files); err != nil {
+		_ = s.Release(blob.ID)
+		return nil, err
+	}
+	return blob, nil
+}
+
+// uncharge gives quota back without touching any content.
+func (s *StorageService) uncharge(ownerID string, bytes, files int64) error {
+	if s.quotas == nil {
+		return nil
 	}
+	return s.quotas.Uncharge(ownerID, bytes, files)
+}
 
-	destRel := filepath.FromSlash(relPath)
-	destPath := filepath.Join(s.BasePath, "owners", ownerID, destRel)
+// CopyFile returns the content of file node n for a new node of the same
+// owner, charged to the quota like IngestFile. Content addressed blobs get
+// another reference instead of being duplicated.
+func (s *StorageService) CopyFile(n *models.Node) (*models.Blob, error) {
+	id := nodeBlobID(n)
+	if id == "" {
+		src, err := s.Open(n.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer src.Close()
+		return s.IngestFile(n.OwnerID, src, 0, nil)
+	}
 
-	absBase, err := filepath.Abs(s.BasePath)
+	if err := s.CheckQuota(n.OwnerID, n.Size, 1); err != nil {
+		return nil, err
+	}
+	b, err := s.blobRepo.FindBlob(id)
 	if err != nil {
-		return "", 0, fmt.Errorf("internal error: %w", err)
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrBlobNotFound
+	}
+	created, err := s.blobRepo.AcquireBlob(b)
+	if errors.Is(err, repository.ErrBlobDeleting) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		// the last reference went away while copying
+		_ = s.Release(b.ID)
+		return nil, ErrBlobNotFound
+	}
+	if s.quotas != nil {
+		if err := s.quotas.Charge(n.OwnerID, n.Size, 1); err != nil {
+			_ = s.Release(b.ID)
+			return nil, err
+		}
 	}
-	absDest, err := filepath.Abs(destPath)
+	return b, nil
+}
+
+// DiscardIngested undoes IngestFile for content that did not become a node.
+func (s *StorageService) DiscardIngested(ownerID string, b *models.Blob) error {
+	return s.ReleaseFile(&models.Node{OwnerID: ownerID, Type: "file", Size: b.Size, BlobID: b.ID})
+}
+
+// Ingest stores the content of r by its SHA-256 digest. Identical content is
+// kept once and shared; every successful call adds one reference that must be
+// given back with Release. With encryption enabled content is only shared
+// between files of the same owner.
+func (s *StorageService) Ingest(ownerID string, r io.Reader) (*models.Blob, error) {
+	return s.IngestLimited(ownerID, r, s.MaxSize, nil)
+}
+
+// IngestLimited is Ingest with an explicit size limit instead of MaxSize; a
+// limit of zero or less means unlimited. Unless want is nil the content must
+// match every checksum set in it or ErrChecksumMismatch is returned.
+func (s *StorageService) IngestLimited(ownerID string, r io.Reader, limit int64, want *models.Checksums) (*models.Blob, error) {
+	if s == nil || s.Blobs == nil || s.blobRepo == nil {
+		return nil, fmt.Errorf("storage not configured")
+	}
+	id, err := uuid.NewRandom()
 	if err != nil {
-		return "", 0, fmt.Errorf("internal error: %w", err)
+		return nil, err
+	}
+	stagingKey := path.Join("tmp", id.String())
+
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
 	}
-	if absDest != absBase && !strings.HasPrefix(absDest, absBase+string(os.PathSeparator)) {
-		return "", 0, fmt.Errorf("invalid destination path")
+	sum := newChecksummer()
+	counted := &countingReader{r: io.TeeReader(r, sum)}
+	var src io.Reader = counted
+
+	var keyID string
+	if s.keys != nil {
+		var dataKey []byte
+		keyID, dataKey, err = s.keys.DataKeyFor(ownerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load data key: %w", err)
+		}
+		if src, err = newEncryptingReader(counted, dataKey); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := os.MkdirAll(filepath.Dir(absDest), 0o755); err != nil {
-		return "", 0, fmt.Errorf("failed to create directories: %w", err)
+	if _, err := s.Blobs.Put(stagingKey, src); err != nil {
+		_ = s.Blobs.Delete(stagingKey)
+		return nil, fmt.Errorf("failed to write blob: %w", err)
+	}
+	if limit > 0 && counted.n > limit {
+		_ = s.Blobs.Delete(stagingKey)
+		return nil, fmt.Errorf("%w (max %d bytes)", ErrTooLarge, limit)
+	}
+	got := sum.checksums()
+	if err := verifyChecksums(want, got); err != nil {
+		_ = s.Blobs.Delete(stagingKey)
+		return nil, err
 	}
+	digest := got.SHA256
 
-	tmp, err := os.CreateTemp("", "save-from-reader-*")
+	blob := &models.Blob{ID: digest, Digest: digest, MD5: got.MD5, CRC32C: got.CRC32C, Key: blobKey(digest), Size: counted.n}
+	if keyID != "" {
+		blob.ID = keyID + "." + digest
+		blob.Key = blobKey(digest) + "." + keyID
+		blob.KeyID = keyID
+	}
+	created, err := s.acquireBlob(blob)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to create temp file: %w", err)
+		_ = s.Blobs.Delete(stagingKey)
+		return nil, err
+	}
+	repaired := false
+	if !created {
+		// reuse the stored copy unless it is gone or the scrubber found it
+		// damaged, in which case the fresh upload replaces it
+		existing, err := s.blobRepo.FindBlob(blob.ID)
+		if err == nil && existing != nil && !existing.Corrupt {
+			if _, err := s.Blobs.Stat(blob.Key); err == nil {
+				_ = s.Blobs.Delete(stagingKey)
+				return blob, nil
+			}
+		}
+		repaired = err == nil && existing != nil && existing.Corrupt
 	}
-	tmpPath := tmp.Name()
-	defer func() {
-		tmp.Close()
-		_ = os.Remove(tmpPath)
-	}()
+	if err := s.Blobs.Move(stagingKey, blob.Key); err != nil {
+		_ = s.Blobs.Delete(stagingKey)
+		_ = s.Release(blob.ID)
+		return nil, err
+	}
+	if repaired && s.scrub != nil {
+		if err := s.scrub.MarkRepaired(blob.ID); err != nil {
+			log.Printf("storage: failed to unflag repaired blob %s: %v", blob.ID, err)
+		}
+	}
+	return blob, nil
+}
 
-	var reader io.Reader = r
-	if s.MaxSize > 0 {
-		reader = io.LimitReader(r, s.MaxSize+1)
+// acquireBlob is AcquireBlob that waits for a blob being deleted to be gone,
+// so that new content is never written where Release is about to delete it.
+// A blob left marked for longer than staleBlobDeletion by a Release that
+// failed is deleted here.
+func (s *StorageService) acquireBlob(b *models.Blob) (bool, error) {
+	deadline := time.Now().Add(blobDeletionWait)
+	for {
+		created, err := s.blobRepo.AcquireBlob(b)
+		if !errors.Is(err, repository.ErrBlobDeleting) {
+			return created, err
+		}
+		old, err := s.blobRepo.FindBlob(b.ID)
+		if err != nil {
+			return false, err
+		}
+		if old != nil && old.DeletingAt != nil && time.Since(*old.DeletingAt) > staleBlobDeletion {
+			if err := s.purgeBlob(old); err != nil {
+				return false, err
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return false, fmt.Errorf("blob %s is still being deleted", b.ID)
+		}
+		time.Sleep(50 * time.Millisecond)
 	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
 
-	n, err := io.Copy(tmp, reader)
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Release drops one reference to a blob and deletes the content once nothing
+// refers to it anymore.
+func (s *StorageService) Release(blobID string) error {
+	b, err := s.blobRepo.ReleaseBlob(blobID)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to write temp file: %w", err)
+		return err
 	}
-	if s.MaxSize > 0 && n > s.MaxSize {
-		return "", 0, fmt.Errorf("file too large (max %d bytes)", s.MaxSize)
+	if b == nil || b.RefCount > 0 {
+		return nil
 	}
-	if err := tmp.Close(); err != nil {
-		return "", 0, fmt.Errorf("failed to finalize temp file: %w", err)
+	// the record stays, marked, until the content is gone, so that an
+	// upload of the same content waits instead of writing an object that
+	// is about to be deleted
+	b, err = s.blobRepo.MarkBlobDeleting(blobID)
+	if err != nil || b == nil {
+		return err
 	}
+	return s.purgeBlob(b)
+}
 
-	if err := os.Rename(tmpPath, absDest); err != nil {
-		in, err2 := os.Open(tmpPath)
-		if err2 != nil {
-			return "", 0, fmt.Errorf("failed to move temp file: %w", err)
+// purgeBlob deletes the content of blob b, marked with MarkBlobDeleting, and
+// then its record. The record stays if the content could not be deleted.
+func (s *StorageService) purgeBlob(b *models.Blob) error {
+	err := s.Blobs.Delete(b.Key)
+	for size := range ThumbnailSizes {
+		if terr := s.Blobs.Delete(thumbnailKey(b, size)); err == nil {
+			err = terr
+		}
+	}
+	for _, name := range b.Renditions {
+		if rerr := s.Blobs.Delete(renditionKey(b, name)); err == nil {
+			err = rerr
 		}
-		defer in.Close()
-		out, err2 := os.Create(absDest)
-		if err2 != nil {
-			return "", 0, fmt.Errorf("failed to create destination file: %w", err2)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.blobRepo.DeleteBlobIfUnreferenced(b.ID)
+	return err
+}
+
+// ReleaseFile gives back the storage and quota held by a file node and its
+// versions. Nodes written before content addressing own their blob and are
+// deleted directly.
+func (s *StorageService) ReleaseFile(n *models.Node) error {
+	var err error
+	if id := nodeBlobID(n); id != "" {
+		err = s.Release(id)
+	} else if n.Path != "" {
+		err = s.DeleteFile(n.Path)
+	}
+	if qerr := s.uncharge(n.OwnerID, n.Size, 1); err == nil {
+		err = qerr
+	}
+	for _, v := range n.Versions {
+		if verr := s.ReleaseVersion(n.OwnerID, v); err == nil {
+			err = verr
 		}
-		defer out.Close()
-		if _, err2 = io.Copy(out, in); err2 != nil {
-			return "", 0, fmt.Errorf("failed to copy to destination: %w", err2)
+	}
+	return err
+}
+
+// UnchargeFile gives back the quota held by a file node and its versions
+// whose content is gone, leaving the blobs alone.
+func (s *StorageService) UnchargeFile(n *models.Node) error {
+	err := s.uncharge(n.OwnerID, n.Size, 1)
+	for _, v := range n.Versions {
+		if verr := s.uncharge(n.OwnerID, v.Size, 0); err == nil {
+			err = verr
 		}
-		_ = os.Remove(tmpPath)
 	}
+	return err
+}
+
+// ReleaseVersion gives back the storage and quota held by a file version.
+func (s *StorageService) ReleaseVersion(ownerID string, v models.NodeVersion) error {
+	var err error
+	if v.BlobID != "" {
+		err = s.Release(v.BlobID)
+	} else if v.Path != "" {
+		err = s.DeleteFile(v.Path)
+	}
+	if qerr := s.uncharge(ownerID, v.Size, 0); err == nil {
+		err = qerr
+	}
+	return err
+}
 
-	return absDest, n, nil
+// ReleaseAvatar gives back the storage held by a user's avatar.
+func (s *StorageService) ReleaseAvatar(u *models.User) error {
+	if u.AvatarBlobID != "" {
+		return s.Release(u.AvatarBlobID)
+	}
+	if u.AvatarURL == "" {
+		return nil
+	}
+	return s.DeleteFile(u.AvatarURL)
 }
 
-func (s *StorageService) SaveFile(ownerID string, fileHeader *multipart.FileHeader) (string, int64, error) {
-	if fileHeader.Size > s.MaxSize && s.MaxSize > 0 {
-		return "", 0, errors.New("file too large")
+// nodeBlobID returns the blob a file node refers to; nodes stored before
+// encryption existed use the plain digest as blob id.
+func nodeBlobID(n *models.Node) string {
+	if n.BlobID != "" {
+		return n.BlobID
 	}
-	src, err := fileHeader.Open()
+	return n.Digest
+}
+
+func blobKey(digest string) string {
+	return path.Join("blobs", digest[:2], digest[2:4], digest)
+}
+
+func (s *StorageService) Stat(key string) (*BlobInfo, error) {
+	return s.Blobs.Stat(key)
+}
+
+// Open returns a seekable reader over a plaintext blob, suitable for
+// http.ServeContent. Data is fetched lazily with range reads so a seek does
+// not download the whole object.
+func (s *StorageService) Open(key string) (*BlobReader, error) {
+	info, err := s.Blobs.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+	return &BlobReader{
+		info: info,
+		open: func(offset, length int64) (io.ReadCloser, error) {
+			return s.Blobs.GetRange(key, offset, length)
+		},
+	}, nil
+}
+
+// OpenFile is Open for the content of a file node and transparently
+// decrypts encrypted blobs.
+func (s *StorageService) OpenFile(n *models.Node) (*BlobReader, error) {
+	id := nodeBlobID(n)
+	if id == "" {
+		return s.Open(n.Path)
+	}
+	b, err := s.blobRepo.FindBlob(id)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrBlobNotFound
+	}
+	return s.openBlob(b)
+}
+
+// openBlob returns a reader over the plaintext of a blob.
+func (s *StorageService) openBlob(b *models.Blob) (*BlobReader, error) {
+	return s.openStored(b.Key, b.KeyID)
+}
+
+// openStored returns a reader over the plaintext of the object at key,
+// encrypted with the data key keyID unless it is empty.
+func (s *StorageService) openStored(key, keyID string) (*BlobReader, error) {
+	if keyID == "" {
+		return s.Open(key)
+	}
+	if s.keys == nil {
+		return nil, fmt.Errorf("blob is encrypted but encryption is not configured")
+	}
+	dataKey, err := s.keys.DataKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.Blobs.Stat(key)
 	if err != nil {
-		return "", 0, err
+		return nil, err
 	}
-	defer src.Close()
+	size, err := plainSize(info.Size)
+	if err != nil {
+		return nil, err
+	}
+	return &BlobReader{
+		info: &BlobInfo{Key: key, Size: size, ModTime: info.ModTime},
+		open: func(offset, length int64) (io.ReadCloser, error) {
+			return openDecryptedRange(s.Blobs, key, dataKey, size, offset, length)
+		},
+	}, nil
+}
+
+// thumbnailPrefix holds the thumbnails of blobs, see PutThumbnail.
+const thumbnailPrefix = "thumbs"
+
+// renditionPrefix holds the resized images of blobs, see PutRendition.
+const renditionPrefix = "renditions"
 
-	dir := filepath.Join(s.BasePath, ownerID)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", 0, err
+// thumbnailKey is where the thumbnail of the size of blob b is stored.
+func thumbnailKey(b *models.Blob, size string) string {
+	return derivedKey(thumbnailPrefix, b, size+".jpg")
+}
+
+// renditionKey is where the rendition name of blob b is stored.
+func renditionKey(b *models.Blob, name string) string {
+	return derivedKey(renditionPrefix, b, name)
+}
+
+// derivedKey is where a file made of blob b is stored below prefix. The
+// file is named by the blob ID, a dot and name, e.g. "<blob id>.small.jpg",
+// so the extension of name ends the key. name must have an extension and
+// no other dot: derivedBlobID drops the extension and then cuts the blob ID
+// off at the last dot, which a name without extension would not leave.
+func derivedKey(prefix string, b *models.Blob, name string) string {
+	return path.Join(prefix, b.Digest[:2], b.Digest[2:4], b.ID+"."+name)
+}
+
+// derivedBlobID returns the blob a thumbnail or rendition key belongs to.
+func derivedBlobID(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, thumbnailPrefix+"/")
+	if !ok {
+		if rest, ok = strings.CutPrefix(key, renditionPrefix+"/"); !ok {
+			return "", false
+		}
+	}
+	name := path.Base(rest)
+	name = strings.TrimSuffix(name, path.Ext(name))
+	i := strings.LastIndexByte(name, '.')
+	if i <= 0 {
+		return "", false
 	}
+	return name[:i], true
+}
 
-	safeName := sanitizeFileName(fileHeader.Filename)
-	ts := time.Now().UnixNano()
-	storedName := filepath.Join(dir, fmt.Sprintf("%d_%s", ts, safeName))
+// contentBlob returns the blob holding the content of file node n, nil for
+// nodes stored before content addressing.
+func (s *StorageService) contentBlob(n *models.Node) (*models.Blob, error) {
+	id := nodeBlobID(n)
+	if id == "" {
+		return nil, nil
+	}
+	b, err := s.blobRepo.FindBlob(id)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrBlobNotFound
+	}
+	return b, nil
+}
 
-	dst, err := os.Create(storedName)
+// PutThumbnail stores the JPEG thumbnail of the size of the content of file
+// node n next to the content, encrypted like it. Thumbnails are shared by
+// every file with the content and deleted with it, see Release.
+func (s *StorageService) PutThumbnail(n *models.Node, size string, r io.Reader) error {
+	b, err := s.contentBlob(n)
 	if err != nil {
-		return "", 0, err
+		return err
 	}
-	defer dst.Close()
+	if b == nil {
+		return ErrNoThumbnail
+	}
+	if err := s.putDerived(thumbnailKey(b, size), b.KeyID, r); err != nil {
+		return fmt.Errorf("failed to write thumbnail: %w", err)
+	}
+	return nil
+}
 
-	written, err := io.Copy(dst, src)
+// PutRendition stores the rendition name of the content of file node n like
+// a thumbnail. Only maxRenditions are kept of a content; it reports false,
+// storing nothing, if there are as many already.
+func (s *StorageService) PutRendition(n *models.Node, name string, r io.Reader) (bool, error) {
+	b, err := s.contentBlob(n)
 	if err != nil {
-		return "", 0, err
+		return false, err
 	}
-	return storedName, written, nil
+	if b == nil {
+		return false, ErrNotImage
+	}
+	// recorded first so that Release never misses it
+	ok, err := s.blobRepo.AddBlobRendition(b.ID, name, maxRenditions)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := s.putDerived(renditionKey(b, name), b.KeyID, r); err != nil {
+		return false, fmt.Errorf("failed to write rendition: %w", err)
+	}
+	return true, nil
 }
 
-func (s *StorageService) DeleteFile(path string) error {
-	return os.Remove(path)
+// putDerived writes a file made of a blob to key, encrypted with the data
+// key keyID unless it is empty. It is staged first so that readers never
+// see a partial file.
+func (s *StorageService) putDerived(key, keyID string, r io.Reader) error {
+	src := r
+	if keyID != "" {
+		if s.keys == nil {
+			return fmt.Errorf("blob is encrypted but encryption is not configured")
+		}
+		dataKey, err := s.keys.DataKey(keyID)
+		if err != nil {
+			return err
+		}
+		if src, err = newEncryptingReader(r, dataKey); err != nil {
+			return err
+		}
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	stagingKey := path.Join("tmp", id.String())
+	if _, err := s.Blobs.Put(stagingKey, src); err != nil {
+		_ = s.Blobs.Delete(stagingKey)
+		return err
+	}
+	if err := s.Blobs.Move(stagingKey, key); err != nil {
+		_ = s.Blobs.Delete(stagingKey)
+		return err
+	}
+	return nil
+}
+
+// OpenThumbnail returns a reader over the thumbnail of the size of the
+// content of file node n, ErrBlobNotFound if there is none.
+func (s *StorageService) OpenThumbnail(n *models.Node, size string) (*BlobReader, error) {
+	b, err := s.contentBlob(n)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrNoThumbnail
+	}
+	return s.openStored(thumbnailKey(b, size), b.KeyID)
+}
+
+// OpenRendition returns a reader over the rendition name of the content of
+// file node n, ErrBlobNotFound if there is none.
+func (s *StorageService) OpenRendition(n *models.Node, name string) (*BlobReader, error) {
+	b, err := s.contentBlob(n)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrNotImage
+	}
+	return s.openStored(renditionKey(b, name), b.KeyID)
+}
+
+// canonicalKey maps a stored key to the form reported by BlobStore.List.
+func (s *StorageService) canonicalKey(key string) string {
+	if c, ok := s.Blobs.(interface {
+		CanonicalKey(key string) (string, error)
+	}); ok {
+		if k, err := c.CanonicalKey(key); err == nil {
+			return k
+		}
+	}
+	return key
+}
+
+// CreateTemp creates a scratch file in TempDir, see os.CreateTemp.
+func (s *StorageService) CreateTemp(pattern string) (*os.File, error) {
+	if s.TempDir != "" {
+		if err := os.MkdirAll(s.TempDir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return os.CreateTemp(s.TempDir, pattern)
+}
+
+func (s *StorageService) DeleteFile(key string) error {
+	return s.Blobs.Delete(key)
+}
+
+type BlobReader struct {
+	info   *BlobInfo
+	open   func(offset, length int64) (io.ReadCloser, error)
+	offset int64
+	body   io.ReadCloser
+}
+
+func (b *BlobReader) Info() *BlobInfo {
+	return b.info
+}
+
+func (b *BlobReader) Read(p []byte) (int, error) {
+	if b.offset >= b.info.Size {
+		return 0, io.EOF
+	}
+	if b.body == nil {
+		body, err := b.open(b.offset, b.info.Size-b.offset)
+		if err != nil {
+			return 0, err
+		}
+		b.body = body
+	}
+	n, err := b.body.Read(p)
+	b.offset += int64(n)
+	return n, err
+}
+
+func (b *BlobReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = b.offset + offset
+	case io.SeekEnd:
+		abs = b.info.Size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	if abs != b.offset && b.body != nil {
+		b.body.Close()
+		b.body = nil
+	}
+	b.offset = abs
+	return abs, nil
+}
+
+func (b *BlobReader) Close() error {
+	if b.body != nil {
+		err := b.body.Close()
+		b.body = nil
+		return err
+	}
+	return nil
 }
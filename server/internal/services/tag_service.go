@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+var (
+	ErrTagNotFound     = errors.New("tag not found")
+	ErrInvalidTagName  = errors.New("tag name must be 1 to 64 characters")
+	ErrInvalidTagColor = errors.New("tag color must be #rrggbb")
+	ErrMergeIntoSelf   = errors.New("cannot merge a tag into itself")
+	ErrTooManyNodes    = errors.New("too many nodes")
+)
+
+const (
+	// DefaultTagColor is the color of a tag created without one.
+	DefaultTagColor = "#808080"
+	maxTagName      = 64
+	// maxTaggedNodes is how many nodes one request may tag or untag.
+	maxTaggedNodes = 1000
+)
+
+var tagColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// TagService manages the owner's tags and which nodes have them. Tag names
+// are unique per owner, compared like sibling names. Nodes in the trash
+// keep their tags but are not counted or found by them.
+type TagService struct {
+	tagRepo  repository.TagRepository
+	fileRepo repository.FileRepository
+}
+
+func NewTagService(tagRepo repository.TagRepository, fileRepo repository.FileRepository) *TagService {
+	return &TagService{tagRepo: tagRepo, fileRepo: fileRepo}
+}
+
+// TagUsage is a tag with the number of nodes that have it.
+type TagUsage struct {
+	*models.Tag
+	Nodes int
+}
+
+func tagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxTagName {
+		return "", ErrInvalidTagName
+	}
+	return name, nil
+}
+
+func tagColorValue(color string) (string, error) {
+	if !tagColor.MatchString(color) {
+		return "", ErrInvalidTagColor
+	}
+	return strings.ToLower(color), nil
+}
+
+// List returns the owner's tags by name.
+func (s *TagService) List(ownerID string) ([]TagUsage, error) {
+	tags, err := s.tagRepo.ListTags(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.fileRepo.CountTags(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TagUsage, len(tags))
+	for i, t := range tags {
+		out[i] = TagUsage{Tag: t, Nodes: counts[t.ID]}
+	}
+	return out, nil
+}
+
+// Find returns the owner's tag, ErrTagNotFound if the owner has no such
+// tag.
+func (s *TagService) Find(ownerID, id string) (*models.Tag, error) {
+	t, err := s.tagRepo.FindTag(id)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.OwnerID != ownerID {
+		return nil, ErrTagNotFound
+	}
+	return t, nil
+}
+
+// Create adds a tag. An empty color means DefaultTagColor.
+func (s *TagService) Create(ownerID, name, color string) (*models.Tag, error) {
+	name, err := tagName(name)
+	if err != nil {
+		return nil, err
+	}
+	if color == "" {
+		color = DefaultTagColor
+	}
+	if color, err = tagColorValue(color); err != nil {
+		return nil, err
+	}
+	t := &models.Tag{OwnerID: ownerID, Name: name, Color: color}
+	if err := s.tagRepo.CreateTag(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Update renames and recolors a tag; nil leaves a field as it is. Renaming
+// to the name of another tag returns repository.ErrNameTaken, see Merge.
+func (s *TagService) Update(ownerID, id string, name, color *string) (*models.Tag, error) {
+	t, err := s.Find(ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+	if name != nil {
+		if t.Name, err = tagName(*name); err != nil {
+			return nil, err
+		}
+	}
+	if color != nil {
+		if t.Color, err = tagColorValue(*color); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.tagRepo.UpdateTag(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Merge gives every node tagged id the tag intoID instead and deletes id.
+// It returns the remaining tag.
+func (s *TagService) Merge(ownerID, id, intoID string) (*models.Tag, error) {
+	if id == intoID {
+		return nil, ErrMergeIntoSelf
+	}
+	from, err := s.Find(ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+	into, err := s.Find(ownerID, intoID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.fileRepo.RetagNodes(ownerID, from.ID, into.ID); err != nil {
+		return nil, err
+	}
+	if err := s.tagRepo.DeleteTag(from.ID); err != nil {
+		return nil, err
+	}
+	return into, nil
+}
+
+// Delete removes a tag from every node, also those in the trash, and
+// deletes it.
+func (s *TagService) Delete(ownerID, id string) error {
+	t, err := s.Find(ownerID, id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.fileRepo.UntagNodes(ownerID, nil, []string{t.ID}); err != nil {
+		return err
+	}
+	return s.tagRepo.DeleteTag(t.ID)
+}
+
+// Apply adds the tags add to and removes the tags remove from the owner's
+// nodes, skipping nodes in the trash and nodes the owner does not have. It
+// returns the nodes as they are now.
+func (s *TagService) Apply(ownerID string, nodeIDs, add, remove []string) ([]*models.Node, error) {
+	if len(nodeIDs) > maxTaggedNodes {
+		return nil, ErrTooManyNodes
+	}
+	if len(nodeIDs) == 0 {
+		// nil would untag every node
+		return []*models.Node{}, nil
+	}
+	for _, id := range append(append([]string{}, add...), remove...) {
+		if _, err := s.Find(ownerID, id); err != nil {
+			return nil, err
+		}
+	}
+	if len(remove) > 0 {
+		if _, err := s.fileRepo.UntagNodes(ownerID, nodeIDs, remove); err != nil {
+			return nil, err
+		}
+	}
+	if len(add) > 0 {
+		if _, err := s.fileRepo.TagNodes(ownerID, nodeIDs, add); err != nil {
+			return nil, err
+		}
+	}
+	nodes, err := s.fileRepo.FindNodesByIDs(nodeIDs)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*models.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.OwnerID == ownerID {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
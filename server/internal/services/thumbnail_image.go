@@ -0,0 +1,187 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/png"
+)
+
+// scaleDown returns src shrunk to fit in a square of max pixels, keeping its
+// aspect ratio, drawn over white where it is transparent. Images that fit
+// already are copied at their size.
+func scaleDown(src image.Image, max int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > max || h > max {
+		if w >= h {
+			w, h = max, (h*max+w/2)/w
+		} else {
+			w, h = (w*max+h/2)/h, max
+		}
+	}
+	return resample(src, b, maxInt(w, 1), maxInt(h, 1), true)
+}
+
+// resample returns the area r of src scaled to w x h pixels. Every output
+// pixel averages up to 4x4 samples of the source area it covers. Opaque
+// images are drawn over white where src is transparent.
+func resample(src image.Image, r image.Rectangle, w, h int, opaque bool) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sx := float64(r.Dx()) / float64(w)
+	sy := float64(r.Dy()) / float64(h)
+	nx, ny := samples(sx), samples(sy)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var cr, cg, cb, ca, n uint32
+			for j := 0; j < ny; j++ {
+				py := r.Min.Y + int((float64(y)+(float64(j)+0.5)/float64(ny))*sy)
+				for i := 0; i < nx; i++ {
+					px := r.Min.X + int((float64(x)+(float64(i)+0.5)/float64(nx))*sx)
+					pr, pg, pb, pa := src.At(px, py).RGBA()
+					cr += pr
+					cg += pg
+					cb += pb
+					ca += pa
+					n++
+				}
+			}
+			if opaque {
+				// premultiplied, so adding the missing alpha puts it over
+				// white
+				cr += n*0xffff - ca
+				cg += n*0xffff - ca
+				cb += n*0xffff - ca
+				ca = n * 0xffff
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(cr / n >> 8),
+				G: uint8(cg / n >> 8),
+				B: uint8(cb / n >> 8),
+				A: uint8(ca / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// samples is how many source pixels per axis are averaged for a scale.
+func samples(scale float64) int {
+	switch {
+	case scale <= 1:
+		return 1
+	case scale >= 4:
+		return 4
+	}
+	return int(scale + 0.999)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// orient turns an image stored with the EXIF orientation o (1 to 8) upright.
+func orient(src *image.RGBA, o int) *image.RGBA {
+	if o < 2 || o > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		// 5 to 8 swap width and height
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2: // flip horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotate by 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // flip vertically
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate clockwise
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate counterclockwise
+				dx, dy = y, w-1-x
+			}
+			dst.SetRGBA(dx, dy, src.RGBAAt(x, y))
+		}
+	}
+	return dst
+}
+
+// exifOrientation returns the orientation stored in the EXIF data of a JPEG
+// file, 1 (upright) if it has none.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return 1
+	}
+	for p := 2; p+4 <= len(data); {
+		if data[p] != 0xff {
+			return 1
+		}
+		marker := data[p+1]
+		if marker == 0xd9 || marker == 0xda {
+			// end of image or start of the image data: no EXIF
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[p+2:]))
+		if length < 2 || p+2+length > len(data) {
+			return 1
+		}
+		segment := data[p+4 : p+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		p += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag of the first IFD of a TIFF
+// structure, the format of EXIF data.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		e := ifd + 2 + 12*i
+		if e+12 > len(tiff) {
+			return 1
+		}
+		// tag 0x0112 of type SHORT
+		if order.Uint16(tiff[e:]) == 0x0112 && order.Uint16(tiff[e+2:]) == 3 {
+			if o := int(order.Uint16(tiff[e+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
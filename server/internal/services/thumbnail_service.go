@@ -0,0 +1,189 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"server/internal/models"
+)
+
+// ErrNoThumbnail is returned for files no thumbnail is made of.
+var ErrNoThumbnail = errors.New("no thumbnail for this file")
+
+// ThumbnailSizes maps the thumbnail sizes to the longest side in pixels.
+var ThumbnailSizes = map[string]int{
+	"small":  128,
+	"medium": 256,
+	"large":  1024,
+}
+
+const (
+	DefaultThumbnailSize = "medium"
+	// maxThumbnailSource is the largest image thumbnails are made of;
+	// images are read into memory.
+	maxThumbnailSource = 50 << 20
+	// maxThumbnailPixels bounds the decoded image, which takes 4 to 8 bytes
+	// a pixel.
+	maxThumbnailPixels = 50_000_000
+	// maxImageDecodes is how many images are decoded at once, each taking
+	// up to 8 bytes a pixel of maxThumbnailPixels.
+	maxImageDecodes = 2
+	// thumbnailQueueSize is how many files may wait for thumbnails; the
+	// thumbnails of files queued beyond it are made when first asked for.
+	thumbnailQueueSize = 1000
+)
+
+// imageDecodes holds a slot for every image being read, see withImage.
+var imageDecodes = make(chan struct{}, maxImageDecodes)
+
+var thumbnailExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true}
+
+// ThumbnailService makes JPEG thumbnails of JPEG, PNG and GIF images in
+// every size of ThumbnailSizes, turned upright by their EXIF orientation.
+// Thumbnails are made in the background after a file is uploaded or gets
+// new content, and when asked for if they are missing.
+type ThumbnailService struct {
+	storage *StorageService
+	queue   chan *models.Node
+}
+
+func NewThumbnailService(storage *StorageService) *ThumbnailService {
+	return &ThumbnailService{storage: storage, queue: make(chan *models.Node, thumbnailQueueSize)}
+}
+
+// HasThumbnail reports whether thumbnails are made of file node n.
+func HasThumbnail(n *models.Node) bool {
+	return IsImage(n)
+}
+
+// IsImage reports whether file node n is a JPEG, PNG or GIF image small
+// enough to be decoded.
+func IsImage(n *models.Node) bool {
+	if n.Type != "file" || n.Size > maxThumbnailSource || nodeBlobID(n) == "" {
+		return false
+	}
+	switch n.Mime {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return thumbnailExtensions[strings.ToLower(path.Ext(n.Name))]
+}
+
+// Queue has the thumbnails of file node n made in the background. It never
+// blocks.
+func (s *ThumbnailService) Queue(n *models.Node) {
+	if !HasThumbnail(n) {
+		return
+	}
+	select {
+	case s.queue <- n:
+	default:
+		log.Printf("thumbnails: queue full, not generating %s", n.ID)
+	}
+}
+
+// Start makes the thumbnails of queued files in the background.
+func (s *ThumbnailService) Start() {
+	go func() {
+		for n := range s.queue {
+			if err := s.Generate(n); err != nil && !errors.Is(err, ErrNoThumbnail) {
+				log.Printf("thumbnails: %s: %v", n.ID, err)
+			}
+		}
+	}()
+}
+
+// Generate makes and stores every size of thumbnail of file node n. It
+// returns ErrNoThumbnail if n is no image it can decode.
+func (s *ThumbnailService) Generate(n *models.Node) error {
+	if !HasThumbnail(n) {
+		return ErrNoThumbnail
+	}
+	err := withImage(s.storage, n, func(img image.Image, orientation int) error {
+		// the smaller sizes are made from the largest, which is much
+		// faster than going back to the full image
+		largest := ""
+		for size, px := range ThumbnailSizes {
+			if largest == "" || px > ThumbnailSizes[largest] {
+				largest = size
+			}
+		}
+		base := scaleDown(img, ThumbnailSizes[largest])
+		for size, px := range ThumbnailSizes {
+			thumb := base
+			if size != largest {
+				thumb = scaleDown(base, px)
+			}
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, orient(thumb, orientation), &jpeg.Options{Quality: 85}); err != nil {
+				return err
+			}
+			if err := s.storage.PutThumbnail(n, size, &buf); err != nil {
+				return fmt.Errorf("failed to store %s thumbnail: %w", size, err)
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotImage) {
+		return ErrNoThumbnail
+	}
+	return err
+}
+
+// Open returns a reader over the thumbnail of the size of file node n,
+// making it first if it is missing.
+func (s *ThumbnailService) Open(n *models.Node, size string) (*BlobReader, error) {
+	if _, ok := ThumbnailSizes[size]; !ok {
+		return nil, fmt.Errorf("unknown thumbnail size %q", size)
+	}
+	if !HasThumbnail(n) {
+		return nil, ErrNoThumbnail
+	}
+	r, err := s.storage.OpenThumbnail(n, size)
+	if !errors.Is(err, ErrBlobNotFound) {
+		return r, err
+	}
+	if err := s.Generate(n); err != nil {
+		return nil, err
+	}
+	return s.storage.OpenThumbnail(n, size)
+}
+
+// withImage reads the content of file node n, decodes it and calls fn with
+// it and its EXIF orientation. It returns ErrNotImage if n is no image it can
+// decode or is larger than the limits, which are checked before the pixels
+// are allocated so that a small file cannot claim a huge image. At most
+// maxImageDecodes images are held at once; the others wait.
+func withImage(storage *StorageService, n *models.Node, fn func(img image.Image, orientation int) error) error {
+	imageDecodes <- struct{}{}
+	defer func() { <-imageDecodes }()
+
+	r, err := storage.OpenFile(n)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxThumbnailSource+1))
+	r.Close()
+	if err != nil {
+		return err
+	}
+	if len(data) > maxThumbnailSource {
+		return ErrNotImage
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxThumbnailPixels {
+		return ErrNotImage
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ErrNotImage
+	}
+	return fn(img, exifOrientation(data))
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+var ErrNotInTrash = errors.New("node is not in the trash")
+
+// TrashService implements soft delete. Deleted nodes keep their content
+// until they are deleted from the trash or Retention has passed; purging is
+// the only place file content of deleted nodes is released.
+type TrashService struct {
+	Retention time.Duration
+
+	fileRepo repository.FileRepository
+	storage  *StorageService
+	content  *ContentIndexService
+}
+
+func NewTrashService(fileRepo repository.FileRepository, storage *StorageService, retention time.Duration) *TrashService {
+	return &TrashService{Retention: retention, fileRepo: fileRepo, storage: storage}
+}
+
+// EnableContentIndex drops the content search index of purged files whose
+// content no other file has.
+func (t *TrashService) EnableContentIndex(content *ContentIndexService) {
+	t.content = content
+}
+
+// withRepo returns a copy of t working on fileRepo, e.g. a transaction.
+func (t *TrashService) withRepo(fileRepo repository.FileRepository) *TrashService {
+	c := *t
+	c.fileRepo = fileRepo
+	return &c
+}
+
+// Trash moves node and everything below it to the owner's trash. The names
+// of the folders above it are kept so Restore can recreate them.
+func (t *TrashService) Trash(node *models.Node) error {
+	var descendants []string
+	queue := []string{node.ID}
+	seen := map[string]bool{node.ID: true}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		children, err := t.fileRepo.ListChildren(node.OwnerID, id)
+		if err != nil {
+			return err
+		}
+		for _, ch := range children {
+			if seen[ch.ID] {
+				continue
+			}
+			seen[ch.ID] = true
+			descendants = append(descendants, ch.ID)
+			if ch.Type == "folder" {
+				queue = append(queue, ch.ID)
+			}
+		}
+	}
+
+	var names []string
+	for pid, depth := node.ParentID, 0; pid != "" && depth < 1000; depth++ {
+		p, err := t.fileRepo.FindNodeByID(pid)
+		if err != nil {
+			return err
+		}
+		if p == nil || p.OwnerID != node.OwnerID {
+			break
+		}
+		names = append([]string{p.Name}, names...)
+		pid = p.ParentID
+	}
+
+	return t.fileRepo.TrashNodes(node.OwnerID, node.ID, strings.Join(names, "/"), descendants, time.Now())
+}
+
+func (t *TrashService) List(ownerID string) ([]*models.Node, error) {
+	return t.fileRepo.ListTrash(ownerID)
+}
+
+func (t *TrashService) find(ownerID, id string) (*models.Node, error) {
+	node, err := t.fileRepo.FindTrashRoot(id)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil || node.OwnerID != ownerID {
+		return nil, ErrNotInTrash
+	}
+	return node, nil
+}
+
+// Restore takes a deleted node out of the trash. It goes back to its
+// folder, which is recreated from the recorded path if it no longer exists.
+// If the folder already holds a node of the same name it is restored to the
+// root instead, renamed if needed.
+func (t *TrashService) Restore(ownerID, id string) (*models.Node, error) {
+	node, err := t.find(ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	parentID := node.ParentID
+	if parentID != "" {
+		p, err := t.fileRepo.FindNodeByID(parentID)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil || p.OwnerID != ownerID || p.Type != "folder" {
+			if parentID, err = t.ensurePath(ownerID, node.TrashPath); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	name := node.Name
+	err = t.fileRepo.RestoreNodes(ownerID, node.ID, parentID, name)
+	if errors.Is(err, repository.ErrNameTaken) {
+		parentID = ""
+		name, err = placeUnique(t.fileRepo, ownerID, "", node.Name, node.Type, func(name string) error {
+			return t.fileRepo.RestoreNodes(ownerID, node.ID, "", name)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	node.ParentID = parentID
+	node.Name = name
+	node.TrashedAt = nil
+	node.TrashPath = ""
+	node.UpdatedAt = time.Now()
+	return node, nil
+}
+
+// ensurePath returns the folder at the slash separated path below the
+// owner's root, creating the folders that are missing.
+func (t *TrashService) ensurePath(ownerID, folderPath string) (string, error) {
+	parentID := ""
+	for _, name := range strings.Split(folderPath, "/") {
+		if name == "" {
+			continue
+		}
+		folder, _, err := ensureFolder(t.fileRepo, ownerID, parentID, name)
+		if err != nil {
+			return "", err
+		}
+		parentID = folder.ID
+	}
+	return parentID, nil
+}
+
+// Delete permanently deletes a node from the owner's trash.
+func (t *TrashService) Delete(ownerID, id string) error {
+	node, err := t.find(ownerID, id)
+	if err != nil {
+		return err
+	}
+	return t.purge(node)
+}
+
+// Empty permanently deletes everything in the owner's trash.
+func (t *TrashService) Empty(ownerID string) (int, error) {
+	nodes, err := t.fileRepo.ListTrash(ownerID)
+	if err != nil {
+		return 0, err
+	}
+	for i, n := range nodes {
+		if err := t.purge(n); err != nil {
+			return i, err
+		}
+	}
+	return len(nodes), nil
+}
+
+// PurgeExpired permanently deletes what has been in the trash for longer
+// than Retention.
+func (t *TrashService) PurgeExpired() (int, error) {
+	nodes, err := t.fileRepo.ListExpiredTrash(time.Now().Add(-t.Retention))
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, n := range nodes {
+		if err := t.purge(n); err != nil {
+			log.Printf("trash purge: failed to purge %s: %v", n.ID, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// purge deletes the nodes of a trashed tree and releases their content. The
+// root goes last so an interrupted purge is picked up again. A node is
+// deleted before its content is released, and only by one purge, so that a
+// retry never releases it twice; an interrupted release is left to fsck.
+func (t *TrashService) purge(root *models.Node) error {
+	nodes, err := t.fileRepo.ListTrashedWith(root.ID)
+	if err != nil {
+		return err
+	}
+	for _, n := range append(nodes, root) {
+		deleted, err := t.fileRepo.PurgeNode(n.ID)
+		if err != nil {
+			return err
+		}
+		if !deleted || n.Type != "file" {
+			continue
+		}
+		if err := t.storage.ReleaseFile(n); err != nil {
+			log.Printf("trash purge: failed to release content of %s: %v", n.ID, err)
+		}
+		if t.content != nil {
+			if err := t.content.Forget(n.OwnerID, n.Digest); err != nil {
+				log.Printf("trash purge: failed to update content index of %s: %v", n.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// StartPurge runs PurgeExpired every interval in the background.
+func (t *TrashService) StartPurge(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := t.PurgeExpired()
+			if err != nil {
+				log.Printf("trash purge: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("trash purge: purged %d deleted nodes", n)
+			}
+		}
+	}()
+}
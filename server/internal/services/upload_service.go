@@ -0,0 +1,397 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"server/internal/models"
+	"server/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUploadNotFound       = errors.New("upload not found")
+	ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+	ErrUploadLocked         = errors.New("upload is being written by another request")
+	ErrUploadIncomplete     = errors.New("upload is not complete")
+	ErrUploadCompleted      = errors.New("upload already completed")
+	// ErrStagingFull is returned when an upload would take the owner's
+	// unfinished uploads beyond MaxStaged.
+	ErrStagingFull = errors.New("too much data in unfinished uploads")
+)
+
+// UploadService keeps resumable upload sessions. Received bytes are staged in
+// Dir on local disk and handed to StorageService once the upload is complete.
+type UploadService struct {
+	Dir     string
+	MaxSize int64
+	// MaxStaged caps the lengths of a user's unfinished uploads together,
+	// partial ones included; zero means unlimited.
+	MaxStaged int64
+	TTL       time.Duration
+
+	repo    repository.UploadRepository
+	nodes   *NodeService
+	storage *StorageService
+
+	mu    sync.Mutex
+	locks map[string]bool
+}
+
+func NewUploadService(repo repository.UploadRepository, nodes *NodeService, storage *StorageService, dir string, maxSize int64, ttl time.Duration) *UploadService {
+	return &UploadService{
+		Dir:     dir,
+		MaxSize: maxSize,
+		TTL:     ttl,
+		repo:    repo,
+		nodes:   nodes,
+		storage: storage,
+		locks:   map[string]bool{},
+	}
+}
+
+func (s *UploadService) stagingPath(id string) string {
+	return filepath.Join(s.Dir, id)
+}
+
+func (s *UploadService) lock(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locks[id] {
+		return false
+	}
+	s.locks[id] = true
+	return true
+}
+
+func (s *UploadService) unlock(id string) {
+	s.mu.Lock()
+	delete(s.locks, id)
+	s.mu.Unlock()
+}
+
+func (s *UploadService) Create(u *models.UploadSession) error {
+	if u.Length < 0 {
+		return fmt.Errorf("invalid upload length")
+	}
+	if s.MaxSize > 0 && u.Length > s.MaxSize {
+		return fmt.Errorf("%w (max %d bytes)", ErrTooLarge, s.MaxSize)
+	}
+	// unfinished uploads count as if they were stored already, so that
+	// partial uploads, only checked when concatenated, cannot fill the disk
+	staged, err := s.repo.StagedUploadBytes(u.OwnerID)
+	if err != nil {
+		return err
+	}
+	if s.MaxStaged > 0 && staged+u.Length > s.MaxStaged {
+		return fmt.Errorf("%w (max %d bytes)", ErrStagingFull, s.MaxStaged)
+	}
+	files := int64(1)
+	if u.Partial {
+		files = 0
+	}
+	if err := s.storage.CheckQuota(u.OwnerID, staged+u.Length, files); err != nil {
+		return err
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	u.ID = strings.ReplaceAll(id.String(), "-", "")
+	u.Offset = 0
+	u.ExpiresAt = time.Now().Add(s.TTL)
+
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create upload dir: %w", err)
+	}
+	f, err := os.Create(s.stagingPath(u.ID))
+	if err != nil {
+		return fmt.Errorf("failed to create staging file: %w", err)
+	}
+	f.Close()
+
+	if err := s.repo.CreateUpload(u); err != nil {
+		_ = os.Remove(s.stagingPath(u.ID))
+		return err
+	}
+	return nil
+}
+
+func (s *UploadService) Get(id string) (*models.UploadSession, error) {
+	u, err := s.repo.FindUpload(id)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUploadNotFound
+	}
+	return u, nil
+}
+
+// Append writes the bytes of r at offset. Whatever was received is kept even
+// if r fails midway so the client can resume from the new offset. When the
+// last byte of a regular upload arrives the file node is created.
+func (s *UploadService) Append(id string, offset int64, r io.Reader) (*models.UploadSession, error) {
+	if !s.lock(id) {
+		return nil, ErrUploadLocked
+	}
+	defer s.unlock(id)
+
+	u, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if u.NodeID != "" || len(u.Parts) > 0 {
+		return nil, ErrUploadCompleted
+	}
+	if offset != u.Offset {
+		return u, ErrUploadOffsetMismatch
+	}
+
+	f, err := os.OpenFile(s.stagingPath(id), os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open staging file: %w", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	n, copyErr := io.Copy(f, io.LimitReader(r, u.Length-offset))
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	ok, err := s.repo.AdvanceUpload(id, offset, offset+n, time.Now().Add(s.TTL))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrUploadOffsetMismatch
+	}
+	u.Offset = offset + n
+	if copyErr != nil {
+		return u, copyErr
+	}
+
+	if u.Offset == u.Length && !u.Partial {
+		if _, err := s.assemble(u, []string{u.ID}); err != nil {
+			if errors.Is(err, ErrChecksumMismatch) || errors.Is(err, repository.ErrNameTaken) {
+				// the bytes are wrong or the name is taken, neither
+				// changes on resume
+				_ = s.discard(id)
+			}
+			return u, err
+		}
+	}
+	return u, nil
+}
+
+// Concat creates the final upload of a tus concatenation from completed
+// partial uploads and assembles the file node right away.
+func (s *UploadService) Concat(u *models.UploadSession, partIDs []string) (*models.Node, error) {
+	// the parts stay locked until they are discarded, so that concurrent
+	// concatenations cannot assemble them twice
+	locked := append([]string(nil), partIDs...)
+	sort.Strings(locked)
+	for i, pid := range locked {
+		if i > 0 && pid == locked[i-1] {
+			continue
+		}
+		if !s.lock(pid) {
+			return nil, ErrUploadLocked
+		}
+		defer s.unlock(pid)
+	}
+
+	var total int64
+	for _, pid := range partIDs {
+		p, err := s.Get(pid)
+		if err != nil {
+			return nil, err
+		}
+		if p.OwnerID != u.OwnerID || !p.Partial {
+			return nil, ErrUploadNotFound
+		}
+		if p.Offset != p.Length {
+			return nil, ErrUploadIncomplete
+		}
+		total += p.Length
+	}
+	if s.MaxSize > 0 && total > s.MaxSize {
+		return nil, fmt.Errorf("%w (max %d bytes)", ErrTooLarge, s.MaxSize)
+	}
+	if err := s.storage.CheckQuota(u.OwnerID, total, 1); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	u.ID = strings.ReplaceAll(id.String(), "-", "")
+	u.Length = total
+	u.Offset = total
+	u.Parts = partIDs
+	u.ExpiresAt = time.Now().Add(s.TTL)
+	if err := s.repo.CreateUpload(u); err != nil {
+		return nil, err
+	}
+
+	node, err := s.assemble(u, partIDs)
+	if err != nil && node == nil {
+		_ = s.repo.DeleteUpload(u.ID)
+		return nil, err
+	}
+	if err != nil {
+		// the node exists, the session expires unfinished
+		log.Printf("upload %s: %v", u.ID, err)
+	}
+	for _, pid := range partIDs {
+		_ = s.discard(pid)
+	}
+	return node, nil
+}
+
+// assemble creates the file node of u from the staged uploads. The node is
+// created under u.PendingID, recorded first, so that when recording it on u
+// fails a retry returns the node instead of creating another one; the node
+// is returned along with that error.
+func (s *UploadService) assemble(u *models.UploadSession, stagedIDs []string) (*models.Node, error) {
+	if u.PendingID != "" {
+		n, err := s.nodes.fileRepo.FindNodeByID(u.PendingID)
+		if err != nil {
+			return nil, err
+		}
+		if n != nil && n.OwnerID == u.OwnerID {
+			return s.complete(u, n)
+		}
+	} else {
+		id := repository.NewNodeID()
+		if err := s.repo.ReserveUploadNode(u.ID, id); err != nil {
+			return nil, err
+		}
+		u.PendingID = id
+	}
+
+	var readers []io.Reader
+	for _, sid := range stagedIDs {
+		f, err := os.Open(s.stagingPath(sid))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open staging file: %w", err)
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+
+	blob, err := s.storage.IngestFile(u.OwnerID, io.MultiReader(readers...), s.MaxSize, u.Expected)
+	if err != nil {
+		return nil, err
+	}
+	if blob.Size != u.Length {
+		_ = s.storage.DiscardIngested(u.OwnerID, blob)
+		return nil, fmt.Errorf("assembled size %d does not match upload length %d", blob.Size, u.Length)
+	}
+
+	mimeType := u.Mime
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(strings.ToLower(filepath.Ext(u.Name)))
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	node, _, err := s.nodes.CreateFile(&models.Node{
+		ID:       u.PendingID,
+		OwnerID:  u.OwnerID,
+		ParentID: u.ParentID,
+		Name:     u.Name,
+		Type:     "file",
+		Size:     blob.Size,
+		Path:     blob.Key,
+		Digest:   blob.Digest,
+		MD5:      blob.MD5,
+		CRC32C:   blob.CRC32C,
+		BlobID:   blob.ID,
+		Mime:     mimeType,
+	}, blob, u.Metadata["conflict"])
+	if err != nil {
+		return nil, err
+	}
+	return s.complete(u, node)
+}
+
+// complete records the file node assembled from u.
+func (s *UploadService) complete(u *models.UploadSession, node *models.Node) (*models.Node, error) {
+	if err := s.repo.CompleteUpload(u.ID, node.ID); err != nil {
+		return node, err
+	}
+	u.NodeID = node.ID
+	if len(u.Parts) == 0 {
+		_ = os.Remove(s.stagingPath(u.ID))
+	}
+	return node, nil
+}
+
+func (s *UploadService) Terminate(id string) error {
+	if !s.lock(id) {
+		return ErrUploadLocked
+	}
+	defer s.unlock(id)
+	return s.discard(id)
+}
+
+func (s *UploadService) discard(id string) error {
+	if err := os.Remove(s.stagingPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return s.repo.DeleteUpload(id)
+}
+
+// PurgeExpired removes sessions (and their staged bytes) that were not
+// touched within TTL, finished or not.
+func (s *UploadService) PurgeExpired() (int, error) {
+	expired, err := s.repo.ListExpiredUploads(time.Now())
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, u := range expired {
+		if !s.lock(u.ID) {
+			continue
+		}
+		err := s.discard(u.ID)
+		s.unlock(u.ID)
+		if err != nil {
+			log.Printf("upload cleanup: failed to discard %s: %v", u.ID, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (s *UploadService) StartJanitor(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := s.PurgeExpired()
+			if err != nil {
+				log.Printf("upload cleanup: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("upload cleanup: purged %d expired uploads", n)
+			}
+		}
+	}()
+}
@@ -0,0 +1,378 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"server/internal/models"
+	"server/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrVersionNotFound      = errors.New("version not found")
+	ErrInvalidVersionLimits = errors.New("version limits must not be negative")
+	ErrNodeChanged          = errors.New("file was changed concurrently, try again")
+)
+
+// VersionService keeps the previous contents of overwritten files for users
+// who enabled versioning. MaxVersions and MaxAge bound what any user may keep
+// and are the defaults for users without their own limits.
+type VersionService struct {
+	MaxVersions int
+	MaxAge      time.Duration
+
+	fileRepo repository.FileRepository
+	userRepo repository.UserRepository
+	storage  *StorageService
+	content  *ContentIndexService
+	recent   *RecentService
+	thumbs   *ThumbnailService
+}
+
+func NewVersionService(fileRepo repository.FileRepository, userRepo repository.UserRepository, storage *StorageService, maxVersions int, maxAge time.Duration) *VersionService {
+	return &VersionService{MaxVersions: maxVersions, MaxAge: maxAge, fileRepo: fileRepo, userRepo: userRepo, storage: storage}
+}
+
+// EnableContentIndex keeps the content search index in step with replaced
+// and restored contents.
+func (v *VersionService) EnableContentIndex(content *ContentIndexService) {
+	v.content = content
+}
+
+// EnableRecent puts files that get new content on their owner's recent
+// list.
+func (v *VersionService) EnableRecent(recent *RecentService) {
+	v.recent = recent
+}
+
+// EnableThumbnails has thumbnails made of replaced and restored contents.
+func (v *VersionService) EnableThumbnails(thumbs *ThumbnailService) {
+	v.thumbs = thumbs
+}
+
+// contentChanged indexes the new content of n and drops the index of its
+// previous content if no file has it anymore.
+func (v *VersionService) contentChanged(n *models.Node, prevDigest string) {
+	if v.thumbs != nil {
+		v.thumbs.Queue(n)
+	}
+	if v.content == nil {
+		return
+	}
+	v.content.Queue(n)
+	if prevDigest != n.Digest {
+		if err := v.content.Forget(n.OwnerID, prevDigest); err != nil {
+			log.Printf("versions: failed to update content index of %s: %v", n.ID, err)
+		}
+	}
+}
+
+// Settings returns the owner's versioning settings with the limits that
+// apply to them resolved.
+func (v *VersionService) Settings(ownerID string) (*models.VersioningSettings, error) {
+	u, err := v.userRepo.FindByID(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	s := models.VersioningSettings{}
+	if u != nil && u.Versioning != nil {
+		s = *u.Versioning
+	}
+	if s.MaxVersions <= 0 || (v.MaxVersions > 0 && s.MaxVersions > v.MaxVersions) {
+		s.MaxVersions = v.MaxVersions
+	}
+	maxDays := int(v.MaxAge / (24 * time.Hour))
+	if s.MaxAgeDays <= 0 || (maxDays > 0 && s.MaxAgeDays > maxDays) {
+		s.MaxAgeDays = maxDays
+	}
+	return &s, nil
+}
+
+// SetSettings stores the owner's settings. Limits above the server limits
+// are lowered to them.
+func (v *VersionService) SetSettings(ownerID string, s *models.VersioningSettings) (*models.VersioningSettings, error) {
+	if s.MaxVersions < 0 || s.MaxAgeDays < 0 {
+		return nil, ErrInvalidVersionLimits
+	}
+	if err := v.userRepo.SetVersioning(ownerID, s); err != nil {
+		return nil, err
+	}
+	// turning versioning off keeps what was kept until it ages out
+	return v.Settings(ownerID)
+}
+
+// UploadTarget returns the file an upload of name into parentID overwrites,
+// or nil when the owner keeps versions off or there is no such file.
+func (v *VersionService) UploadTarget(ownerID, parentID, name string) (*models.Node, error) {
+	s, err := v.Settings(ownerID)
+	if err != nil || !s.Enabled {
+		return nil, err
+	}
+	n, err := v.fileRepo.FindChildByName(ownerID, parentID, name)
+	if err != nil || n == nil || n.Type != "file" {
+		return nil, err
+	}
+	return n, nil
+}
+
+// ReplaceContent makes blob the current content of the file node. The
+// previous content is kept as a version if the owner enabled versioning and
+// released otherwise. blob must be charged for its bytes only, see
+// StorageService.IngestContent; pass chargedAsFile for a blob from
+// IngestFile. On error the blob is released.
+func (v *VersionService) ReplaceContent(node *models.Node, blob *models.Blob, mimeType string, chargedAsFile bool) (*models.Node, error) {
+	if chargedAsFile {
+		if err := v.storage.uncharge(node.OwnerID, 0, 1); err != nil {
+			_ = v.storage.DiscardIngested(node.OwnerID, blob)
+			return nil, err
+		}
+	}
+	discard := func() {
+		_ = v.storage.ReleaseVersion(node.OwnerID, models.NodeVersion{BlobID: blob.ID, Size: blob.Size})
+	}
+	settings, err := v.Settings(node.OwnerID)
+	if err != nil {
+		discard()
+		return nil, err
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		cur, err := v.fileRepo.FindNodeByID(node.ID)
+		if err != nil || cur == nil || cur.Type != "file" {
+			discard()
+			if err == nil {
+				err = errors.New("file not found")
+			}
+			return nil, err
+		}
+		prev := *cur
+		prevUpdatedAt := cur.UpdatedAt
+
+		old := currentVersion(cur)
+		var dropped []models.NodeVersion
+		if settings.Enabled {
+			cur.Versions = append([]models.NodeVersion{old}, cur.Versions...)
+		} else {
+			dropped = append(dropped, old)
+		}
+		cur.Versions, dropped = v.prune(cur.Versions, settings, dropped)
+
+		cur.Size = blob.Size
+		cur.Path = blob.Key
+		cur.Digest = blob.Digest
+		cur.MD5 = blob.MD5
+		cur.CRC32C = blob.CRC32C
+		cur.BlobID = blob.ID
+		cur.Corrupt = false
+		if mimeType != "" {
+			cur.Mime = mimeType
+		}
+
+		ok, err := v.fileRepo.UpdateNodeContent(cur, prevUpdatedAt)
+		if err != nil {
+			discard()
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		v.release(&prev, dropped)
+		v.contentChanged(cur, prev.Digest)
+		if v.recent != nil {
+			v.recent.Touch(cur.OwnerID, cur.ID, cur.Type, RecentUpload)
+		}
+		return cur, nil
+	}
+	discard()
+	return nil, ErrNodeChanged
+}
+
+func currentVersion(n *models.Node) models.NodeVersion {
+	id, _ := uuid.NewRandom()
+	return models.NodeVersion{
+		ID:         strings.ReplaceAll(id.String(), "-", ""),
+		Size:       n.Size,
+		Mime:       n.Mime,
+		Path:       n.Path,
+		Digest:     n.Digest,
+		MD5:        n.MD5,
+		CRC32C:     n.CRC32C,
+		BlobID:     nodeBlobID(n),
+		ReplacedAt: time.Now(),
+	}
+}
+
+// prune splits versions, newest first, into those the settings keep and
+// those to drop, which are added to dropped.
+func (v *VersionService) prune(versions []models.NodeVersion, s *models.VersioningSettings, dropped []models.NodeVersion) ([]models.NodeVersion, []models.NodeVersion) {
+	var kept []models.NodeVersion
+	cutoff := time.Now().Add(-time.Duration(s.MaxAgeDays) * 24 * time.Hour)
+	for _, ver := range versions {
+		if (s.MaxVersions > 0 && len(kept) >= s.MaxVersions) || (s.MaxAgeDays > 0 && ver.ReplacedAt.Before(cutoff)) {
+			dropped = append(dropped, ver)
+			continue
+		}
+		kept = append(kept, ver)
+	}
+	return kept, dropped
+}
+
+func (v *VersionService) release(n *models.Node, versions []models.NodeVersion) {
+	for _, ver := range versions {
+		if err := v.storage.ReleaseVersion(n.OwnerID, ver); err != nil {
+			log.Printf("versions: failed to release version %s of %s: %v", ver.ID, n.ID, err)
+		}
+	}
+}
+
+// Find returns the version of the file node.
+func (v *VersionService) Find(node *models.Node, versionID string) (*models.NodeVersion, error) {
+	for i := range node.Versions {
+		if node.Versions[i].ID == versionID {
+			return &node.Versions[i], nil
+		}
+	}
+	return nil, ErrVersionNotFound
+}
+
+// Open returns a reader over the content of a version.
+func (v *VersionService) Open(node *models.Node, ver *models.NodeVersion) (*BlobReader, error) {
+	return v.storage.OpenFile(&models.Node{OwnerID: node.OwnerID, Path: ver.Path, Digest: ver.Digest, BlobID: ver.BlobID})
+}
+
+// Restore makes a version the current content again. The current content
+// takes its place in the list of versions, so nothing is lost.
+func (v *VersionService) Restore(node *models.Node, versionID string) (*models.Node, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		cur, err := v.fileRepo.FindNodeByID(node.ID)
+		if err != nil {
+			return nil, err
+		}
+		if cur == nil {
+			return nil, ErrVersionNotFound
+		}
+		prevUpdatedAt := cur.UpdatedAt
+		prevDigest := cur.Digest
+		ver, err := v.Find(cur, versionID)
+		if err != nil {
+			return nil, err
+		}
+		restored := *ver
+
+		versions := []models.NodeVersion{currentVersion(cur)}
+		for _, other := range cur.Versions {
+			if other.ID != versionID {
+				versions = append(versions, other)
+			}
+		}
+		cur.Versions = versions
+		cur.Size = restored.Size
+		cur.Mime = restored.Mime
+		cur.Path = restored.Path
+		cur.Digest = restored.Digest
+		cur.MD5 = restored.MD5
+		cur.CRC32C = restored.CRC32C
+		cur.BlobID = restored.BlobID
+		cur.Corrupt = false
+
+		// both contents are charged already, they only swap places
+		ok, err := v.fileRepo.UpdateNodeContent(cur, prevUpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			v.contentChanged(cur, prevDigest)
+			return cur, nil
+		}
+	}
+	return nil, ErrNodeChanged
+}
+
+// Delete drops a version and releases its content.
+func (v *VersionService) Delete(node *models.Node, versionID string) error {
+	for attempt := 0; attempt < 3; attempt++ {
+		cur, err := v.fileRepo.FindNodeByID(node.ID)
+		if err != nil {
+			return err
+		}
+		if cur == nil {
+			return ErrVersionNotFound
+		}
+		prevUpdatedAt := cur.UpdatedAt
+		ver, err := v.Find(cur, versionID)
+		if err != nil {
+			return err
+		}
+		dropped := *ver
+		var kept []models.NodeVersion
+		for _, other := range cur.Versions {
+			if other.ID != versionID {
+				kept = append(kept, other)
+			}
+		}
+		cur.Versions = kept
+		ok, err := v.fileRepo.UpdateNodeContent(cur, prevUpdatedAt)
+		if err != nil {
+			return err
+		}
+		if ok {
+			v.release(cur, []models.NodeVersion{dropped})
+			return nil
+		}
+	}
+	return ErrNodeChanged
+}
+
+// PruneExpired drops the versions every owner's settings no longer keep.
+func (v *VersionService) PruneExpired() (int, error) {
+	settings := map[string]*models.VersioningSettings{}
+	pruned := 0
+	err := v.fileRepo.WalkVersionedNodes(func(n *models.Node) error {
+		s, ok := settings[n.OwnerID]
+		if !ok {
+			var err error
+			if s, err = v.Settings(n.OwnerID); err != nil {
+				return err
+			}
+			settings[n.OwnerID] = s
+		}
+		kept, dropped := v.prune(n.Versions, s, nil)
+		if len(dropped) == 0 {
+			return nil
+		}
+		prevUpdatedAt := n.UpdatedAt
+		n.Versions = kept
+		ok, err := v.fileRepo.UpdateNodeContent(n, prevUpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to prune %s: %w", n.ID, err)
+		}
+		if ok {
+			v.release(n, dropped)
+			pruned += len(dropped)
+		}
+		return nil
+	})
+	return pruned, err
+}
+
+// StartPrune runs PruneExpired every interval in the background.
+func (v *VersionService) StartPrune(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := v.PruneExpired()
+			if err != nil {
+				log.Printf("versions: %v", err)
+			}
+			if n > 0 {
+				log.Printf("versions: pruned %d expired versions", n)
+			}
+		}
+	}()
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"server/internal/commands"
 	"server/internal/controllers"
 	"server/internal/middleware"
 	"server/internal/repository"
@@ -56,6 +60,208 @@ func main() {
 
 	authSrv := services.NewAuthService(repo)
 
+	fileRepo, err := repository.NewMongoFileRepo(client, dbName)
+	if err != nil {
+		log.Fatalf("failed to init file repo: %v", err)
+	}
+	blobStore, err := newBlobStore()
+	if err != nil {
+		log.Fatalf("failed to init blob store: %v", err)
+	}
+	blobRepo, err := repository.NewMongoBlobRepo(client, dbName)
+	if err != nil {
+		log.Fatalf("failed to init blob repo: %v", err)
+	}
+	storageSvc := services.NewStorageService(blobStore, blobRepo, 100<<20)
+	storageSvc.TempDir = os.Getenv("TEMP_DIR")
+	if local, ok := blobStore.(*services.LocalBlobStore); ok && storageSvc.TempDir == "" {
+		// same filesystem as the blobs, so scratch files never cross devices
+		storageSvc.TempDir = filepath.Join(local.Root, "tmp")
+	}
+
+	uploadRepo, err := repository.NewMongoUploadRepo(client, dbName)
+	if err != nil {
+		log.Fatalf("failed to init upload repo: %v", err)
+	}
+	uploadDir := os.Getenv("UPLOAD_DIR")
+	if uploadDir == "" {
+		uploadDir = "./uploads"
+	}
+	uploadMaxSize := int64(10 << 30)
+	if v := os.Getenv("UPLOAD_MAX_SIZE"); v != "" {
+		uploadMaxSize = envInt64("UPLOAD_MAX_SIZE")
+	}
+	// unfinished uploads of a user together, partial ones included
+	uploadMaxStaged := 2 * uploadMaxSize
+	if v := os.Getenv("UPLOAD_MAX_STAGED"); v != "" {
+		uploadMaxStaged = envInt64("UPLOAD_MAX_STAGED")
+	}
+	uploadTTL := 24 * time.Hour
+	if v := os.Getenv("UPLOAD_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid UPLOAD_TTL %q", v)
+		}
+		uploadTTL = d
+	}
+	keyRepo, err := repository.NewMongoKeyRepo(client, dbName)
+	if err != nil {
+		log.Fatalf("failed to init key repo: %v", err)
+	}
+	var keySvc *services.KeyService
+	if spec := os.Getenv("ENCRYPTION_MASTER_KEYS"); spec != "" {
+		masterKeys, err := services.ParseMasterKeys(spec)
+		if err != nil {
+			log.Fatalf("invalid ENCRYPTION_MASTER_KEYS: %v", err)
+		}
+		keySvc, err = services.NewKeyService(keyRepo, os.Getenv("ENCRYPTION_ACTIVE_KEY"), masterKeys)
+		if err != nil {
+			log.Fatalf("failed to init key service: %v", err)
+		}
+		storageSvc.EnableEncryption(keySvc)
+	}
+
+	quotaRepo, err := repository.NewMongoQuotaRepo(client, dbName)
+	if err != nil {
+		log.Fatalf("failed to init quota repo: %v", err)
+	}
+	quotaSvc := services.NewQuotaService(quotaRepo, fileRepo, envInt64("QUOTA_DEFAULT_BYTES"), envInt64("QUOTA_DEFAULT_FILES"))
+	storageSvc.EnableQuotas(quotaSvc)
+
+	jobRepo, err := repository.NewMongoJobRepo(client, dbName)
+	if err != nil {
+		log.Fatalf("failed to init job repo: %v", err)
+	}
+	copySvc := services.NewCopyService(fileRepo, jobRepo, storageSvc)
+
+	trashRetention := 30 * 24 * time.Hour
+	if v := os.Getenv("TRASH_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid TRASH_RETENTION %q", v)
+		}
+		trashRetention = d
+	}
+	trashSvc := services.NewTrashService(fileRepo, storageSvc, trashRetention)
+
+	versionMaxCount := 20
+	if v := os.Getenv("VERSION_MAX_COUNT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("invalid VERSION_MAX_COUNT %q", v)
+		}
+		versionMaxCount = n
+	}
+	versionMaxAge := 90 * 24 * time.Hour
+	if v := os.Getenv("VERSION_MAX_AGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("invalid VERSION_MAX_AGE %q", v)
+		}
+		versionMaxAge = d
+	}
+	versionSvc := services.NewVersionService(fileRepo, repo, storageSvc, versionMaxCount, versionMaxAge)
+
+	recentRepo, err := repository.NewMongoRecentRepo(client, dbName)
+	if err != nil {
+		log.Fatalf("failed to init recent repo: %v", err)
+	}
+	recentSvc := services.NewRecentService(recentRepo, fileRepo)
+	versionSvc.EnableRecent(recentSvc)
+
+	nodeSvc := services.NewNodeService(fileRepo, storageSvc, trashSvc)
+	nodeSvc.EnableVersioning(versionSvc)
+	nodeSvc.EnableRecent(recentSvc)
+	thumbSvc := services.NewThumbnailService(storageSvc)
+	nodeSvc.EnableThumbnails(thumbSvc)
+	versionSvc.EnableThumbnails(thumbSvc)
+	imageSvc := services.NewImageService(storageSvc)
+	uploadSvc := services.NewUploadService(uploadRepo, nodeSvc, storageSvc, uploadDir, uploadMaxSize, uploadTTL)
+	uploadSvc.MaxStaged = uploadMaxStaged
+	batchSvc := services.NewBatchService(fileRepo, nodeSvc, trashSvc, copySvc)
+	archiveSvc := services.NewArchiveService(fileRepo, storageSvc)
+	if v := envInt64("ARCHIVE_MAX_BYTES"); v > 0 {
+		archiveSvc.MaxBytes = v
+	}
+	if v := envInt64("ARCHIVE_MAX_ENTRIES"); v > 0 {
+		archiveSvc.MaxEntries = int(v)
+	}
+
+	// extracted text is stored in MongoDB unencrypted, so content search can
+	// be turned off
+	var contentSvc *services.ContentIndexService
+	if strings.ToLower(os.Getenv("CONTENT_INDEX")) != "false" {
+		contentRepo, err := repository.NewMongoContentRepo(client, dbName)
+		if err != nil {
+			log.Fatalf("failed to init content repo: %v", err)
+		}
+		contentSvc = services.NewContentIndexService(contentRepo, fileRepo, storageSvc)
+		nodeSvc.EnableContentIndex(contentSvc)
+		versionSvc.EnableContentIndex(contentSvc)
+		trashSvc.EnableContentIndex(contentSvc)
+	}
+
+	tagRepo, err := repository.NewMongoTagRepo(client, dbName)
+	if err != nil {
+		log.Fatalf("failed to init tag repo: %v", err)
+	}
+	tagSvc := services.NewTagService(tagRepo, fileRepo)
+	metadataKeyRepo, err := repository.NewMongoMetadataKeyRepo(client, dbName)
+	if err != nil {
+		log.Fatalf("failed to init metadata key repo: %v", err)
+	}
+	metadataSvc := services.NewMetadataService(fileRepo, metadataKeyRepo)
+
+	fsckSvc := services.NewFsckService(fileRepo, blobRepo, repo, storageSvc)
+	scrubSvc := services.NewScrubService(blobRepo, fileRepo, storageSvc)
+	storageSvc.EnableScrub(scrubSvc)
+
+	if len(os.Args) > 1 {
+		env := &commands.Env{
+			Keys:      keySvc,
+			Fsck:      fsckSvc,
+			Scrub:     scrubSvc,
+			Migration: services.NewLayoutMigration(fileRepo, repo, storageSvc),
+			Quotas:    quotaSvc,
+			Nodes:     nodeSvc,
+			Content:   contentSvc,
+		}
+		if err := commands.Run(env, os.Args[1:]); err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	if n, err := copySvc.FailInterruptedJobs(); err != nil {
+		log.Printf("failed to mark interrupted copy jobs: %v", err)
+	} else if n > 0 {
+		log.Printf("marked %d copy jobs interrupted by the restart as failed", n)
+	}
+	uploadSvc.StartJanitor(time.Hour)
+	trashSvc.StartPurge(time.Hour)
+	versionSvc.StartPrune(time.Hour)
+	thumbSvc.Start()
+	if contentSvc != nil {
+		contentSvc.Start()
+	}
+
+	if v := os.Getenv("FSCK_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			log.Fatalf("invalid FSCK_INTERVAL %q", v)
+		}
+		fsckOpts := services.DefaultFsckOptions()
+		fsckOpts.DryRun = strings.ToLower(os.Getenv("FSCK_REPAIR")) != "true"
+		fsckSvc.StartPeriodic(interval, fsckOpts)
+	}
+	if v := os.Getenv("SCRUB_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			log.Fatalf("invalid SCRUB_INTERVAL %q", v)
+		}
+		scrubSvc.StartPeriodic(interval)
+	}
+
 	r := gin.Default()
 
 	allowedOrigins := []string{"http://localhost:3000"}
@@ -63,11 +269,13 @@ func main() {
 		allowedOrigins = strings.Split(origins, ",")
 	}
 
+	tusHeaders := []string{"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata", "Upload-Concat"}
 	c := cors.New(cors.Options{
 		AllowedOrigins:   allowedOrigins,
 		AllowCredentials: true,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type", "Origin"},
+		AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   append([]string{"Authorization", "Content-Type", "Origin"}, tusHeaders...),
+		ExposedHeaders:   append([]string{"Location", "Tus-Version", "Tus-Extension", "Tus-Max-Size", "Upload-Expires", "X-Node-Id", "X-Next-Cursor"}, tusHeaders...),
 	})
 
 	r.Use(c)
@@ -78,33 +286,72 @@ func main() {
 	r.POST("/auth/logout", controllers.LogoutHandler(authSrv))
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	fileRepo, err := repository.NewMongoFileRepo(client, dbName)
-	if err != nil {
-		log.Fatalf("failed to init file repo: %v", err)
-	}
-	storageBase := os.Getenv("STORAGE_BASE")
-	if storageBase == "" {
-		storageBase = "./storage"
-	}
-	storageSvc := services.NewStorageService(storageBase, 100<<20)
-
 	authMw := middleware.AuthMiddleware()
 
-	r.POST("/folders", authMw, controllers.CreateFolderHandler(fileRepo))
-	r.GET("/files", authMw, controllers.ListHandler(fileRepo))
-	r.GET("/folders/:parent_id", authMw, controllers.FoldersListHandler(fileRepo))
-	r.POST("/files/upload", authMw, controllers.UploadHandler(fileRepo, storageSvc))
-	r.POST("/files/unzip", authMw, controllers.UnzipHandler(fileRepo, storageSvc))
-	r.POST("/move/:id", authMw, controllers.MoveHandler(fileRepo))
-	r.GET("/files/:id/download", authMw, controllers.DownloadHandler(fileRepo))
-	r.DELETE("/files/:id", authMw, controllers.DeleteHandler(fileRepo, storageSvc))
+	r.POST("/folders", authMw, controllers.CreateFolderHandler(nodeSvc))
+	r.GET("/files", authMw, controllers.ListHandler(fileRepo, recentSvc))
+	r.GET("/folders/:parent_id", authMw, controllers.FoldersListHandler(fileRepo, recentSvc))
+	r.POST("/files/upload", authMw, controllers.UploadHandler(storageSvc, nodeSvc))
+	r.PUT("/files/upload", authMw, controllers.UploadRawHandler(storageSvc, nodeSvc))
+	r.POST("/files/unzip", authMw, controllers.UnzipHandler(fileRepo, storageSvc, nodeSvc))
+	r.OPTIONS("/files/tus", controllers.TusOptionsHandler(uploadSvc))
+	r.POST("/files/tus", authMw, controllers.TusCreateHandler(fileRepo, uploadSvc))
+	r.HEAD("/files/tus/:id", authMw, controllers.TusHeadHandler(uploadSvc))
+	r.PATCH("/files/tus/:id", authMw, controllers.TusPatchHandler(uploadSvc))
+	r.DELETE("/files/tus/:id", authMw, controllers.TusDeleteHandler(uploadSvc))
+	r.POST("/move/:id", authMw, controllers.MoveHandler(fileRepo, nodeSvc))
+	r.POST("/copy/:id", authMw, controllers.CopyHandler(fileRepo, copySvc))
+	r.POST("/batch", authMw, controllers.BatchHandler(batchSvc))
+	r.GET("/jobs/:id", authMw, controllers.JobHandler(copySvc))
+	r.GET("/files/:id/download", authMw, controllers.DownloadHandler(fileRepo, storageSvc, recentSvc))
+	r.GET("/files/:id/thumbnail", authMw, controllers.ThumbnailHandler(fileRepo, thumbSvc))
+	r.GET("/files/:id/image", authMw, controllers.ImageHandler(fileRepo, imageSvc))
+	r.PATCH("/files/:id", authMw, controllers.UpdateNodeHandler(fileRepo))
+	r.PUT("/files/:id/content", authMw, controllers.PutContentHandler(fileRepo, storageSvc, versionSvc))
+	r.GET("/files/:id/versions", authMw, controllers.ListVersionsHandler(fileRepo))
+	r.GET("/files/:id/versions/:version_id/download", authMw, controllers.DownloadVersionHandler(fileRepo, versionSvc))
+	r.POST("/files/:id/versions/:version_id/restore", authMw, controllers.RestoreVersionHandler(fileRepo, versionSvc))
+	r.DELETE("/files/:id/versions/:version_id", authMw, controllers.DeleteVersionHandler(fileRepo, versionSvc))
+	r.DELETE("/files/:id", authMw, controllers.DeleteHandler(fileRepo, trashSvc))
 	r.GET("/folder/:parent_id/parent", authMw, controllers.ParentHandler(fileRepo))
+	r.GET("/nodes/:id", authMw, controllers.NodeDetailsHandler(fileRepo, nodeSvc, recentSvc))
+	r.PUT("/nodes/:id/star", authMw, controllers.StarHandler(fileRepo))
+	r.DELETE("/nodes/:id/star", authMw, controllers.UnstarHandler(fileRepo))
+	r.PUT("/nodes/:id/metadata", authMw, controllers.ReplaceMetadataHandler(metadataSvc))
+	r.PATCH("/nodes/:id/metadata", authMw, controllers.PatchMetadataHandler(metadataSvc))
+	r.DELETE("/nodes/:id/metadata/:key", authMw, controllers.DeleteMetadataKeyHandler(metadataSvc))
+	r.GET("/metadata/keys", authMw, controllers.ListMetadataKeysHandler(metadataSvc))
+	r.PUT("/metadata/keys/:key", authMw, controllers.DefineMetadataKeyHandler(metadataSvc))
+	r.DELETE("/metadata/keys/:key", authMw, controllers.DeleteMetadataKeyDefinitionHandler(metadataSvc))
+	r.GET("/starred", authMw, controllers.ListStarredHandler(nodeSvc))
+	r.GET("/recent", authMw, controllers.ListRecentHandler(recentSvc))
+	r.GET("/resolve", authMw, controllers.ResolveHandler(nodeSvc))
+	r.GET("/search", authMw, controllers.SearchHandler(nodeSvc))
+	if contentSvc != nil {
+		r.GET("/search/content", authMw, controllers.ContentSearchHandler(contentSvc))
+	}
+	r.GET("/tags", authMw, controllers.ListTagsHandler(tagSvc))
+	r.POST("/tags", authMw, controllers.CreateTagHandler(tagSvc))
+	r.PATCH("/tags/:id", authMw, controllers.UpdateTagHandler(tagSvc))
+	r.DELETE("/tags/:id", authMw, controllers.DeleteTagHandler(tagSvc))
+	r.POST("/tags/:id/merge", authMw, controllers.MergeTagHandler(tagSvc))
+	r.GET("/tags/:id/nodes", authMw, controllers.ListTagNodesHandler(tagSvc, nodeSvc))
+	r.POST("/nodes/tags", authMw, controllers.UpdateNodeTagsHandler(tagSvc))
 	r.GET("/folders/:parent_id/stats", authMw, controllers.FolderStatsHandler(fileRepo))
+	r.GET("/folders/:parent_id/archive", authMw, controllers.FolderArchiveHandler(fileRepo, archiveSvc))
+	r.POST("/archive", authMw, controllers.ArchiveHandler(fileRepo, archiveSvc))
+	r.GET("/trash", authMw, controllers.ListTrashHandler(trashSvc))
+	r.DELETE("/trash", authMw, controllers.EmptyTrashHandler(trashSvc))
+	r.POST("/trash/:id/restore", authMw, controllers.RestoreHandler(trashSvc))
+	r.DELETE("/trash/:id", authMw, controllers.DeleteFromTrashHandler(trashSvc))
 
 	r.GET("/me", authMw, controllers.GetMeHandler(authSrv))
 	r.POST("/me/profile", authMw, controllers.UpdateProfileHandler(authSrv, storageSvc))
 	r.POST("/me/email", authMw, controllers.ChangeEmailHandler(authSrv))
 	r.POST("/me/password", authMw, controllers.ChangePasswordHandler(authSrv))
+	r.GET("/me/usage", authMw, controllers.UsageHandler(quotaSvc))
+	r.GET("/me/versioning", authMw, controllers.GetVersioningHandler(versionSvc))
+	r.PUT("/me/versioning", authMw, controllers.UpdateVersioningHandler(versionSvc))
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -112,3 +359,42 @@ func main() {
 	}
 	_ = r.Run(":" + port)
 }
+
+func envInt64(name string) int64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		log.Fatalf("invalid %s %q", name, v)
+	}
+	return n
+}
+
+func newBlobStore() (services.BlobStore, error) {
+	switch driver := strings.ToLower(os.Getenv("STORAGE_DRIVER")); driver {
+	case "", "local":
+		storageBase := os.Getenv("STORAGE_BASE")
+		if storageBase == "" {
+			storageBase = "./storage"
+		}
+		return services.NewLocalBlobStore(storageBase), nil
+	case "s3":
+		pathStyle := true
+		if v := os.Getenv("S3_PATH_STYLE"); v != "" {
+			pathStyle = strings.ToLower(v) == "true"
+		}
+		return services.NewS3BlobStore(services.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PathStyle: pathStyle,
+			Prefix:    os.Getenv("S3_PREFIX"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+}